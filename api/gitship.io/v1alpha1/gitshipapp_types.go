@@ -20,20 +20,88 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// PullRequestsAnnotation holds the open pull requests of an app with previews
+// enabled, as a JSON object mapping each PR number to its head commit. It is
+// maintained by the webhook receiver.
+const PullRequestsAnnotation = "gitship.io/pull-requests"
+
+// ConditionSourceMissing is true while the branch or tag an app tracks doesn't
+// exist in the repository, e.g. after the branch was deleted.
+const ConditionSourceMissing = "SourceMissing"
+
+// ConditionCredentialsInvalid is true while the credentials the app's auth
+// method needs are missing or malformed, e.g. the credentials Secret doesn't
+// exist or holds no deploy key.
+const ConditionCredentialsInvalid = "CredentialsInvalid"
+
+// Conditions summarizing each stage of shipping an app, set for the
+// generation in their observedGeneration.
+const (
+	// ConditionSourceResolved is true once the tracked branch, tag or image
+	// resolved to a commit or digest.
+	ConditionSourceResolved = "SourceResolved"
+	// ConditionBuildSucceeded reflects the latest build. It is Unknown while
+	// a build runs.
+	ConditionBuildSucceeded = "BuildSucceeded"
+	// ConditionDeployed is true once the Deployment, Service and Ingress of
+	// the build to run are in place.
+	ConditionDeployed = "Deployed"
+	// ConditionAvailable is true while all desired replicas are ready.
+	ConditionAvailable = "Available"
+	// ConditionIngressReady is true once the app's Ingress has an address.
+	// Apps without ingresses don't have it.
+	ConditionIngressReady = "IngressReady"
+	// ConditionCertificateReady is true once the certificates of all TLS
+	// ingresses are issued. Apps without TLS don't have it.
+	ConditionCertificateReady = "CertificateReady"
+)
+
 // GitshipAppSpec defines the desired state of GitshipApp.
+// +kubebuilder:validation:XValidation:rule="has(self.image) || (has(self.repoUrl) && has(self.imageName))",message="repoUrl and imageName are required unless image is set"
 type GitshipAppSpec struct {
 	// Git Configuration
-	RepoURL string `json:"repoUrl"`
+	// +optional
+	RepoURL string `json:"repoUrl,omitempty"`
+	// URL to clone the repository with the SSH deploy key, e.g.
+	// ssh://git@git.example.com:2222/team/app.git. Derived from repoUrl if empty.
+	// +kubebuilder:validation:Pattern=`^(ssh://([A-Za-z0-9._~-]+@)?[A-Za-z0-9.-]+(:[0-9]+)?/|([A-Za-z0-9._~-]+@)?[A-Za-z0-9.-]+:)[A-Za-z0-9._~/-]+$`
+	// +optional
+	SSHURL string `json:"sshUrl,omitempty"`
 	// Source configuration: branch, tag, or commit
 	// +kubebuilder:default:={type:"branch", value:"main"}
 	Source SourceConfig `json:"source,omitempty"`
-	// Authentication method: "ssh" or "token"
-	// +kubebuilder:default:="token"
+	// Only build commits that change files matching these globs
+	Paths PathFilter `json:"paths,omitempty"`
+	// Authentication method: "ssh" uses only the SSH deploy key, "token" only
+	// the access token, "none" clones anonymously and "auto" tries the deploy
+	// key, the token, then anonymous access. "token" never uses the deploy key
+	// and, without credentialsSecretRef, clones anonymously when there is no
+	// gitship-github-token Secret. Before "auto", "token" was the default and
+	// every method tried the deploy key first.
+	// +kubebuilder:validation:Enum=auto;ssh;token;none
+	// +kubebuilder:default:="auto"
 	AuthMethod string `json:"authMethod,omitempty"`
+	// Name of a Secret holding the deploy key under "ssh-privatekey" and/or the
+	// access token under "token". Defaults to the <app>-ssh-key Secret for the
+	// deploy key and gitship-github-token for the token.
+	CredentialsSecretRef string `json:"credentialsSecretRef,omitempty"`
+
+	// Deploy a prebuilt image from a registry instead of building the repository
+	// +optional
+	Image *ImageSource `json:"image,omitempty"`
 
 	// Build Configuration
-	RegistrySecretRef string `json:"registrySecretRef"` // Name of K8s Secret with docker-creds
-	ImageName         string `json:"imageName"`         // e.g. "ghcr.io/user/image"
+	RegistrySecretRef string `json:"registrySecretRef"`   // Name of K8s Secret with docker-creds
+	ImageName         string `json:"imageName,omitempty"` // e.g. "ghcr.io/user/image"
+	// How the repository is cloned and the Dockerfile, context and arguments passed to Kaniko
+	Build BuildConfig `json:"build,omitempty"`
+	// What happens to a running build when a newer commit arrives: "queue" lets
+	// it finish and deploys it before building the newer commit,
+	// "cancel-in-progress" cancels it, "skip-intermediate" lets it finish but
+	// only deploys the newest commit
+	// +kubebuilder:validation:Enum=queue;cancel-in-progress;skip-intermediate
+	// +kubebuilder:default:="queue"
+	BuildPolicy string `json:"buildPolicy,omitempty"`
 
 	// Run Configuration
 	Ports          []PortConfig        `json:"ports,omitempty"` // Multiple port mappings
@@ -43,6 +111,8 @@ type GitshipAppSpec struct {
 	Replicas       int32               `json:"replicas,omitempty"`
 	Ingresses      []IngressRuleConfig `json:"ingresses,omitempty"` // Multiple domains/paths
 	HealthCheck    HealthCheckConfig   `json:"healthCheck,omitempty"`
+	// How new images are rolled out and rolled back
+	Rollout RolloutConfig `json:"rollout,omitempty"`
 
 	// Storage Configuration
 	Volumes []VolumeConfig `json:"volumes,omitempty"`
@@ -50,6 +120,14 @@ type GitshipAppSpec struct {
 	// Update Strategy
 	// +kubebuilder:default:={type:"polling", interval:"5m"}
 	UpdateStrategy UpdateStrategy `json:"updateStrategy,omitempty"`
+	// Name of a Secret whose "secret" key verifies webhook deliveries for this
+	// app. Deliveries for apps without one are verified with the receiver's
+	// global secret of the provider.
+	WebhookSecretRef string `json:"webhookSecretRef,omitempty"`
+	// known_hosts entries trusted for this app's repository, in addition to the
+	// cluster's gitship-known-hosts ConfigMap. They replace the cluster's
+	// entries for the same hosts.
+	KnownHosts string `json:"knownHosts,omitempty"`
 
 	// TLS Configuration
 	TLS TLSConfig `json:"tls,omitempty"`
@@ -62,6 +140,100 @@ type GitshipAppSpec struct {
 
 	// Token to trigger a manual rebuild. Changing this value forces a new build.
 	RebuildToken string `json:"rebuildToken,omitempty"`
+
+	// Token to cancel the running build. Changing this value cancels it.
+	CancelToken string `json:"cancelToken,omitempty"`
+
+	// Run the image of this previously built commit instead of tracking the
+	// source. No builds run while an app is pinned.
+	PinnedCommit string `json:"pinnedCommit,omitempty"`
+
+	// Deploy a preview of every open pull request
+	Previews PreviewConfig `json:"previews,omitempty"`
+}
+
+type PreviewConfig struct {
+	// Create a GitshipApp named <app>-pr-<number> for each open pull request
+	// against the tracked branch, deleted when the pull request is closed
+	Enabled bool `json:"enabled,omitempty"`
+	// Maximum number of previews running at once
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default:=3
+	MaxPreviews int32 `json:"maxPreviews,omitempty"`
+	// Pass the app's secretRefs, secretMounts and Secret build arguments on to
+	// its previews. Previews run the code of every pull request, including
+	// those from forks, so only enable this if all pull request authors are
+	// trusted with the Secrets.
+	IncludeSecrets bool `json:"includeSecrets,omitempty"`
+}
+
+type PreviewStatus struct {
+	PullRequest int32 `json:"pullRequest"`
+	// Head commit of the pull request
+	HeadCommit string `json:"headCommit,omitempty"`
+	// Preview GitshipApp, empty if it couldn't be created
+	AppName string `json:"appName,omitempty"`
+	URL     string `json:"url,omitempty"`
+	// Why the preview isn't running
+	Message string `json:"message,omitempty"`
+}
+
+type RolloutConfig struct {
+	// "rolling" updates the Deployment in place, "blueGreen" starts the new
+	// image next to the current one and switches the Service over, "canary"
+	// sends a share of the ingress traffic to the new image first
+	// +kubebuilder:validation:Enum=rolling;blueGreen;canary
+	// +kubebuilder:default:="rolling"
+	Strategy string `json:"strategy,omitempty"`
+	// Revert to the last healthy commit when a rolling update fails. Blue/green
+	// and canary rollouts never promote a failing image.
+	// +kubebuilder:default:=true
+	AutoRollback *bool `json:"autoRollback,omitempty"`
+	// Seconds a rollout may go without progress before it is considered failed
+	// +kubebuilder:default:=300
+	ProgressDeadlineSeconds int32 `json:"progressDeadlineSeconds,omitempty"`
+	// Seconds a blue/green or canary image must stay healthy before it is promoted
+	// +kubebuilder:default:=60
+	HealthWindowSeconds int32 `json:"healthWindowSeconds,omitempty"`
+	// Percentage of ingress traffic sent to the canary
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default:=10
+	CanaryWeight int32 `json:"canaryWeight,omitempty"`
+}
+
+type BuildConfig struct {
+	// Path of the Dockerfile, relative to the build context (default "Dockerfile")
+	Dockerfile string `json:"dockerfile,omitempty"`
+	// Subdirectory of the repository used as build context (e.g. "services/api")
+	Context string `json:"context,omitempty"`
+	// Build arguments passed to the Dockerfile
+	Args []BuildArg `json:"args,omitempty"`
+	// Target stage of a multi-stage Dockerfile
+	Target string `json:"target,omitempty"`
+	// Check out the repository's submodules recursively, with the app's credentials
+	Submodules bool `json:"submodules,omitempty"`
+	// Pull Git LFS objects after checkout
+	LFS bool `json:"lfs,omitempty"`
+	// Clone only this many commits of history, 0 for the full history
+	// +kubebuilder:validation:Minimum=0
+	CloneDepth int32 `json:"cloneDepth,omitempty"`
+}
+
+type BuildArg struct {
+	// Name of the build argument
+	Name string `json:"name"`
+	// Literal value of the build argument
+	Value string `json:"value,omitempty"`
+	// Read the value from a Secret key instead of Value
+	SecretRef *SecretKeyRef `json:"secretRef,omitempty"`
+}
+
+type SecretKeyRef struct {
+	// Name of the Kubernetes Secret
+	Name string `json:"name"`
+	// Key within the Secret
+	Key string `json:"key"`
 }
 
 type SecretMountConfig struct {
@@ -122,12 +294,36 @@ type ResourceConfig struct {
 }
 
 type SourceConfig struct {
-	// Type: "branch", "tag", or "commit"
+	// Type: "branch", "tag", "commit", or "pullRequest"
 	Type string `json:"type"`
-	// Value: branch name, tag name, or commit hash
+	// Value: branch name, tag name, pattern (e.g. "v*", the latest matching tag is built) or
+	// semver constraint (e.g. ">=1.2 <2", "~1.4", the highest satisfying version is built),
+	// commit hash, or pull request number
 	Value string `json:"value"`
 }
 
+// ImageSource selects the image of an app that is built outside of Gitship.
+type ImageSource struct {
+	// Image repository without tag, e.g. "ghcr.io/team/app"
+	Repository string `json:"repository"`
+	// Policy: "exact" deploys tag, "semver" the highest tag satisfying the
+	// constraint in tag (e.g. "^1.4"), "digest" deploys digest
+	// +kubebuilder:validation:Enum=exact;semver;digest
+	// +kubebuilder:default:="exact"
+	Policy string `json:"policy,omitempty"`
+	// Tag or semver constraint, "latest" if empty
+	Tag string `json:"tag,omitempty"`
+	// Digest deployed by the "digest" policy, e.g. "sha256:..."
+	Digest string `json:"digest,omitempty"`
+}
+
+type PathFilter struct {
+	// Globs of repository paths that trigger a build (e.g. "services/api/**"). Empty means all paths.
+	Include []string `json:"include,omitempty"`
+	// Globs of repository paths that never trigger a build (e.g. "**/*.md")
+	Exclude []string `json:"exclude,omitempty"`
+}
+
 type TLSConfig struct {
 }
 
@@ -143,12 +339,18 @@ type VolumeConfig struct {
 	MountPath    string `json:"mountPath"`
 	Size         string `json:"size"` // e.g. "1Gi"
 	StorageClass string `json:"storageClass,omitempty"`
+	// DeletionPolicy decides whether the PVC is deleted with the app. A
+	// retained PVC is reused by a new app of the same name.
+	// +kubebuilder:validation:Enum=Retain;Delete
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
 }
 
 type BuildRecord struct {
 	// Commit ID of this build
 	CommitID string `json:"commitId"`
-	// Status: "Succeeded", "Failed"
+	// Status: "Succeeded", "Failed", "Skipped", "Cancelled"
 	Status string `json:"status"`
 	// When the build started
 	StartTime string `json:"startTime,omitempty"`
@@ -156,16 +358,25 @@ type BuildRecord struct {
 	CompletionTime string `json:"completionTime,omitempty"`
 	// Optional log summary or reference
 	Message string `json:"message,omitempty"`
+	// GitshipBuild holding the logs of this build
+	BuildName string `json:"buildName,omitempty"`
+	// Git tag the commit was built for, also used as its image tag
+	Tag string `json:"tag,omitempty"`
+	// Digest of the pushed image, deployments pin it
+	Digest string `json:"digest,omitempty"`
 }
 
 // GitshipAppStatus defines the observed state of GitshipApp.
 type GitshipAppStatus struct {
 	LatestBuildID string `json:"latestBuildId"`
-	Phase         string `json:"phase"` // "Building", "Running", "Failed"
+	Phase         string `json:"phase"` // "Building", "Running", "Failed", "RolledBack"
 	AppURL        string `json:"appUrl,omitempty"`
 
 	BuildHistory []BuildRecord `json:"buildHistory,omitempty"`
 
+	// Project type detected by the last successful build: "dockerfile", "node", "python" or "go"
+	DetectedStack string `json:"detectedStack,omitempty"`
+
 	// Enhanced status fields (Phase 9)
 	ReadyReplicas   int32  `json:"readyReplicas,omitempty"`
 	DesiredReplicas int32  `json:"desiredReplicas,omitempty"`
@@ -176,10 +387,71 @@ type GitshipAppStatus struct {
 
 	// Tracks the last processed rebuild token
 	LatestRebuildToken string `json:"latestRebuildToken,omitempty"`
+
+	// Tracks the last processed cancel token
+	LatestCancelToken string `json:"latestCancelToken,omitempty"`
+
+	// Last commit whose rollout became fully available
+	LastHealthyCommit string `json:"lastHealthyCommit,omitempty"`
+	// Commit whose failed rollout was reverted, cleared by the next successful build
+	RolledBackCommit string `json:"rolledBackCommit,omitempty"`
+	// Why the rollout of RolledBackCommit was reverted
+	RollbackReason string `json:"rollbackReason,omitempty"`
+	// Blue/green slot receiving traffic: "blue" (<app>) or "green" (<app>-green)
+	ActiveSlot string `json:"activeSlot,omitempty"`
+	// Commit being verified by a blue/green or canary rollout
+	CandidateCommit string `json:"candidateCommit,omitempty"`
+	// When the candidate became healthy, it is promoted after the health window
+	CandidateHealthySince string `json:"candidateHealthySince,omitempty"`
+
+	// Commit the app is pinned to, set once its image was found in the registry
+	PinnedCommit string `json:"pinnedCommit,omitempty"`
+	// Why spec.pinnedCommit can't be deployed
+	PinError string `json:"pinError,omitempty"`
+	// Latest commit of the tracked source while pinned
+	TrackedCommit string `json:"trackedCommit,omitempty"`
+	// Commits on the tracked source since the pinned commit
+	CommitsBehind int32 `json:"commitsBehind,omitempty"`
+
+	// Hash of the build configuration used by the last successful build
+	BuildConfigHash string `json:"buildConfigHash,omitempty"`
+
+	// Latest commit that was not built because no file matching the path filters changed
+	SkippedCommit string `json:"skippedCommit,omitempty"`
+	// Latest commit that needs a build because a file matching the path filters changed
+	PathsMatchedCommit string `json:"pathsMatchedCommit,omitempty"`
+
+	// Tag a tag or image source currently resolves to, e.g. the highest version satisfying its semver constraint
+	ResolvedTag string `json:"resolvedTag,omitempty"`
+
+	// Branch the repository's HEAD points to, tracked by branch sources without a value or "HEAD"
+	DefaultBranch string `json:"defaultBranch,omitempty"`
+
+	// How the controller last reached the repository: "ssh", "token" or "anonymous"
+	AuthMethod string `json:"authMethod,omitempty"`
+
+	// Previews of the open pull requests
+	Previews []PreviewStatus `json:"previews,omitempty"`
+
+	// Generation of the spec last acted on by a build or deployment
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions: SourceResolved, BuildSucceeded, Deployed, Available,
+	// IngressReady, CertificateReady, SourceMissing, CredentialsInvalid
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Available",type=string,JSONPath=`.status.conditions[?(@.type=="Available")].status`
+// +kubebuilder:printcolumn:name="Commit",type=string,JSONPath=`.status.latestBuildId`,priority=1
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.appUrl`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:validation:XValidation:rule="!self.metadata.name.endsWith('-green') && !self.metadata.name.endsWith('-canary')",message="app names can't end in -green or -canary, which name the rollout Deployments of other apps"
 
 // GitshipApp is the Schema for the gitshipapps API.
 type GitshipApp struct {
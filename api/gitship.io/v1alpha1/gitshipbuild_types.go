@@ -0,0 +1,97 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GitshipBuildSpec describes a single build of a GitshipApp.
+type GitshipBuildSpec struct {
+	// Name of the GitshipApp being built
+	AppName string `json:"appName"`
+	// Commit being built
+	CommitID string `json:"commitId"`
+	// Image pushed by the build
+	Image string `json:"image,omitempty"`
+	// Name of the Kubernetes Job running the build
+	JobName string `json:"jobName"`
+	// Rebuild token that triggered this build, if any
+	RebuildToken string `json:"rebuildToken,omitempty"`
+	// Hash of the app's build configuration at the time of the build
+	BuildConfigHash string `json:"buildConfigHash,omitempty"`
+	// Git tag being built, the image is also pushed under it
+	Tag string `json:"tag,omitempty"`
+}
+
+type ContainerLog struct {
+	// Build container: "git-clone", "detect" or "kaniko"
+	Container string `json:"container"`
+	// Last lines of the container output
+	Log string `json:"log,omitempty"`
+}
+
+// GitshipBuildStatus defines the observed state of GitshipBuild.
+type GitshipBuildStatus struct {
+	// "Running", "Succeeded", "Failed", "Cancelled"
+	Phase string `json:"phase,omitempty"`
+	// When the build Job was created
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// When the build Job finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Container that made the build fail
+	FailedContainer string `json:"failedContainer,omitempty"`
+	// Exit code of the failed container
+	ExitCode int32 `json:"exitCode,omitempty"`
+	// Termination message or reason of the failed container
+	Message string `json:"message,omitempty"`
+	// Project type reported by the detect step
+	DetectedStack string `json:"detectedStack,omitempty"`
+	// Digest of the pushed image, reported by Kaniko
+	Digest string `json:"digest,omitempty"`
+	// Tail of each build container's logs, captured when the build finished
+	Logs []ContainerLog `json:"logs,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="App",type=string,JSONPath=`.spec.appName`
+// +kubebuilder:printcolumn:name="Commit",type=string,JSONPath=`.spec.commitId`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// GitshipBuild is the Schema for the gitshipbuilds API.
+type GitshipBuild struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GitshipBuildSpec   `json:"spec,omitempty"`
+	Status GitshipBuildStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GitshipBuildList contains a list of GitshipBuild.
+type GitshipBuildList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GitshipBuild `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GitshipBuild{}, &GitshipBuildList{})
+}
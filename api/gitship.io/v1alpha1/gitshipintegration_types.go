@@ -26,7 +26,8 @@ type GitshipIntegrationSpec struct {
 	Type string `json:"type"`
 
 	// Configuration for the integration
-	// For cloudflare-tunnel: {"token": "...", "apps": ["myapp"]}
+	// For cloudflare-tunnel: {"token": "...", "apps": ["myapp"]}, plus an optional
+	// "apiToken" allowed to edit the tunnel, used to remove deleted apps' routes
 	Config map[string]string `json:"config,omitempty"`
 
 	// Resource limits/requests
@@ -25,6 +25,48 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BuildArg) DeepCopyInto(out *BuildArg) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuildArg.
+func (in *BuildArg) DeepCopy() *BuildArg {
+	if in == nil {
+		return nil
+	}
+	out := new(BuildArg)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BuildConfig) DeepCopyInto(out *BuildConfig) {
+	*out = *in
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]BuildArg, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuildConfig.
+func (in *BuildConfig) DeepCopy() *BuildConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(BuildConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BuildRecord) DeepCopyInto(out *BuildRecord) {
 	*out = *in
@@ -40,6 +82,21 @@ func (in *BuildRecord) DeepCopy() *BuildRecord {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerLog) DeepCopyInto(out *ContainerLog) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerLog.
+func (in *ContainerLog) DeepCopy() *ContainerLog {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerLog)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitshipApp) DeepCopyInto(out *GitshipApp) {
 	*out = *in
@@ -103,6 +160,13 @@ func (in *GitshipAppList) DeepCopyObject() runtime.Object {
 func (in *GitshipAppSpec) DeepCopyInto(out *GitshipAppSpec) {
 	*out = *in
 	out.Source = in.Source
+	in.Paths.DeepCopyInto(&out.Paths)
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(ImageSource)
+		**out = **in
+	}
+	in.Build.DeepCopyInto(&out.Build)
 	if in.Ports != nil {
 		in, out := &in.Ports, &out.Ports
 		*out = make([]PortConfig, len(*in))
@@ -123,6 +187,7 @@ func (in *GitshipAppSpec) DeepCopyInto(out *GitshipAppSpec) {
 		copy(*out, *in)
 	}
 	out.HealthCheck = in.HealthCheck
+	in.Rollout.DeepCopyInto(&out.Rollout)
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
 		*out = make([]VolumeConfig, len(*in))
@@ -140,6 +205,7 @@ func (in *GitshipAppSpec) DeepCopyInto(out *GitshipAppSpec) {
 		*out = make([]SecretMountConfig, len(*in))
 		copy(*out, *in)
 	}
+	out.Previews = in.Previews
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitshipAppSpec.
@@ -160,6 +226,18 @@ func (in *GitshipAppStatus) DeepCopyInto(out *GitshipAppStatus) {
 		*out = make([]BuildRecord, len(*in))
 		copy(*out, *in)
 	}
+	if in.Previews != nil {
+		in, out := &in.Previews, &out.Previews
+		*out = make([]PreviewStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitshipAppStatus.
@@ -172,6 +250,108 @@ func (in *GitshipAppStatus) DeepCopy() *GitshipAppStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitshipBuild) DeepCopyInto(out *GitshipBuild) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitshipBuild.
+func (in *GitshipBuild) DeepCopy() *GitshipBuild {
+	if in == nil {
+		return nil
+	}
+	out := new(GitshipBuild)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GitshipBuild) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitshipBuildList) DeepCopyInto(out *GitshipBuildList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GitshipBuild, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitshipBuildList.
+func (in *GitshipBuildList) DeepCopy() *GitshipBuildList {
+	if in == nil {
+		return nil
+	}
+	out := new(GitshipBuildList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GitshipBuildList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitshipBuildSpec) DeepCopyInto(out *GitshipBuildSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitshipBuildSpec.
+func (in *GitshipBuildSpec) DeepCopy() *GitshipBuildSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GitshipBuildSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitshipBuildStatus) DeepCopyInto(out *GitshipBuildStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Logs != nil {
+		in, out := &in.Logs, &out.Logs
+		*out = make([]ContainerLog, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitshipBuildStatus.
+func (in *GitshipBuildStatus) DeepCopy() *GitshipBuildStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GitshipBuildStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitshipIntegration) DeepCopyInto(out *GitshipIntegration) {
 	*out = *in
@@ -384,6 +564,21 @@ func (in *HealthCheckConfig) DeepCopy() *HealthCheckConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageSource) DeepCopyInto(out *ImageSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSource.
+func (in *ImageSource) DeepCopy() *ImageSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IngressRuleConfig) DeepCopyInto(out *IngressRuleConfig) {
 	*out = *in
@@ -399,6 +594,31 @@ func (in *IngressRuleConfig) DeepCopy() *IngressRuleConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PathFilter) DeepCopyInto(out *PathFilter) {
+	*out = *in
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PathFilter.
+func (in *PathFilter) DeepCopy() *PathFilter {
+	if in == nil {
+		return nil
+	}
+	out := new(PathFilter)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PortConfig) DeepCopyInto(out *PortConfig) {
 	*out = *in
@@ -414,6 +634,36 @@ func (in *PortConfig) DeepCopy() *PortConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreviewConfig) DeepCopyInto(out *PreviewConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreviewConfig.
+func (in *PreviewConfig) DeepCopy() *PreviewConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PreviewConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreviewStatus) DeepCopyInto(out *PreviewStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreviewStatus.
+func (in *PreviewStatus) DeepCopy() *PreviewStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PreviewStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RegistryConfig) DeepCopyInto(out *RegistryConfig) {
 	*out = *in
@@ -444,6 +694,41 @@ func (in *ResourceConfig) DeepCopy() *ResourceConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutConfig) DeepCopyInto(out *RolloutConfig) {
+	*out = *in
+	if in.AutoRollback != nil {
+		in, out := &in.AutoRollback, &out.AutoRollback
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutConfig.
+func (in *RolloutConfig) DeepCopy() *RolloutConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyRef) DeepCopyInto(out *SecretKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyRef.
+func (in *SecretKeyRef) DeepCopy() *SecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretMountConfig) DeepCopyInto(out *SecretMountConfig) {
 	*out = *in
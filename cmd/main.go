@@ -23,6 +23,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -30,6 +32,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
@@ -42,6 +45,7 @@ import (
 	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
 	gitshipiocontroller "github.com/gitshipio/gitship/internal/controller/gitship.io"
+	"github.com/gitshipio/gitship/internal/githubapp"
 	gitshipwebhook "github.com/gitshipio/gitship/internal/webhook"
 	// +kubebuilder:scaffold:imports
 )
@@ -118,6 +122,15 @@ func main() {
 		ImageGit:            getEnv("IMAGE_GIT", "alpine/git"),
 		ImageKaniko:         getEnv("IMAGE_KANIKO", "gcr.io/kaniko-project/executor:latest"),
 	}
+	var err error
+	if config.RegistryRetainBuilds, err = strconv.Atoi(getEnv("REGISTRY_RETAIN_BUILDS", "10")); err != nil {
+		setupLog.Error(err, "invalid REGISTRY_RETAIN_BUILDS")
+		os.Exit(1)
+	}
+	if config.RegistryGCInterval, err = time.ParseDuration(getEnv("REGISTRY_GC_INTERVAL", "1h")); err != nil {
+		setupLog.Error(err, "invalid REGISTRY_GC_INTERVAL")
+		os.Exit(1)
+	}
 
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
@@ -234,10 +247,18 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "GitshipUser")
 		os.Exit(1)
 	}
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create clientset")
+		os.Exit(1)
+	}
+
 	if err := (&gitshipiocontroller.GitshipAppReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Config: config,
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Config:    config,
+		Clientset: clientset,
+		GitHubApp: githubapp.NewClient(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "GitshipApp")
 		os.Exit(1)
@@ -283,7 +304,16 @@ func main() {
 	// Start a separate HTTP server for the webhook to avoid TLS headache locally
 	go func() {
 		setupLog.Info("Starting Webhook HTTP Server on :3001")
-		http.Handle("/api/webhooks/github", &gitshipwebhook.Receiver{Client: mgr.GetClient()})
+		// Keep the last 200 deliveries and reject redeliveries within an hour
+		deliveries := gitshipwebhook.NewDeliveryLog(200, time.Hour)
+		for _, provider := range gitshipwebhook.Providers {
+			http.Handle("/api/webhooks/"+string(provider), &gitshipwebhook.Receiver{
+				Client:     mgr.GetClient(),
+				Provider:   provider,
+				Deliveries: deliveries,
+			})
+		}
+		http.Handle("/api/webhooks/deliveries", deliveries)
 		if err := http.ListenAndServe(":3001", nil); err != nil {
 			setupLog.Error(err, "Failed to start webhook server")
 		}
@@ -0,0 +1,14 @@
+package cloudflare
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCloudflare(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Cloudflare Suite")
+}
@@ -0,0 +1,152 @@
+package cloudflare
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the Cloudflare API.
+const DefaultBaseURL = "https://api.cloudflare.com/client/v4"
+
+// catchAllService answers requests matching no other rule of a tunnel whose
+// catch-all rule was removed, as cloudflared requires one.
+const catchAllService = "http_status:404"
+
+// Tunnel identifies a Cloudflare tunnel.
+type Tunnel struct {
+	AccountID string
+	ID        string
+}
+
+// ParseTunnelToken reads which tunnel the token cloudflared runs with belongs to.
+func ParseTunnelToken(token string) (Tunnel, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(token))
+	if err != nil {
+		return Tunnel{}, errors.New("tunnel token is not base64 encoded")
+	}
+	var claims struct {
+		AccountID string `json:"a"`
+		TunnelID  string `json:"t"`
+	}
+	if err := json.Unmarshal(data, &claims); err != nil || claims.AccountID == "" || claims.TunnelID == "" {
+		return Tunnel{}, errors.New("tunnel token names no account and tunnel")
+	}
+	return Tunnel{AccountID: claims.AccountID, ID: claims.TunnelID}, nil
+}
+
+// Client edits the configuration of remotely managed tunnels.
+type Client struct {
+	HTTP    *http.Client
+	BaseURL string
+	// API token allowed to edit the account's tunnels
+	Token string
+}
+
+// NewClient returns a Client of the Cloudflare API authenticated with token.
+func NewClient(token string) *Client {
+	return &Client{
+		HTTP:    &http.Client{Timeout: 15 * time.Second},
+		BaseURL: DefaultBaseURL,
+		Token:   token,
+	}
+}
+
+// RemoveRoutes removes the ingress rules of the tunnel's configuration whose
+// service matches, e.g. http://web:8080, and returns how many were removed.
+// A matching catch-all rule is made to answer 404 instead. Tunnels managed
+// by a local configuration file have no rules to remove.
+func (c *Client) RemoveRoutes(ctx context.Context, tunnel Tunnel, match func(service string) bool) (int, error) {
+	url := fmt.Sprintf("%s/accounts/%s/cfd_tunnel/%s/configurations", c.BaseURL, tunnel.AccountID, tunnel.ID)
+	var current struct {
+		// Other settings are written back as they are
+		Config map[string]json.RawMessage `json:"config"`
+	}
+	if err := c.do(ctx, http.MethodGet, url, nil, &current); err != nil {
+		return 0, err
+	}
+	var rules []map[string]any
+	if raw, ok := current.Config["ingress"]; ok {
+		if err := json.Unmarshal(raw, &rules); err != nil {
+			return 0, fmt.Errorf("ingress rules of tunnel %s: %w", tunnel.ID, err)
+		}
+	}
+
+	removed := 0
+	kept := make([]map[string]any, 0, len(rules))
+	for i, rule := range rules {
+		service, _ := rule["service"].(string)
+		if !match(service) {
+			kept = append(kept, rule)
+			continue
+		}
+		removed++
+		if i == len(rules)-1 {
+			kept = append(kept, map[string]any{"service": catchAllService})
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	ingress, err := json.Marshal(kept)
+	if err != nil {
+		return 0, err
+	}
+	current.Config["ingress"] = ingress
+	return removed, c.do(ctx, http.MethodPut, url, map[string]any{"config": current.Config}, nil)
+}
+
+// do sends a request to the API and decodes the result of the response into
+// out, unless out is nil.
+func (c *Client) do(ctx context.Context, method, url string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&envelope); err != nil {
+		return fmt.Errorf("%s %s: %s", method, url, resp.Status)
+	}
+	if !envelope.Success {
+		messages := make([]string, 0, len(envelope.Errors))
+		for _, e := range envelope.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, strings.Join(messages, "; "))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(envelope.Result, out)
+}
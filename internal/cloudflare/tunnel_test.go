@@ -0,0 +1,81 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tunnel", func() {
+	It("reads the account and tunnel of a tunnel token", func() {
+		token := base64.StdEncoding.EncodeToString([]byte(`{"a":"account","t":"tunnel","s":"secret"}`))
+		tunnel, err := ParseTunnelToken(token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tunnel).To(Equal(Tunnel{AccountID: "account", ID: "tunnel"}))
+
+		_, err = ParseTunnelToken("not a token")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseTunnelToken(base64.StdEncoding.EncodeToString([]byte(`{}`)))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("removes the matching routes of a tunnel", func() {
+		var written map[string]any
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /accounts/account/cfd_tunnel/tunnel/configurations", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Authorization")).To(Equal("Bearer cf_api"))
+			_, _ = fmt.Fprint(w, `{"success":true,"result":{"config":{"warp-routing":{"enabled":false},"ingress":[
+				{"hostname":"web.example.com","service":"http://web:8080"},
+				{"hostname":"api.example.com","service":"http://api:80"},
+				{"service":"http://web:8080"}]}}}`)
+		})
+		mux.HandleFunc("PUT /accounts/account/cfd_tunnel/tunnel/configurations", func(w http.ResponseWriter, r *http.Request) {
+			Expect(json.NewDecoder(r.Body).Decode(&written)).To(Succeed())
+			_, _ = fmt.Fprint(w, `{"success":true,"result":{}}`)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		client := NewClient("cf_api")
+		client.BaseURL = server.URL
+		tunnel := Tunnel{AccountID: "account", ID: "tunnel"}
+		n, err := client.RemoveRoutes(context.Background(), tunnel, func(service string) bool {
+			return strings.HasPrefix(service, "http://web:")
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(2))
+		Expect(written).To(Equal(map[string]any{"config": map[string]any{
+			"warp-routing": map[string]any{"enabled": false},
+			"ingress": []any{
+				map[string]any{"hostname": "api.example.com", "service": "http://api:80"},
+				map[string]any{"service": "http_status:404"},
+			},
+		}}))
+
+		written = nil
+		n, err = client.RemoveRoutes(context.Background(), tunnel, func(string) bool { return false })
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(BeZero())
+		Expect(written).To(BeNil())
+	})
+
+	It("reports API errors", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = fmt.Fprint(w, `{"success":false,"errors":[{"code":10000,"message":"Authentication error"}]}`)
+		}))
+		defer server.Close()
+
+		client := NewClient("cf_api")
+		client.BaseURL = server.URL
+		_, err := client.RemoveRoutes(context.Background(), Tunnel{AccountID: "account", ID: "tunnel"}, func(string) bool { return true })
+		Expect(err).To(MatchError(ContainSubstring("Authentication error")))
+	})
+})
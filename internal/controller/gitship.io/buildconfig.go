@@ -0,0 +1,85 @@
+package gitshipio
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+const defaultDockerfile = "Dockerfile"
+
+// buildPaths resolves the absolute build context and Dockerfile paths inside
+// the build pod. Both are confined to /workspace.
+func buildPaths(build gitshipiov1alpha1.BuildConfig) (contextDir, dockerfilePath string) {
+	contextDir = path.Join("/workspace", cleanBuildPath(build.Context))
+	dockerfile := build.Dockerfile
+	if dockerfile == "" {
+		dockerfile = defaultDockerfile
+	}
+	return contextDir, path.Join(contextDir, cleanBuildPath(dockerfile))
+}
+
+// cleanBuildPath turns a user supplied path into a relative one without any
+// ".." components.
+func cleanBuildPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// kanikoBuildArgs returns the Kaniko flags for the build configuration along
+// with the env vars that carry Secret-sourced build arguments.
+func kanikoBuildArgs(build gitshipiov1alpha1.BuildConfig) ([]string, []corev1.EnvVar) {
+	contextDir, dockerfilePath := buildPaths(build)
+	args := []string{
+		"--dockerfile=" + dockerfilePath,
+		"--context=dir://" + contextDir,
+	}
+	if build.Target != "" {
+		args = append(args, "--target="+build.Target)
+	}
+
+	var env []corev1.EnvVar
+	for i, arg := range build.Args {
+		if arg.SecretRef == nil {
+			// Escape $(...) so Kubernetes doesn't try to expand it
+			args = append(args, fmt.Sprintf("--build-arg=%s=%s", arg.Name, strings.ReplaceAll(arg.Value, "$(", "$$(")))
+			continue
+		}
+		envName := fmt.Sprintf("BUILD_ARG_%d", i)
+		env = append(env, corev1.EnvVar{
+			Name: envName,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: arg.SecretRef.Name},
+					Key:                  arg.SecretRef.Key,
+				},
+			},
+		})
+		args = append(args, fmt.Sprintf("--build-arg=%s=$(%s)", arg.Name, envName))
+	}
+	return args, env
+}
+
+// buildConfigHash fingerprints the build configuration so that changing it
+// triggers a new build. The default configuration hashes to "" to keep apps
+// created before the build section existed from rebuilding. Values read from
+// Secrets are not part of the hash.
+func buildConfigHash(build gitshipiov1alpha1.BuildConfig) string {
+	if build.Dockerfile == defaultDockerfile {
+		build.Dockerfile = ""
+	}
+	build.Context = cleanBuildPath(build.Context)
+	if build.Dockerfile == "" && build.Context == "" && build.Target == "" && len(build.Args) == 0 &&
+		!build.Submodules && !build.LFS && build.CloneDepth == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(build)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,47 @@
+package gitshipio
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+func TestBuildPathsStayInWorkspace(t *testing.T) {
+	g := NewWithT(t)
+	contextDir, dockerfile := buildPaths(gitshipiov1alpha1.BuildConfig{
+		Context:    "../services/api",
+		Dockerfile: "docker/Dockerfile.prod",
+	})
+	g.Expect(contextDir).To(Equal("/workspace/services/api"))
+	g.Expect(dockerfile).To(Equal("/workspace/services/api/docker/Dockerfile.prod"))
+}
+
+func TestKanikoBuildArgsFromSecrets(t *testing.T) {
+	g := NewWithT(t)
+	args, env := kanikoBuildArgs(gitshipiov1alpha1.BuildConfig{
+		Target: "runtime",
+		Args: []gitshipiov1alpha1.BuildArg{
+			{Name: "VERSION", Value: "1.0"},
+			{Name: "NPM_TOKEN", SecretRef: &gitshipiov1alpha1.SecretKeyRef{Name: "npm", Key: "token"}},
+		},
+	})
+	g.Expect(args).To(ContainElements(
+		"--dockerfile=/workspace/Dockerfile",
+		"--context=dir:///workspace",
+		"--target=runtime",
+		"--build-arg=VERSION=1.0",
+		"--build-arg=NPM_TOKEN=$(BUILD_ARG_1)",
+	))
+	g.Expect(env).To(HaveLen(1))
+	g.Expect(env[0].Name).To(Equal("BUILD_ARG_1"))
+	g.Expect(env[0].ValueFrom.SecretKeyRef.Name).To(Equal("npm"))
+}
+
+func TestBuildConfigHashOfDefaults(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(buildConfigHash(gitshipiov1alpha1.BuildConfig{})).To(BeEmpty())
+	g.Expect(buildConfigHash(gitshipiov1alpha1.BuildConfig{Dockerfile: "Dockerfile", Context: "."})).To(BeEmpty())
+	g.Expect(buildConfigHash(gitshipiov1alpha1.BuildConfig{Target: "prod"})).NotTo(BeEmpty())
+}
@@ -0,0 +1,113 @@
+package gitshipio
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+// Stacks reported by the detect init container through its termination message.
+const (
+	stackDockerfile = "dockerfile"
+	stackNode       = "node"
+	stackPython     = "python"
+	stackGo         = "go"
+)
+
+const detectContainerName = "detect"
+
+// buildpackTemplates are the Dockerfiles generated for repositories that don't
+// ship their own. All of them listen on $PORT (8080), the default container port.
+var buildpackTemplates = map[string]string{
+	stackNode: `FROM node:20-alpine
+WORKDIR /app
+COPY package*.json ./
+RUN if [ -f package-lock.json ]; then npm ci; else npm install; fi
+COPY . .
+RUN npm run build --if-present
+ENV NODE_ENV=production PORT=8080
+EXPOSE 8080
+CMD ["npm", "start"]
+`,
+	stackPython: `FROM python:3.12-slim
+WORKDIR /app
+COPY . .
+RUN if [ -f requirements.txt ]; then pip install --no-cache-dir -r requirements.txt; else pip install --no-cache-dir .; fi
+ENV PYTHONUNBUFFERED=1 PORT=8080
+EXPOSE 8080
+CMD ["sh", "-c", "if [ -f main.py ]; then exec python main.py; else exec python app.py; fi"]
+`,
+	stackGo: `FROM golang:1.24-alpine AS build
+WORKDIR /src
+COPY go.* ./
+RUN go mod download
+COPY . .
+RUN CGO_ENABLED=0 go build -o /out/app .
+
+FROM alpine:3.20
+COPY --from=build /out/app /usr/local/bin/app
+ENV PORT=8080
+EXPOSE 8080
+ENTRYPOINT ["/usr/local/bin/app"]
+`,
+}
+
+// detectScript inspects the build context, writes a Dockerfile from the
+// matching template when the repository has none, and reports the detected
+// stack through the container termination message. An explicitly configured
+// Dockerfile is never generated.
+const detectScript = `
+	if ! cd "$BUILD_CONTEXT"; then
+		echo "Build context $BUILD_CONTEXT not found in repository" | tee /dev/termination-log
+		exit 1
+	fi
+	if [ -f "$DOCKERFILE_PATH" ]; then
+		STACK=dockerfile
+	elif [ -n "$DOCKERFILE_REQUIRED" ]; then
+		echo "Dockerfile $DOCKERFILE_PATH not found in repository" | tee /dev/termination-log
+		exit 1
+	elif [ -f package.json ]; then
+		STACK=node
+	elif [ -f requirements.txt ] || [ -f pyproject.toml ]; then
+		STACK=python
+	elif [ -f go.mod ]; then
+		STACK=go
+	else
+		echo "No Dockerfile found and no supported project detected (package.json, requirements.txt, pyproject.toml, go.mod)" | tee /dev/termination-log
+		exit 1
+	fi
+	case "$STACK" in
+		node) printf '%s' "$DOCKERFILE_NODE" > "$DOCKERFILE_PATH" ;;
+		python) printf '%s' "$DOCKERFILE_PYTHON" > "$DOCKERFILE_PATH" ;;
+		go) printf '%s' "$DOCKERFILE_GO" > "$DOCKERFILE_PATH" ;;
+	esac
+	echo "Detected stack: $STACK"
+	printf '%s' "$STACK" > /dev/termination-log
+`
+
+// detectContainer returns the init container that runs between git-clone and Kaniko.
+func (r *GitshipAppReconciler) detectContainer(build gitshipiov1alpha1.BuildConfig, volumeMounts []corev1.VolumeMount, resources corev1.ResourceRequirements) corev1.Container {
+	contextDir, dockerfilePath := buildPaths(build)
+	env := []corev1.EnvVar{
+		{Name: "BUILD_CONTEXT", Value: contextDir},
+		{Name: "DOCKERFILE_PATH", Value: dockerfilePath},
+	}
+	if build.Dockerfile != "" && build.Dockerfile != defaultDockerfile {
+		env = append(env, corev1.EnvVar{Name: "DOCKERFILE_REQUIRED", Value: "true"})
+	}
+	for _, stack := range []string{stackNode, stackPython, stackGo} {
+		env = append(env, corev1.EnvVar{
+			Name:  fmt.Sprintf("DOCKERFILE_%s", strings.ToUpper(stack)),
+			Value: buildpackTemplates[stack],
+		})
+	}
+	return corev1.Container{
+		Name: detectContainerName, Image: r.Config.ImageGit,
+		Command:      []string{"/bin/sh", "-c", detectScript},
+		Env:          env,
+		VolumeMounts: volumeMounts, Resources: resources,
+	}
+}
@@ -0,0 +1,350 @@
+package gitshipio
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+const (
+	buildPhaseRunning   = "Running"
+	buildPhaseSucceeded = "Succeeded"
+	buildPhaseFailed    = "Failed"
+	buildPhaseCancelled = "Cancelled"
+
+	// GitshipBuilds kept per app, matching the length of BuildHistory
+	maxBuildRuns = 10
+
+	buildLogTailLines  = 100
+	buildLogLimitBytes = 16 * 1024
+
+	// kanikoContainerName builds and pushes the image
+	kanikoContainerName = "kaniko"
+
+	// buildJobGracePeriod is how long a build's Job may be missing from the
+	// cache after the build was recorded before the build counts as failed
+	buildJobGracePeriod = 2 * time.Minute
+)
+
+// Build policies, see GitshipAppSpec.BuildPolicy.
+const (
+	buildPolicyCancelInProgress = "cancel-in-progress"
+	buildPolicySkipIntermediate = "skip-intermediate"
+)
+
+// createBuildRun records a new build. It is created before the Job so that a
+// build is never started without a record.
+func (r *GitshipAppReconciler) createBuildRun(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, jobName, commit, image string, isRebuild bool) error {
+	build := &gitshipiov1alpha1.GitshipBuild{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: app.Namespace,
+			Labels:    map[string]string{"gitship.io/app": app.Name, "gitship.io/commit": commit},
+		},
+		Spec: gitshipiov1alpha1.GitshipBuildSpec{
+			AppName:         app.Name,
+			CommitID:        commit,
+			Image:           image,
+			JobName:         jobName,
+			BuildConfigHash: buildConfigHash(app.Spec.Build),
+			Tag:             app.Status.ResolvedTag,
+		},
+	}
+	if isRebuild {
+		build.Spec.RebuildToken = app.Spec.RebuildToken
+	}
+	if err := ctrl.SetControllerReference(app, build, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, build); err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	build.Status.Phase = buildPhaseRunning
+	build.Status.StartTime = &now
+	return r.Status().Update(ctx, build)
+}
+
+// syncBuildRuns finalizes the app's running builds whose Job has finished. The
+// outcome and log tails are captured before the Job is garbage collected, the
+// result is added to the build history and successful builds are promoted to
+// LatestBuildID.
+func (r *GitshipAppReconciler) syncBuildRuns(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) error {
+	builds := &gitshipiov1alpha1.GitshipBuildList{}
+	if err := r.List(ctx, builds, client.InNamespace(app.Namespace), client.MatchingLabels{"gitship.io/app": app.Name}); err != nil {
+		return err
+	}
+
+	appChanged := false
+	for i := range builds.Items {
+		build := &builds.Items[i]
+		if build.Status.Phase != "" && build.Status.Phase != buildPhaseRunning {
+			continue
+		}
+
+		job := &batchv1.Job{}
+		err := r.Get(ctx, types.NamespacedName{Name: build.Spec.JobName, Namespace: app.Namespace}, job)
+		switch {
+		case err != nil && client.IgnoreNotFound(err) != nil:
+			return err
+		case err != nil:
+			// The Job is created right after the build, the cache may not have seen it yet
+			started := build.CreationTimestamp.Time
+			if build.Status.StartTime != nil {
+				started = build.Status.StartTime.Time
+			}
+			if time.Since(started) < buildJobGracePeriod {
+				continue
+			}
+			build.Status.Phase = buildPhaseFailed
+			build.Status.Message = "Build job not found"
+		case jobPhase(job) != "":
+			build.Status.Phase = jobPhase(job)
+		default:
+			continue
+		}
+
+		log.Info("Build finished", "build", build.Name, "phase", build.Status.Phase)
+		r.captureBuildResult(ctx, build)
+		now := metav1.Now()
+		build.Status.CompletionTime = &now
+		if err := r.Status().Update(ctx, build); err != nil {
+			return err
+		}
+		r.applyBuildResult(app, build)
+		appChanged = true
+	}
+
+	if appChanged {
+		if err := r.Status().Update(ctx, app); err != nil {
+			return err
+		}
+	}
+
+	r.pruneBuildRuns(ctx, builds.Items)
+	return nil
+}
+
+// jobPhase returns the phase of a build whose Job has finished, "" while the
+// Job is running. A failed pod doesn't finish a Job that still retries it.
+func jobPhase(job *batchv1.Job) string {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return buildPhaseSucceeded
+		case batchv1.JobFailed:
+			return buildPhaseFailed
+		}
+	}
+	return ""
+}
+
+// cancelBuildRuns stops the app's running build Jobs and records them as
+// Cancelled. The app status is updated by the caller.
+func (r *GitshipAppReconciler) cancelBuildRuns(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, reason string) (int, error) {
+	jobs := &batchv1.JobList{}
+	if err := r.List(ctx, jobs, client.InNamespace(app.Namespace), client.MatchingLabels{"gitship.io/app": app.Name}); err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if jobPhase(job) != "" {
+			continue
+		}
+		if err := r.cancelBuildRun(ctx, app, job, reason); err != nil {
+			return cancelled, err
+		}
+		cancelled++
+	}
+	return cancelled, nil
+}
+
+// cancelBuildRun captures what the build has logged so far and deletes its Job.
+func (r *GitshipAppReconciler) cancelBuildRun(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, job *batchv1.Job, reason string) error {
+	log.Info("Cancelling build", "job", job.Name, "reason", reason)
+
+	build := &gitshipiov1alpha1.GitshipBuild{}
+	err := r.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, build)
+	if err != nil && client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	hasBuild := err == nil
+	if hasBuild {
+		r.captureBuildResult(ctx, build)
+	}
+
+	if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+
+	if !hasBuild {
+		// Job started before builds were recorded as GitshipBuilds
+		r.recordBuild(app, job.Labels["gitship.io/commit"], buildPhaseCancelled, reason)
+		return nil
+	}
+
+	now := metav1.Now()
+	build.Status.Phase = buildPhaseCancelled
+	build.Status.Message = reason
+	build.Status.CompletionTime = &now
+	if err := r.Status().Update(ctx, build); err != nil {
+		return err
+	}
+	r.applyBuildResult(app, build)
+	return nil
+}
+
+// captureBuildResult inspects the build pod for the failing container, the
+// detected stack and the tail of every container's logs.
+func (r *GitshipAppReconciler) captureBuildResult(ctx context.Context, build *gitshipiov1alpha1.GitshipBuild) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(build.Namespace), client.MatchingLabels{"job-name": build.Spec.JobName}); err != nil || len(pods.Items) == 0 {
+		return
+	}
+	// Retried Jobs leave several pods behind, the newest one tells what happened last
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[j].CreationTimestamp.Before(&pods.Items[i].CreationTimestamp)
+	})
+	pod := &pods.Items[0]
+
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		terminated := cs.State.Terminated
+		if terminated == nil && cs.State.Running == nil {
+			continue
+		}
+		if terminated != nil && cs.Name == detectContainerName && terminated.ExitCode == 0 {
+			build.Status.DetectedStack = strings.TrimSpace(terminated.Message)
+		}
+		if terminated != nil && cs.Name == kanikoContainerName && terminated.ExitCode == 0 {
+			build.Status.Digest = pushedDigest(terminated.Message)
+		}
+		if terminated != nil && terminated.ExitCode != 0 && build.Status.FailedContainer == "" {
+			build.Status.FailedContainer = cs.Name
+			build.Status.ExitCode = terminated.ExitCode
+			build.Status.Message = strings.TrimSpace(terminated.Message)
+			if build.Status.Message == "" {
+				build.Status.Message = terminated.Reason
+			}
+		}
+		if r.Clientset != nil {
+			build.Status.Logs = append(build.Status.Logs, gitshipiov1alpha1.ContainerLog{
+				Container: cs.Name,
+				Log:       r.containerLogTail(ctx, pod, cs.Name),
+			})
+		}
+	}
+}
+
+func (r *GitshipAppReconciler) containerLogTail(ctx context.Context, pod *corev1.Pod, container string) string {
+	tailLines := int64(buildLogTailLines)
+	limitBytes := int64(buildLogLimitBytes)
+	raw, err := r.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container:  container,
+		TailLines:  &tailLines,
+		LimitBytes: &limitBytes,
+	}).DoRaw(ctx)
+	if err != nil {
+		return fmt.Sprintf("Failed to read logs: %v", err)
+	}
+	return string(raw)
+}
+
+// pushedDigest returns the image digest Kaniko wrote to its termination
+// message with --digest-file, "" if there is none.
+func pushedDigest(message string) string {
+	digest := strings.TrimSpace(message)
+	if !strings.HasPrefix(digest, "sha256:") || strings.ContainsAny(digest, " \n") {
+		return ""
+	}
+	return digest
+}
+
+// buildDigest returns the image digest of the last successful build of
+// commit, "" if it's unknown.
+func buildDigest(app *gitshipiov1alpha1.GitshipApp, commit string) string {
+	for _, record := range app.Status.BuildHistory {
+		if record.CommitID == commit && record.Status == buildPhaseSucceeded {
+			return record.Digest
+		}
+	}
+	return ""
+}
+
+// applyBuildResult records a finished build in the app status.
+func (r *GitshipAppReconciler) applyBuildResult(app *gitshipiov1alpha1.GitshipApp, build *gitshipiov1alpha1.GitshipBuild) {
+	record := gitshipiov1alpha1.BuildRecord{
+		CommitID:       build.Spec.CommitID,
+		Status:         build.Status.Phase,
+		CompletionTime: metav1.Now().Format(time.RFC3339),
+		BuildName:      build.Name,
+		Tag:            build.Spec.Tag,
+		Digest:         build.Status.Digest,
+	}
+	if build.Status.StartTime != nil {
+		record.StartTime = build.Status.StartTime.Format(time.RFC3339)
+	}
+
+	switch build.Status.Phase {
+	case buildPhaseCancelled:
+		record.Message = build.Status.Message
+		setCondition(app, gitshipiov1alpha1.ConditionBuildSucceeded, metav1.ConditionFalse, "BuildCancelled", record.Message)
+	case buildPhaseSucceeded:
+		record.Message = "Build completed successfully"
+		setCondition(app, gitshipiov1alpha1.ConditionBuildSucceeded, metav1.ConditionTrue, "BuildSucceeded", fmt.Sprintf("Built commit %s", build.Spec.CommitID))
+		app.Status.LatestBuildID = build.Spec.CommitID
+		app.Status.BuildConfigHash = build.Spec.BuildConfigHash
+		app.Status.SkippedCommit = ""
+		if build.Spec.RebuildToken != "" {
+			app.Status.LatestRebuildToken = build.Spec.RebuildToken
+		}
+		if build.Status.DetectedStack != "" {
+			app.Status.DetectedStack = build.Status.DetectedStack
+		}
+	default:
+		record.Message = "Build job failed"
+		if build.Status.FailedContainer != "" {
+			record.Message = fmt.Sprintf("Build failed in %s (exit code %d): %s", build.Status.FailedContainer, build.Status.ExitCode, build.Status.Message)
+		} else if build.Status.Message != "" {
+			record.Message = build.Status.Message
+		}
+		app.Status.Phase = "Failed"
+		setCondition(app, gitshipiov1alpha1.ConditionBuildSucceeded, metav1.ConditionFalse, "BuildFailed", record.Message)
+	}
+	r.appendBuildRecord(app, record)
+}
+
+// pruneBuildRuns deletes finished builds beyond the newest maxBuildRuns.
+func (r *GitshipAppReconciler) pruneBuildRuns(ctx context.Context, builds []gitshipiov1alpha1.GitshipBuild) {
+	if len(builds) <= maxBuildRuns {
+		return
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[j].CreationTimestamp.Before(&builds[i].CreationTimestamp)
+	})
+	for i := maxBuildRuns; i < len(builds); i++ {
+		if builds[i].Status.Phase == buildPhaseRunning {
+			continue
+		}
+		if err := r.Delete(ctx, &builds[i]); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to prune build", "build", builds[i].Name)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package gitshipio
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+func TestApplyBuildResultPromotesSuccess(t *testing.T) {
+	g := NewWithT(t)
+	r := &GitshipAppReconciler{}
+	app := &gitshipiov1alpha1.GitshipApp{}
+	app.Status.SkippedCommit = "def456"
+	build := &gitshipiov1alpha1.GitshipBuild{}
+	build.Name = "app-build-abc1234"
+	build.Spec = gitshipiov1alpha1.GitshipBuildSpec{CommitID: "abc123", RebuildToken: "t1", BuildConfigHash: "h1"}
+	build.Status = gitshipiov1alpha1.GitshipBuildStatus{Phase: buildPhaseSucceeded, DetectedStack: stackGo}
+
+	r.applyBuildResult(app, build)
+
+	g.Expect(app.Status.LatestBuildID).To(Equal("abc123"))
+	g.Expect(app.Status.LatestRebuildToken).To(Equal("t1"))
+	g.Expect(app.Status.BuildConfigHash).To(Equal("h1"))
+	g.Expect(app.Status.SkippedCommit).To(BeEmpty())
+	g.Expect(app.Status.DetectedStack).To(Equal(stackGo))
+	g.Expect(app.Status.BuildHistory).To(HaveLen(1))
+	g.Expect(app.Status.BuildHistory[0].BuildName).To(Equal("app-build-abc1234"))
+	g.Expect(meta.IsStatusConditionTrue(app.Status.Conditions, gitshipiov1alpha1.ConditionBuildSucceeded)).To(BeTrue())
+}
+
+func TestApplyBuildResultRecordsFailedContainer(t *testing.T) {
+	g := NewWithT(t)
+	r := &GitshipAppReconciler{}
+	app := &gitshipiov1alpha1.GitshipApp{}
+	app.Status.LatestBuildID = "old"
+	build := &gitshipiov1alpha1.GitshipBuild{}
+	build.Spec.CommitID = "abc123"
+	build.Status = gitshipiov1alpha1.GitshipBuildStatus{
+		Phase:           buildPhaseFailed,
+		FailedContainer: "kaniko",
+		ExitCode:        1,
+		Message:         "Error",
+	}
+
+	r.applyBuildResult(app, build)
+
+	g.Expect(app.Status.LatestBuildID).To(Equal("old"))
+	g.Expect(app.Status.Phase).To(Equal("Failed"))
+	g.Expect(app.Status.BuildHistory[0].Status).To(Equal(buildPhaseFailed))
+	g.Expect(app.Status.BuildHistory[0].Message).To(Equal("Build failed in kaniko (exit code 1): Error"))
+	condition := meta.FindStatusCondition(app.Status.Conditions, gitshipiov1alpha1.ConditionBuildSucceeded)
+	g.Expect(condition).NotTo(BeNil())
+	g.Expect(condition.Reason).To(Equal("BuildFailed"))
+}
+
+func TestApplyBuildResultRecordsCancellation(t *testing.T) {
+	g := NewWithT(t)
+	r := &GitshipAppReconciler{}
+	app := &gitshipiov1alpha1.GitshipApp{}
+	app.Status.Phase = "Building"
+	build := &gitshipiov1alpha1.GitshipBuild{}
+	build.Spec.CommitID = "abc123"
+	build.Status = gitshipiov1alpha1.GitshipBuildStatus{Phase: buildPhaseCancelled, Message: "Superseded by commit def4567"}
+
+	r.applyBuildResult(app, build)
+
+	g.Expect(app.Status.Phase).To(Equal("Building"))
+	g.Expect(app.Status.LatestBuildID).To(BeEmpty())
+	g.Expect(app.Status.BuildHistory[0].Status).To(Equal(buildPhaseCancelled))
+	g.Expect(app.Status.BuildHistory[0].Message).To(Equal("Superseded by commit def4567"))
+}
+
+func TestApplyBuildResultPinsDigest(t *testing.T) {
+	g := NewWithT(t)
+	r := &GitshipAppReconciler{}
+	app := &gitshipiov1alpha1.GitshipApp{}
+	app.Spec.ImageName = "web"
+	app.Spec.RegistrySecretRef = "registry"
+	build := &gitshipiov1alpha1.GitshipBuild{}
+	build.Spec.CommitID = "abc123"
+	build.Status = gitshipiov1alpha1.GitshipBuildStatus{Phase: buildPhaseSucceeded, Digest: pushedDigest("sha256:0123\n")}
+
+	r.applyBuildResult(app, build)
+
+	g.Expect(app.Status.BuildHistory[0].Digest).To(Equal("sha256:0123"))
+	_, image := r.resolveImageNames(app, "abc123")
+	g.Expect(image).To(Equal("web:abc123@sha256:0123"))
+}
+
+func TestPushedDigestIgnoresOtherMessages(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(pushedDigest("error pushing image")).To(BeEmpty())
+	g.Expect(pushedDigest("")).To(BeEmpty())
+}
+
+// buildReconciler returns a reconciler whose client serves the status of
+// apps, builds and Deployments as a subresource.
+func buildReconciler(t *testing.T, objs ...client.Object) *GitshipAppReconciler {
+	t.Helper()
+	r := retentionReconciler(t, "")
+	r.Client = fake.NewClientBuilder().WithScheme(r.Scheme).WithObjects(objs...).
+		WithStatusSubresource(&gitshipiov1alpha1.GitshipApp{}, &gitshipiov1alpha1.GitshipBuild{}, &appsv1.Deployment{}).Build()
+	return r
+}
+
+// runningBuild returns a running build of app started at start.
+func runningBuild(app *gitshipiov1alpha1.GitshipApp, start time.Time) *gitshipiov1alpha1.GitshipBuild {
+	started := metav1.NewTime(start)
+	build := &gitshipiov1alpha1.GitshipBuild{ObjectMeta: metav1.ObjectMeta{
+		Name:              "web-build-abc1234",
+		Namespace:         app.Namespace,
+		Labels:            map[string]string{"gitship.io/app": app.Name},
+		CreationTimestamp: started,
+	}}
+	build.Spec = gitshipiov1alpha1.GitshipBuildSpec{AppName: app.Name, CommitID: "abc123", JobName: "web-build-abc1234"}
+	build.Status = gitshipiov1alpha1.GitshipBuildStatus{Phase: buildPhaseRunning, StartTime: &started}
+	return build
+}
+
+func TestSyncBuildRunsWaitsForUncachedJob(t *testing.T) {
+	g := NewWithT(t)
+	app := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}}
+	r := buildReconciler(t, app, runningBuild(app, time.Now()))
+	ctx := context.Background()
+
+	g.Expect(r.syncBuildRuns(ctx, app)).To(Succeed())
+	build := &gitshipiov1alpha1.GitshipBuild{}
+	g.Expect(r.Get(ctx, types.NamespacedName{Name: "web-build-abc1234", Namespace: "team"}, build)).To(Succeed())
+	g.Expect(build.Status.Phase).To(Equal(buildPhaseRunning))
+	g.Expect(app.Status.BuildHistory).To(BeEmpty())
+}
+
+func TestSyncBuildRunsFailsBuildWithoutJob(t *testing.T) {
+	g := NewWithT(t)
+	app := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}}
+	r := buildReconciler(t, app, runningBuild(app, time.Now().Add(-buildJobGracePeriod-time.Second)))
+	ctx := context.Background()
+
+	g.Expect(r.syncBuildRuns(ctx, app)).To(Succeed())
+	build := &gitshipiov1alpha1.GitshipBuild{}
+	g.Expect(r.Get(ctx, types.NamespacedName{Name: "web-build-abc1234", Namespace: "team"}, build)).To(Succeed())
+	g.Expect(build.Status.Phase).To(Equal(buildPhaseFailed))
+	g.Expect(build.Status.Message).To(Equal("Build job not found"))
+}
+
+func TestEnsureBuildJobDeletesBuildWhenJobFails(t *testing.T) {
+	g := NewWithT(t)
+	app := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team", UID: "web-uid"}}
+	app.Spec.RepoURL = "https://github.com/team/web"
+	r := buildReconciler(t, app)
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*batchv1.Job); ok {
+				return errors.New("quota exceeded")
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	})
+	ctx := context.Background()
+
+	err := r.ensureBuildJob(ctx, app, "web-build-abc1234", "abc123", gitCredentials{}, false)
+	g.Expect(err).To(MatchError("quota exceeded"))
+	err = r.Get(ctx, types.NamespacedName{Name: "web-build-abc1234", Namespace: "team"}, &gitshipiov1alpha1.GitshipBuild{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestSyncBuildRunsWaitsForRetries(t *testing.T) {
+	g := NewWithT(t)
+	app := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}}
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "web-build-abc1234", Namespace: "team"}}
+	job.Status.Failed = 1
+	r := buildReconciler(t, app, runningBuild(app, time.Now()), job)
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "web-build-abc1234", Namespace: "team"}
+
+	// The first pod failed, the Job is retrying it
+	g.Expect(r.syncBuildRuns(ctx, app)).To(Succeed())
+	build := &gitshipiov1alpha1.GitshipBuild{}
+	g.Expect(r.Get(ctx, key, build)).To(Succeed())
+	g.Expect(build.Status.Phase).To(Equal(buildPhaseRunning))
+
+	g.Expect(r.Get(ctx, key, job)).To(Succeed())
+	job.Status.Succeeded = 1
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	g.Expect(r.Status().Update(ctx, job)).To(Succeed())
+	g.Expect(r.syncBuildRuns(ctx, app)).To(Succeed())
+	g.Expect(r.Get(ctx, key, build)).To(Succeed())
+	g.Expect(build.Status.Phase).To(Equal(buildPhaseSucceeded))
+	g.Expect(app.Status.LatestBuildID).To(Equal("abc123"))
+}
+
+func TestCancelBuildRunsCancelsRetryingJob(t *testing.T) {
+	g := NewWithT(t)
+	app := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}}
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		Name:      "web-build-abc1234",
+		Namespace: "team",
+		Labels:    map[string]string{"gitship.io/app": "web"},
+	}}
+	job.Status.Failed = 1
+	r := buildReconciler(t, app, runningBuild(app, time.Now()), job)
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "web-build-abc1234", Namespace: "team"}
+
+	cancelled, err := r.cancelBuildRuns(ctx, app, "superseded")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cancelled).To(Equal(1))
+	g.Expect(apierrors.IsNotFound(r.Get(ctx, key, &batchv1.Job{}))).To(BeTrue())
+	build := &gitshipiov1alpha1.GitshipBuild{}
+	g.Expect(r.Get(ctx, key, build)).To(Succeed())
+	g.Expect(build.Status.Phase).To(Equal(buildPhaseCancelled))
+}
@@ -0,0 +1,138 @@
+package gitshipio
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/gitutil"
+)
+
+// cloneScript returns the git-clone container's script, cloning the
+// repository with the credentials of the app's auth method, checking out
+// COMMIT_ID and fetching submodules and LFS objects if the build asks for
+// them. GITHUB_TOKEN is set when a token was loaded, GIT_USERNAME when it
+// authenticates as another user than oauth2. URLs from the spec are only
+// referenced through the env vars of cloneEnv, never interpolated.
+func cloneScript(creds gitCredentials, repoURL string, build gitshipiov1alpha1.BuildConfig) string {
+	cloneArgs := ""
+	if build.CloneDepth > 0 {
+		// Other branches are needed to check out commits that aren't on the default branch
+		cloneArgs = fmt.Sprintf(" --depth=%d --no-single-branch", build.CloneDepth)
+	}
+	// Submodule URLs are only rewritten when the repository's host is known
+	tokenSubmodules, sshSubmodules := "", ""
+	if _, err := gitutil.ParseRepoURL(repoURL); err == nil {
+		tokenSubmodules = tokenRewrite
+		if sshBase(creds.sshURL) != "" {
+			sshSubmodules = sshRewrite
+		}
+	}
+
+	tokenClone := `
+		if [ -n "$GITHUB_TOKEN" ]; then 
+			REPO_URL=$(echo "$REPO_URL" | sed "s/https:\/\//https:\/\/${GIT_USERNAME:-oauth2}:$GITHUB_TOKEN@/"); 
+		fi; 
+		git clone` + cloneArgs + ` -- "$REPO_URL" /workspace && cd /workspace && ` + checkoutSteps(build, tokenSubmodules) + `
+	`
+	if creds.privateKey == "" {
+		return tokenClone
+	}
+
+	sshSetup := `
+		mkdir -p /root/.ssh && 
+		cp /etc/ssh-key/` + sshPrivateKeyKey + ` /root/.ssh/id_rsa && 
+		chmod 600 /root/.ssh/id_rsa && 
+		export GIT_SSH_COMMAND="ssh -i /root/.ssh/id_rsa -o UserKnownHostsFile=` + knownHostsMountPath + `/` + knownHostsKey + ` -o StrictHostKeyChecking=yes" && `
+	sshSteps := checkoutSteps(build, sshSubmodules)
+	if creds.method == authMethodSSH {
+		return sshSetup + fmt.Sprintf(`
+		git clone%s -- "$SSH_URL" /workspace && cd /workspace && %s
+	`, cloneArgs, sshSteps)
+	}
+	// auto falls back to the token, then anonymous access
+	return sshSetup + fmt.Sprintf(`
+		if git clone%s -- "$SSH_URL" /workspace; then 
+			cd /workspace && %s; 
+		else %s
+		fi
+	`, cloneArgs, sshSteps, tokenClone)
+}
+
+// cloneEnv returns the env vars the clone script reads the repository's URLs
+// from: REPO_URL, SSH_URL, GIT_HOST (the repository's host) and SSH_BASE
+// (the SSH URL prefix of that host submodules are rewritten to).
+func cloneEnv(creds gitCredentials, repoURL string) []corev1.EnvVar {
+	env := []corev1.EnvVar{{Name: "REPO_URL", Value: repoURL}}
+	if creds.privateKey != "" {
+		env = append(env, corev1.EnvVar{Name: "SSH_URL", Value: creds.sshURL})
+		if base := sshBase(creds.sshURL); base != "" {
+			env = append(env, corev1.EnvVar{Name: "SSH_BASE", Value: base})
+		}
+	}
+	if repo, err := gitutil.ParseRepoURL(repoURL); err == nil {
+		env = append(env, corev1.EnvVar{Name: "GIT_HOST", Value: repo.Host})
+	}
+	return env
+}
+
+// checkoutSteps checks out the commit to build, fetching FETCH_REF (or the
+// commit itself) first when it wasn't cloned, like the head of a pull request
+// from a fork or a commit beyond the clone depth. Submodules are cloned after
+// applying rewrite, which points their URLs at the host the repository was
+// cloned from with the same credentials.
+func checkoutSteps(build gitshipiov1alpha1.BuildConfig, rewrite string) string {
+	fetchArgs := ""
+	if build.CloneDepth > 0 {
+		fetchArgs = fmt.Sprintf(" --depth=%d", build.CloneDepth)
+	}
+	steps := []string{`{ git checkout $COMMIT_ID || { git fetch` + fetchArgs + ` origin "${FETCH_REF:-$COMMIT_ID}" && git checkout $COMMIT_ID; }; }`}
+	if build.Submodules {
+		if rewrite != "" {
+			steps = append(steps, rewrite)
+		}
+		steps = append(steps, "git submodule update --init --recursive")
+	}
+	if build.LFS {
+		// alpine/git doesn't ship git-lfs
+		steps = append(steps,
+			`{ git lfs version >/dev/null 2>&1 || apk add --no-cache git-lfs >/dev/null || { echo "git-lfs is not installed in the git image" >&2; false; }; }`,
+			"git lfs install --local",
+			"git lfs pull")
+		if build.Submodules {
+			steps = append(steps, "git submodule foreach --recursive 'git lfs install --local && git lfs pull'")
+		}
+	}
+	return strings.Join(steps, " && ")
+}
+
+// sshBase returns the prefix of SSH URLs on the host of sshURL, e.g.
+// git@host: or ssh://git@host:2222/.
+func sshBase(sshURL string) string {
+	repo, err := gitutil.ParseRepoURL(sshURL)
+	if err != nil {
+		return ""
+	}
+	user := repo.User
+	if user == "" {
+		user = "git"
+	}
+	if repo.Scheme == "ssh" && repo.Port != "" {
+		return fmt.Sprintf("ssh://%s@%s:%s/", user, repo.Host, repo.Port)
+	}
+	return fmt.Sprintf("%s@%s:", user, repo.Host)
+}
+
+const (
+	// sshRewrite makes submodules on the repository's host clone over SSH
+	// with the deploy key.
+	sshRewrite = `git config --global url."$SSH_BASE".insteadOf "https://$GIT_HOST/"`
+	// tokenRewrite makes submodules on the repository's host clone over
+	// HTTPS with the token, or anonymously without one.
+	tokenRewrite = `if [ -n "$GITHUB_TOKEN" ]; then ` +
+		`git config --global url."https://${GIT_USERNAME:-oauth2}:$GITHUB_TOKEN@$GIT_HOST/".insteadOf "https://$GIT_HOST/" && ` +
+		`git config --global --add url."https://${GIT_USERNAME:-oauth2}:$GITHUB_TOKEN@$GIT_HOST/".insteadOf "git@$GIT_HOST:"; ` +
+		`else git config --global url."https://$GIT_HOST/".insteadOf "git@$GIT_HOST:"; fi`
+)
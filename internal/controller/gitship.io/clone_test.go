@@ -0,0 +1,64 @@
+package gitshipio
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+func TestCloneScriptDefaults(t *testing.T) {
+	g := NewWithT(t)
+	script := cloneScript(gitCredentials{method: authMethodAuto}, "https://github.com/team/app", gitshipiov1alpha1.BuildConfig{})
+	g.Expect(script).To(ContainSubstring(`git clone -- "$REPO_URL" /workspace`))
+	g.Expect(script).NotTo(ContainSubstring("--depth"))
+	g.Expect(script).NotTo(ContainSubstring("submodule"))
+	g.Expect(script).NotTo(ContainSubstring("lfs"))
+}
+
+func TestCloneScriptDepth(t *testing.T) {
+	g := NewWithT(t)
+	script := cloneScript(gitCredentials{method: authMethodToken}, "https://github.com/team/app", gitshipiov1alpha1.BuildConfig{CloneDepth: 5})
+	g.Expect(script).To(ContainSubstring(`git clone --depth=5 --no-single-branch -- "$REPO_URL"`))
+	g.Expect(script).To(ContainSubstring(`git fetch --depth=5 origin "${FETCH_REF:-$COMMIT_ID}"`))
+}
+
+func TestCloneScriptSubmodulesWithDeployKey(t *testing.T) {
+	g := NewWithT(t)
+	creds := gitCredentials{method: authMethodSSH, privateKey: "key", sshURL: "ssh://git@git.example.com:2222/team/app.git"}
+	script := cloneScript(creds, "https://git.example.com/team/app.git", gitshipiov1alpha1.BuildConfig{Submodules: true, LFS: true})
+	g.Expect(script).To(ContainSubstring(`git config --global url."$SSH_BASE".insteadOf "https://$GIT_HOST/"`))
+	g.Expect(script).To(ContainSubstring("git submodule update --init --recursive"))
+	g.Expect(script).To(ContainSubstring("git lfs pull"))
+	g.Expect(script).NotTo(ContainSubstring("GITHUB_TOKEN"))
+	g.Expect(cloneEnv(creds, "https://git.example.com/team/app.git")).To(ContainElements(
+		corev1.EnvVar{Name: "SSH_URL", Value: "ssh://git@git.example.com:2222/team/app.git"},
+		corev1.EnvVar{Name: "SSH_BASE", Value: "ssh://git@git.example.com:2222/"},
+		corev1.EnvVar{Name: "GIT_HOST", Value: "git.example.com"},
+	))
+}
+
+func TestCloneScriptDoesNotInterpolateURLs(t *testing.T) {
+	g := NewWithT(t)
+	creds := gitCredentials{method: authMethodSSH, privateKey: "key", sshURL: "git@evil.example.com:x;$(id)"}
+	script := cloneScript(creds, "https://git.example.com/team/app;$(id)", gitshipiov1alpha1.BuildConfig{Submodules: true})
+	g.Expect(script).NotTo(ContainSubstring("$(id)"))
+	g.Expect(script).NotTo(ContainSubstring("example.com"))
+	g.Expect(script).To(ContainSubstring(`git clone -- "$SSH_URL" /workspace`))
+}
+
+func TestCloneScriptSubmodulesWithToken(t *testing.T) {
+	g := NewWithT(t)
+	script := cloneScript(gitCredentials{method: authMethodToken}, "https://github.com/team/app", gitshipiov1alpha1.BuildConfig{Submodules: true})
+	g.Expect(script).To(ContainSubstring(`url."https://${GIT_USERNAME:-oauth2}:$GITHUB_TOKEN@$GIT_HOST/".insteadOf "git@$GIT_HOST:"`))
+	g.Expect(script).To(ContainSubstring("git submodule update --init --recursive"))
+}
+
+func TestBuildConfigHashOfCloneOptions(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(buildConfigHash(gitshipiov1alpha1.BuildConfig{})).To(BeEmpty())
+	g.Expect(buildConfigHash(gitshipiov1alpha1.BuildConfig{Submodules: true})).NotTo(BeEmpty())
+	g.Expect(buildConfigHash(gitshipiov1alpha1.BuildConfig{CloneDepth: 1})).NotTo(BeEmpty())
+}
@@ -0,0 +1,127 @@
+package gitshipio
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+// phaseDegraded is set when a running app loses ready replicas
+const phaseDegraded = "Degraded"
+
+// setCondition sets a condition of the app for its current generation. It
+// reports whether the condition changed.
+func setCondition(app *gitshipiov1alpha1.GitshipApp, conditionType string, status metav1.ConditionStatus, reason, message string) bool {
+	return meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: app.Generation,
+	})
+}
+
+// setAvailable records whether the ready replicas of dep satisfy the app and
+// moves the phase between Running and Degraded accordingly. It reports
+// whether the status changed.
+func setAvailable(app *gitshipiov1alpha1.GitshipApp, dep *appsv1.Deployment, replicas int32) bool {
+	ready := dep.Status.ReadyReplicas
+	message := fmt.Sprintf("%d/%d replicas ready", ready, replicas)
+	if ready > 0 && ready >= replicas {
+		return setCondition(app, gitshipiov1alpha1.ConditionAvailable, metav1.ConditionTrue, "MinimumReplicasAvailable", message)
+	}
+	changed := setCondition(app, gitshipiov1alpha1.ConditionAvailable, metav1.ConditionFalse, "ReplicasUnavailable", message)
+	if app.Status.Phase == phaseRunning {
+		app.Status.Phase = phaseDegraded
+		changed = true
+	}
+	return changed
+}
+
+// setIngressReady records whether the app's Ingress has been given an
+// address. It reports whether the condition changed.
+func (r *GitshipAppReconciler) setIngressReady(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) bool {
+	if len(app.Spec.Ingresses) == 0 {
+		return meta.RemoveStatusCondition(&app.Status.Conditions, gitshipiov1alpha1.ConditionIngressReady)
+	}
+	ing := &networkingv1.Ingress{}
+	if err := r.Get(ctx, types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, ing); err != nil {
+		return setCondition(app, gitshipiov1alpha1.ConditionIngressReady, metav1.ConditionUnknown, "IngressUnknown", err.Error())
+	}
+	var addresses []string
+	for _, lb := range ing.Status.LoadBalancer.Ingress {
+		if lb.Hostname != "" {
+			addresses = append(addresses, lb.Hostname)
+		} else if lb.IP != "" {
+			addresses = append(addresses, lb.IP)
+		}
+	}
+	if len(addresses) == 0 {
+		return setCondition(app, gitshipiov1alpha1.ConditionIngressReady, metav1.ConditionFalse, "AddressPending", "The ingress controller has not assigned an address")
+	}
+	return setCondition(app, gitshipiov1alpha1.ConditionIngressReady, metav1.ConditionTrue, "AddressAssigned", strings.Join(addresses, ", "))
+}
+
+// setCertificateReady records whether cert-manager issued the certificates
+// of the app's TLS ingresses. It reports whether the condition changed.
+func (r *GitshipAppReconciler) setCertificateReady(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) bool {
+	var secretNames []string
+	for _, ingressConfig := range app.Spec.Ingresses {
+		if ingressConfig.TLS {
+			secretNames = append(secretNames, tlsSecretName(app, ingressConfig.Host))
+		}
+	}
+	if len(secretNames) == 0 {
+		return meta.RemoveStatusCondition(&app.Status.Conditions, gitshipiov1alpha1.ConditionCertificateReady)
+	}
+
+	ing := &networkingv1.Ingress{}
+	if err := r.Get(ctx, types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, ing); err == nil && ing.Annotations["cert-manager.io/issuer"] == "" {
+		return setCondition(app, gitshipiov1alpha1.ConditionCertificateReady, metav1.ConditionFalse, "NoIssuer", "No letsencrypt-prod issuer in the namespace")
+	}
+	// cert-manager names the Certificate of an Ingress after its TLS Secret
+	for _, name := range secretNames {
+		cert := &cmv1.Certificate{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, cert)
+		if apierrors.IsNotFound(err) {
+			return setCondition(app, gitshipiov1alpha1.ConditionCertificateReady, metav1.ConditionFalse, "CertificatePending", fmt.Sprintf("certificate %s not created yet", name))
+		}
+		if err != nil {
+			return setCondition(app, gitshipiov1alpha1.ConditionCertificateReady, metav1.ConditionUnknown, "CertificateUnknown", err.Error())
+		}
+		if ready := certificateReady(cert); ready == nil || ready.Status != cmmeta.ConditionTrue {
+			reason, message := "CertificatePending", fmt.Sprintf("certificate %s is not issued yet", name)
+			if ready != nil && ready.Reason != "" {
+				reason, message = ready.Reason, fmt.Sprintf("certificate %s: %s", name, ready.Message)
+			}
+			return setCondition(app, gitshipiov1alpha1.ConditionCertificateReady, metav1.ConditionFalse, reason, message)
+		}
+	}
+	return setCondition(app, gitshipiov1alpha1.ConditionCertificateReady, metav1.ConditionTrue, "CertificatesIssued", strings.Join(secretNames, ", "))
+}
+
+// certificateReady returns the Ready condition of a Certificate, or nil.
+func certificateReady(cert *cmv1.Certificate) *cmv1.CertificateCondition {
+	for i := range cert.Status.Conditions {
+		if cert.Status.Conditions[i].Type == cmv1.CertificateConditionReady {
+			return &cert.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// tlsSecretName returns the Secret holding the certificate of an ingress host.
+func tlsSecretName(app *gitshipiov1alpha1.GitshipApp, host string) string {
+	return fmt.Sprintf("%s-%s-tls", app.Name, strings.ReplaceAll(host, ".", "-"))
+}
@@ -0,0 +1,114 @@
+package gitshipio
+
+import (
+	"context"
+	"testing"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+func conditionsReconciler(t *testing.T, objs ...client.Object) *GitshipAppReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, cmv1.AddToScheme, gitshipiov1alpha1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &GitshipAppReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		Scheme: scheme,
+	}
+}
+
+func conditionsIngress(issuer string, addresses ...string) *networkingv1.Ingress {
+	ing := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}}
+	if issuer != "" {
+		ing.Annotations = map[string]string{"cert-manager.io/issuer": issuer}
+	}
+	for _, ip := range addresses {
+		ing.Status.LoadBalancer.Ingress = append(ing.Status.LoadBalancer.Ingress, networkingv1.IngressLoadBalancerIngress{IP: ip})
+	}
+	return ing
+}
+
+func conditionsApp() *gitshipiov1alpha1.GitshipApp {
+	app := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team", Generation: 3}}
+	app.Spec.Ingresses = []gitshipiov1alpha1.IngressRuleConfig{{Host: "web.example.com", TLS: true}}
+	return app
+}
+
+func TestSetAvailableDegradesAndRecovers(t *testing.T) {
+	g := NewWithT(t)
+	app := conditionsApp()
+	app.Status.Phase = phaseRunning
+	dep := &appsv1.Deployment{}
+	dep.Status.ReadyReplicas = 1
+
+	g.Expect(setAvailable(app, dep, 2)).To(BeTrue())
+	g.Expect(app.Status.Phase).To(Equal(phaseDegraded))
+	condition := meta.FindStatusCondition(app.Status.Conditions, gitshipiov1alpha1.ConditionAvailable)
+	g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(condition.Message).To(Equal("1/2 replicas ready"))
+	g.Expect(condition.ObservedGeneration).To(Equal(int64(3)))
+
+	dep.Status.ReadyReplicas = 2
+	g.Expect(setAvailable(app, dep, 2)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(app.Status.Conditions, gitshipiov1alpha1.ConditionAvailable)).To(BeTrue())
+	g.Expect(setAvailable(app, dep, 2)).To(BeFalse())
+}
+
+func TestSetIngressReady(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := conditionsApp()
+	r := conditionsReconciler(t, conditionsIngress("letsencrypt-prod"))
+	g.Expect(r.setIngressReady(ctx, app)).To(BeTrue())
+	g.Expect(meta.FindStatusCondition(app.Status.Conditions, gitshipiov1alpha1.ConditionIngressReady).Reason).To(Equal("AddressPending"))
+
+	r = conditionsReconciler(t, conditionsIngress("letsencrypt-prod", "203.0.113.7"))
+	g.Expect(r.setIngressReady(ctx, app)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(app.Status.Conditions, gitshipiov1alpha1.ConditionIngressReady)).To(BeTrue())
+
+	app.Spec.Ingresses = nil
+	g.Expect(r.setIngressReady(ctx, app)).To(BeTrue())
+	g.Expect(meta.FindStatusCondition(app.Status.Conditions, gitshipiov1alpha1.ConditionIngressReady)).To(BeNil())
+}
+
+func TestSetCertificateReady(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := conditionsApp()
+	cert := &cmv1.Certificate{ObjectMeta: metav1.ObjectMeta{Name: "web-web-example-com-tls", Namespace: "team"}}
+	cert.Status.Conditions = []cmv1.CertificateCondition{{
+		Type:    cmv1.CertificateConditionReady,
+		Status:  cmmeta.ConditionFalse,
+		Reason:  "Issuing",
+		Message: "Issuing certificate as Secret does not exist",
+	}}
+
+	r := conditionsReconciler(t, conditionsIngress(""))
+	g.Expect(r.setCertificateReady(ctx, app)).To(BeTrue())
+	g.Expect(meta.FindStatusCondition(app.Status.Conditions, gitshipiov1alpha1.ConditionCertificateReady).Reason).To(Equal("NoIssuer"))
+
+	r = conditionsReconciler(t, conditionsIngress("letsencrypt-prod"), cert)
+	g.Expect(r.setCertificateReady(ctx, app)).To(BeTrue())
+	g.Expect(meta.FindStatusCondition(app.Status.Conditions, gitshipiov1alpha1.ConditionCertificateReady).Reason).To(Equal("Issuing"))
+
+	cert.Status.Conditions[0].Status = cmmeta.ConditionTrue
+	r = conditionsReconciler(t, conditionsIngress("letsencrypt-prod"), cert)
+	g.Expect(r.setCertificateReady(ctx, app)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(app.Status.Conditions, gitshipiov1alpha1.ConditionCertificateReady)).To(BeTrue())
+}
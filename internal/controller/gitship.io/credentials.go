@@ -0,0 +1,238 @@
+package gitshipio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	golang_ssh "golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/gitutil"
+)
+
+// Ways the controller reaches a repository, reported in the app status
+const (
+	authMethodSSH       = "ssh"
+	authMethodToken     = "token"
+	authMethodAnonymous = "anonymous"
+)
+
+// Auth methods of the app spec besides "ssh" and "token"
+const (
+	// authMethodAuto tries the deploy key, the token, then anonymous access
+	authMethodAuto = "auto"
+	authMethodNone = "none"
+)
+
+const (
+	// Keys of the credentials Secret
+	sshPrivateKeyKey = "ssh-privatekey"
+	tokenKey         = "token"
+	// defaultTokenSecret holds the access token of apps without a credentials Secret
+	defaultTokenSecret = "gitship-github-token"
+	// phaseCredentialError is set while the app's credentials can't be loaded
+	phaseCredentialError = "CredentialError"
+)
+
+// gitCredentials are what the controller authenticates to an app's repository with.
+type gitCredentials struct {
+	// spec auth method, deciding which of the credentials are tried
+	method     string
+	privateKey string
+	// Secret the private key is read from
+	sshSecret string
+	token     string
+	// Secret the token is read from, empty for installation tokens
+	tokenSecret string
+	// HTTPS user the token authenticates as, "oauth2" if empty
+	tokenUser string
+	// When a short-lived token expires, zero for tokens from Secrets
+	tokenExpiry time.Time
+	// known_hosts entries SSH host keys are verified against
+	knownHosts string
+	// URL the repository is cloned from with the private key
+	sshURL string
+}
+
+// credentialError is a problem with the credentials an app is configured
+// with, as opposed to the git host rejecting them.
+type credentialError struct {
+	reason  string
+	message string
+}
+
+func (e *credentialError) Error() string {
+	return e.message
+}
+
+// appAuthMethod returns the app's auth method, "auto" if unset.
+func appAuthMethod(app *gitshipiov1alpha1.GitshipApp) string {
+	if app.Spec.AuthMethod == "" {
+		return authMethodAuto
+	}
+	return app.Spec.AuthMethod
+}
+
+// loadCredentials reads the credentials of the app's auth method. Credentials
+// the method requires but that are missing or malformed are reported as a
+// *credentialError; "auto" skips those that don't exist.
+func (r *GitshipAppReconciler) loadCredentials(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) (gitCredentials, error) {
+	method := appAuthMethod(app)
+	creds := gitCredentials{method: method, sshURL: sshURL(app)}
+	secretRef := app.Spec.CredentialsSecretRef
+	// In auto mode only an explicitly named Secret has to exist
+	required := method != authMethodAuto
+
+	var secret *corev1.Secret
+	if secretRef != "" {
+		var err error
+		if secret, err = r.credentialsSecret(ctx, app.Namespace, secretRef); err != nil {
+			return gitCredentials{}, err
+		}
+		if method == authMethodAuto && len(secret.Data[sshPrivateKeyKey]) == 0 && len(secret.Data[tokenKey]) == 0 {
+			return gitCredentials{}, &credentialError{
+				reason:  "KeyMissing",
+				message: fmt.Sprintf("secret %s has neither %q nor %q", secretRef, sshPrivateKeyKey, tokenKey),
+			}
+		}
+	}
+
+	if method == authMethodSSH || method == authMethodAuto {
+		name := secretRef
+		if name == "" {
+			name = fmt.Sprintf("%s-ssh-key", app.Name)
+		}
+		key, err := r.credentialValue(ctx, app.Namespace, name, secret, sshPrivateKeyKey, required)
+		if err != nil {
+			return gitCredentials{}, err
+		}
+		if key != "" {
+			if _, err := golang_ssh.ParsePrivateKey([]byte(key)); err != nil {
+				if method == authMethodSSH {
+					return gitCredentials{}, &credentialError{
+						reason:  "InvalidKey",
+						message: fmt.Sprintf("secret %s: invalid %s: %v", name, sshPrivateKeyKey, err),
+					}
+				}
+				log.Error(err, "Skipping SSH authentication", "secret", name)
+				key = ""
+			}
+		}
+		if key != "" {
+			knownHosts, err := r.knownHosts(ctx, app)
+			if err != nil {
+				return gitCredentials{}, err
+			}
+			creds.privateKey, creds.sshSecret, creds.knownHosts = key, name, knownHosts
+		}
+	}
+
+	if (method == authMethodToken || method == authMethodAuto) && secretRef == "" {
+		// A GitHub App's installation token replaces the namespace's token
+		token, err := r.installationToken(ctx, app)
+		var credErr *credentialError
+		// Repositories the app isn't installed on use the token Secret
+		if err != nil && (!errors.As(err, &credErr) || credErr.reason != "AppNotInstalled") {
+			return gitCredentials{}, err
+		}
+		if token.Value != "" {
+			creds.token, creds.tokenUser, creds.tokenExpiry = token.Value, installationTokenUser, token.ExpiresAt
+			return creds, nil
+		}
+	}
+
+	if method == authMethodToken || method == authMethodAuto {
+		name := secretRef
+		if name == "" {
+			name = defaultTokenSecret
+		}
+		// Only a named Secret is required: apps defaulted to "token" by older
+		// versions track public repositories without gitship-github-token
+		token, err := r.credentialValue(ctx, app.Namespace, name, secret, tokenKey, required && secretRef != "")
+		if err != nil {
+			return gitCredentials{}, err
+		}
+		if token != "" {
+			creds.token, creds.tokenSecret = token, name
+		}
+	}
+	return creds, nil
+}
+
+// credentialsSecret gets a Secret holding credentials, reporting a missing one
+// as a *credentialError.
+func (r *GitshipAppReconciler) credentialsSecret(ctx context.Context, namespace, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, secret)
+	if apierrors.IsNotFound(err) {
+		return nil, &credentialError{reason: "SecretNotFound", message: fmt.Sprintf("secret %s not found", name)}
+	}
+	return secret, err
+}
+
+// credentialValue reads a key of the named Secret, or of secret if it's
+// already been read. Unless required, a missing Secret or key yields "".
+func (r *GitshipAppReconciler) credentialValue(ctx context.Context, namespace, name string, secret *corev1.Secret, key string, required bool) (string, error) {
+	if secret == nil {
+		var err error
+		secret, err = r.credentialsSecret(ctx, namespace, name)
+		var credErr *credentialError
+		if errors.As(err, &credErr) && !required {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	value := string(secret.Data[key])
+	if value == "" && required {
+		return "", &credentialError{reason: "KeyMissing", message: fmt.Sprintf("secret %s has no %q", name, key)}
+	}
+	return value, nil
+}
+
+// setCredentialsInvalid records why the app's credentials can't be used. It
+// reports whether the condition changed.
+func setCredentialsInvalid(app *gitshipiov1alpha1.GitshipApp, err *credentialError) bool {
+	return meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               gitshipiov1alpha1.ConditionCredentialsInvalid,
+		Status:             metav1.ConditionTrue,
+		Reason:             err.reason,
+		Message:            err.message,
+		ObservedGeneration: app.Generation,
+	})
+}
+
+// clearCredentialsInvalid marks the app's credentials as usable again. It
+// reports whether the condition changed.
+func clearCredentialsInvalid(app *gitshipiov1alpha1.GitshipApp) bool {
+	if meta.FindStatusCondition(app.Status.Conditions, gitshipiov1alpha1.ConditionCredentialsInvalid) == nil {
+		return false
+	}
+	return meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               gitshipiov1alpha1.ConditionCredentialsInvalid,
+		Status:             metav1.ConditionFalse,
+		Reason:             "CredentialsLoaded",
+		ObservedGeneration: app.Generation,
+	})
+}
+
+// sshURL returns the URL to clone the app's repository from with its deploy
+// key: spec.sshUrl, or the SSH form of the repository URL.
+func sshURL(app *gitshipiov1alpha1.GitshipApp) string {
+	if app.Spec.SSHURL != "" {
+		return app.Spec.SSHURL
+	}
+	repo, err := gitutil.ParseRepoURL(app.Spec.RepoURL)
+	if err != nil {
+		return app.Spec.RepoURL
+	}
+	return repo.SSHURL()
+}
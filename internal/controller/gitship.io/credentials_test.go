@@ -0,0 +1,179 @@
+package gitshipio
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	golang_ssh "golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+func testPrivateKey(t *testing.T) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := golang_ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(block))
+}
+
+func credentialsReconciler(secrets ...client.Object) *GitshipAppReconciler {
+	return &GitshipAppReconciler{
+		Client: fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(secrets...).Build(),
+		Config: ControllerConfig{SystemNamespace: "gitship-system"},
+	}
+}
+
+func credentialsApp(method, secretRef string) *gitshipiov1alpha1.GitshipApp {
+	app := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team"}}
+	app.Spec.RepoURL = "https://github.com/team/app"
+	app.Spec.AuthMethod = method
+	app.Spec.CredentialsSecretRef = secretRef
+	return app
+}
+
+func testSecret(name string, data map[string]string) *corev1.Secret {
+	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team"}, Data: secretData(data)}
+}
+
+func secretData(data map[string]string) map[string][]byte {
+	out := map[string][]byte{}
+	for k, v := range data {
+		out[k] = []byte(v)
+	}
+	return out
+}
+
+func TestSSHURL(t *testing.T) {
+	g := NewWithT(t)
+	app := &gitshipiov1alpha1.GitshipApp{}
+	app.Spec.RepoURL = "https://gitea.example.com/team/app.git"
+	g.Expect(sshURL(app)).To(Equal("git@gitea.example.com:team/app.git"))
+
+	app.Spec.SSHURL = "ssh://git@gitea.example.com:2222/team/app.git"
+	g.Expect(sshURL(app)).To(Equal("ssh://git@gitea.example.com:2222/team/app.git"))
+}
+
+func TestRemoteCandidatesTryDeployKeyFirst(t *testing.T) {
+	g := NewWithT(t)
+	creds := gitCredentials{
+		privateKey: testPrivateKey(t),
+		token:      "t0ken",
+		sshURL:     "git@gitlab.com:team/app.git",
+	}
+	candidates := remoteCandidates("https://gitlab.com/team/app.git", creds)
+	g.Expect(candidates).To(HaveLen(3))
+	g.Expect(candidates[0].method).To(Equal(authMethodSSH))
+	g.Expect(candidates[0].url).To(Equal("git@gitlab.com:team/app.git"))
+	g.Expect(candidates[1].method).To(Equal(authMethodToken))
+	g.Expect(candidates[2].url).To(Equal("https://gitlab.com/team/app.git"))
+}
+
+func TestRemoteCandidatesOfConfiguredMethod(t *testing.T) {
+	g := NewWithT(t)
+	creds := gitCredentials{method: authMethodSSH, privateKey: testPrivateKey(t), sshURL: "git@github.com:team/app"}
+	candidates := remoteCandidates("https://github.com/team/app", creds)
+	g.Expect(candidates).To(HaveLen(1))
+	g.Expect(candidates[0].method).To(Equal(authMethodSSH))
+
+	candidates = remoteCandidates("https://github.com/team/app", gitCredentials{method: authMethodToken, token: "t0ken"})
+	g.Expect(candidates).To(HaveLen(1))
+	g.Expect(candidates[0].method).To(Equal(authMethodToken))
+
+	// Without a token the token method goes on anonymously
+	candidates = remoteCandidates("https://github.com/team/app", gitCredentials{method: authMethodToken})
+	g.Expect(candidates).To(HaveLen(1))
+	g.Expect(candidates[0].method).To(Equal(authMethodAnonymous))
+
+	candidates = remoteCandidates("https://github.com/team/app", gitCredentials{method: authMethodNone})
+	g.Expect(candidates).To(HaveLen(1))
+	g.Expect(candidates[0].method).To(Equal(authMethodAnonymous))
+}
+
+func TestCloneScriptWithoutFallbackForSSH(t *testing.T) {
+	g := NewWithT(t)
+	creds := gitCredentials{method: authMethodSSH, privateKey: testPrivateKey(t), sshURL: "git@github.com:team/app"}
+	g.Expect(cloneScript(creds, "https://github.com/team/app", gitshipiov1alpha1.BuildConfig{})).NotTo(ContainSubstring("GITHUB_TOKEN"))
+
+	creds.method = authMethodAuto
+	g.Expect(cloneScript(creds, "https://github.com/team/app", gitshipiov1alpha1.BuildConfig{})).To(ContainSubstring("GITHUB_TOKEN"))
+}
+
+func TestLoadCredentialsFromNamedSecret(t *testing.T) {
+	g := NewWithT(t)
+	r := credentialsReconciler(testSecret("git-creds", map[string]string{tokenKey: "t0ken"}))
+
+	creds, err := r.loadCredentials(context.Background(), credentialsApp(authMethodToken, "git-creds"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(creds.token).To(Equal("t0ken"))
+	g.Expect(creds.tokenSecret).To(Equal("git-creds"))
+	g.Expect(creds.privateKey).To(BeEmpty())
+}
+
+func TestLoadCredentialsReportsMissingCredentials(t *testing.T) {
+	g := NewWithT(t)
+	r := credentialsReconciler(testSecret("git-creds", map[string]string{tokenKey: "t0ken"}))
+
+	_, err := r.loadCredentials(context.Background(), credentialsApp(authMethodSSH, ""))
+	var credErr *credentialError
+	g.Expect(err).To(BeAssignableToTypeOf(credErr))
+	g.Expect(err.(*credentialError).reason).To(Equal("SecretNotFound"))
+
+	_, err = r.loadCredentials(context.Background(), credentialsApp(authMethodSSH, "git-creds"))
+	g.Expect(err).To(BeAssignableToTypeOf(credErr))
+	g.Expect(err.(*credentialError).reason).To(Equal("KeyMissing"))
+}
+
+func TestLoadCredentialsRejectsMalformedKey(t *testing.T) {
+	g := NewWithT(t)
+	r := credentialsReconciler(testSecret("app-ssh-key", map[string]string{sshPrivateKeyKey: "not a key"}))
+
+	_, err := r.loadCredentials(context.Background(), credentialsApp(authMethodSSH, ""))
+	g.Expect(err).To(BeAssignableToTypeOf(&credentialError{}))
+	g.Expect(err.(*credentialError).reason).To(Equal("InvalidKey"))
+
+	// auto skips the key and goes on without it
+	creds, err := r.loadCredentials(context.Background(), credentialsApp(authMethodAuto, ""))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(creds.privateKey).To(BeEmpty())
+}
+
+func TestLoadCredentialsSkipsMissingDefaultSecrets(t *testing.T) {
+	g := NewWithT(t)
+	r := credentialsReconciler(testSecret(defaultTokenSecret, map[string]string{tokenKey: "t0ken"}))
+
+	creds, err := r.loadCredentials(context.Background(), credentialsApp("", ""))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(creds.method).To(Equal(authMethodAuto))
+	g.Expect(creds.privateKey).To(BeEmpty())
+	g.Expect(creds.token).To(Equal("t0ken"))
+	g.Expect(creds.tokenSecret).To(Equal(defaultTokenSecret))
+}
+
+func TestLoadCredentialsWithoutTokenSecret(t *testing.T) {
+	g := NewWithT(t)
+	r := credentialsReconciler()
+
+	// Apps defaulted to token by older versions track public repositories
+	creds, err := r.loadCredentials(context.Background(), credentialsApp(authMethodToken, ""))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(creds.token).To(BeEmpty())
+
+	_, err = r.loadCredentials(context.Background(), credentialsApp(authMethodToken, "git-creds"))
+	g.Expect(err).To(BeAssignableToTypeOf(&credentialError{}))
+	g.Expect(err.(*credentialError).reason).To(Equal("SecretNotFound"))
+}
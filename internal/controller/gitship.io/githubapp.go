@@ -0,0 +1,134 @@
+package gitshipio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/githubapp"
+	"github.com/gitshipio/gitship/internal/gitutil"
+)
+
+const (
+	// githubAppSecret in the system namespace configures the GitHub App
+	// installation tokens are minted with
+	githubAppSecret = "gitship-github-app"
+	githubAppIDKey  = "app-id"
+	githubAppKeyKey = "private-key"
+	// Optional API URL of a GitHub Enterprise Server, e.g. https://github.example.com/api/v3
+	githubAppURLKey = "api-url"
+	// installationTokenUser is the HTTPS user installation tokens authenticate as
+	installationTokenUser = "x-access-token"
+	// tokenExpiresAnnotation records when the token of a build's Secret expires
+	tokenExpiresAnnotation = "gitship.io/token-expires-at"
+)
+
+// installationToken mints a token of the GitHub App's installation on the
+// app's repository. It returns no token if no GitHub App is configured or the
+// repository isn't on its host.
+func (r *GitshipAppReconciler) installationToken(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) (githubapp.Token, error) {
+	if r.GitHubApp == nil {
+		return githubapp.Token{}, nil
+	}
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: githubAppSecret, Namespace: r.Config.SystemNamespace}, secret)
+	if apierrors.IsNotFound(err) {
+		return githubapp.Token{}, nil
+	}
+	if err != nil {
+		return githubapp.Token{}, err
+	}
+	ghApp, err := r.GitHubApp.LoadApp(secret.ResourceVersion, string(secret.Data[githubAppIDKey]), secret.Data[githubAppKeyKey], string(secret.Data[githubAppURLKey]))
+	if err != nil {
+		return githubapp.Token{}, &credentialError{reason: "InvalidGitHubApp", message: fmt.Sprintf("secret %s: %v", githubAppSecret, err)}
+	}
+
+	owner, repo, ok := githubRepository(app.Spec.RepoURL, ghApp.BaseURL)
+	if !ok {
+		return githubapp.Token{}, nil
+	}
+	token, err := r.GitHubApp.RepositoryToken(ctx, ghApp, owner, repo)
+	if errors.Is(err, githubapp.ErrNotInstalled) {
+		return githubapp.Token{}, &credentialError{reason: "AppNotInstalled", message: fmt.Sprintf("the GitHub App is not installed on %s/%s", owner, repo)}
+	}
+	return token, err
+}
+
+// githubRepository returns the owner and name of a repository on the GitHub
+// host whose API is at apiURL.
+func githubRepository(repoURL, apiURL string) (owner, repo string, ok bool) {
+	parsed, err := gitutil.ParseRepoURL(repoURL)
+	if err != nil {
+		return "", "", false
+	}
+	host := "github.com"
+	if apiURL != githubapp.DefaultBaseURL {
+		api, err := url.Parse(apiURL)
+		if err != nil {
+			return "", "", false
+		}
+		host = api.Hostname()
+	}
+	if !strings.EqualFold(parsed.Host, host) {
+		return "", "", false
+	}
+	owner, repo, ok = strings.Cut(strings.TrimSuffix(strings.Trim(parsed.Path, "/"), ".git"), "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return "", "", false
+	}
+	return owner, repo, true
+}
+
+// ensureBuildTokenSecret stores a short-lived token in a Secret of the build
+// Job, so that it isn't shared with other builds. It returns the Secret's name.
+func (r *GitshipAppReconciler) ensureBuildTokenSecret(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, jobName string, creds gitCredentials) (string, error) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s-git-token", jobName),
+			Namespace:   app.Namespace,
+			Labels:      map[string]string{"gitship.io/app": app.Name},
+			Annotations: map[string]string{tokenExpiresAnnotation: creds.tokenExpiry.UTC().Format(time.RFC3339)},
+		},
+		Data: map[string][]byte{tokenKey: []byte(creds.token)},
+	}
+	// Owned by the app until the Job exists
+	if err := ctrl.SetControllerReference(app, secret, r.Scheme); err != nil {
+		return "", err
+	}
+	err := r.Create(ctx, secret)
+	if apierrors.IsAlreadyExists(err) {
+		existing := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, existing); err != nil {
+			return "", err
+		}
+		existing.Annotations = secret.Annotations
+		existing.Data = secret.Data
+		err = r.Update(ctx, existing)
+	}
+	return secret.Name, err
+}
+
+// adoptBuildTokenSecret hands the build's token Secret to its Job, so that
+// it's deleted with the Job.
+func (r *GitshipAppReconciler) adoptBuildTokenSecret(ctx context.Context, name string, job *batchv1.Job) error {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: job.Namespace}, secret); err != nil {
+		return err
+	}
+	secret.OwnerReferences = nil
+	if err := ctrl.SetControllerReference(job, secret, r.Scheme); err != nil {
+		return err
+	}
+	return r.Update(ctx, secret)
+}
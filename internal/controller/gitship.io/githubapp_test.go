@@ -0,0 +1,94 @@
+package gitshipio
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/gitshipio/gitship/internal/githubapp"
+)
+
+func TestGitHubRepository(t *testing.T) {
+	g := NewWithT(t)
+	owner, repo, ok := githubRepository("https://github.com/team/app.git", githubapp.DefaultBaseURL)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(owner).To(Equal("team"))
+	g.Expect(repo).To(Equal("app"))
+
+	owner, repo, ok = githubRepository("git@github.example.com:team/app", "https://github.example.com/api/v3")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(owner + "/" + repo).To(Equal("team/app"))
+
+	_, _, ok = githubRepository("https://gitlab.com/team/app", githubapp.DefaultBaseURL)
+	g.Expect(ok).To(BeFalse())
+	_, _, ok = githubRepository("https://github.com/team/group/app", githubapp.DefaultBaseURL)
+	g.Expect(ok).To(BeFalse())
+}
+
+// githubAppReconciler returns a reconciler configured with a GitHub App that
+// is installed on team/app of a fake GitHub API.
+func githubAppReconciler(t *testing.T) (*GitshipAppReconciler, *httptest.Server) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/team/app/installation", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"id":7}`)
+	})
+	mux.HandleFunc("POST /app/installations/7/access_tokens", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"token":"ghs_abc","expires_at":%q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: githubAppSecret, Namespace: "gitship-system"},
+		Data: map[string][]byte{
+			githubAppIDKey:  []byte("1234"),
+			githubAppKeyKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+			githubAppURLKey: []byte(server.URL),
+		},
+	}
+	r := credentialsReconciler(appSecret, testSecret(defaultTokenSecret, map[string]string{tokenKey: "t0ken"}))
+	r.GitHubApp = githubapp.NewClient()
+	return r, server
+}
+
+func TestLoadCredentialsUsesInstallationToken(t *testing.T) {
+	g := NewWithT(t)
+	r, server := githubAppReconciler(t)
+	app := credentialsApp(authMethodToken, "")
+	app.Spec.RepoURL = server.URL + "/team/app"
+
+	creds, err := r.loadCredentials(context.Background(), app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(creds.token).To(Equal("ghs_abc"))
+	g.Expect(creds.tokenUser).To(Equal(installationTokenUser))
+	g.Expect(creds.tokenSecret).To(BeEmpty())
+	g.Expect(creds.tokenExpiry).NotTo(BeZero())
+}
+
+func TestLoadCredentialsFallsBackWhereAppIsNotInstalled(t *testing.T) {
+	g := NewWithT(t)
+	r, server := githubAppReconciler(t)
+	app := credentialsApp(authMethodToken, "")
+	app.Spec.RepoURL = server.URL + "/team/other"
+
+	creds, err := r.loadCredentials(context.Background(), app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(creds.token).To(Equal("t0ken"))
+	g.Expect(creds.tokenSecret).To(Equal(defaultTokenSecret))
+}
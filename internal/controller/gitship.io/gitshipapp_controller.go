@@ -18,6 +18,7 @@ package gitshipio
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -25,25 +26,30 @@ import (
 	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/go-git/go-git/v5/storage/memory"
-	golang_ssh "golang.org/x/crypto/ssh"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/githubapp"
 )
 
 const logName = "gitshipapp-controller"
@@ -68,6 +74,12 @@ type ControllerConfig struct {
 
 	ImageGit    string
 	ImageKaniko string
+
+	// Images of the built-in registry kept per app besides those in use or in
+	// the build history, pruning is disabled when 0
+	RegistryRetainBuilds int
+	// How often the built-in registry is pruned
+	RegistryGCInterval time.Duration
 }
 
 // GitshipAppReconciler reconciles a GitshipApp object
@@ -75,16 +87,26 @@ type GitshipAppReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 	Config ControllerConfig
+	// Clientset is used to read build pod logs. Logs are not captured when nil.
+	Clientset kubernetes.Interface
+	// GitHubApp mints installation tokens when a GitHub App is configured. Apps
+	// use their token Secrets only when nil.
+	GitHubApp *githubapp.Client
 }
 
 // +kubebuilder:rbac:groups=gitship.io,resources=gitshipapps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=gitship.io,resources=gitshipapps/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=gitship.io,resources=gitshipapps/finalizers,verbs=update
+// +kubebuilder:rbac:groups=gitship.io,resources=gitshipbuilds,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gitship.io,resources=gitshipbuilds/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=gitship.io,resources=gitshipintegrations,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=services;secrets;pods;persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=services;secrets;configmaps;pods;persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=pods/log,verbs=get
 // +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses;networkpolicies,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cert-manager.io,resources=issuers,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch
 
 func (r *GitshipAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.WithValues("gitshipapp", req.NamespacedName)
@@ -94,59 +116,77 @@ func (r *GitshipAppReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	latestCommit, privateKey, _, result := r.resolveAuthAndCommit(ctx, gitshipApp)
-	if result != nil {
-		return *result, nil
+	if !gitshipApp.DeletionTimestamp.IsZero() {
+		return r.finalizeApp(ctx, gitshipApp)
 	}
-
-	log.Info("Resolved latest commit", "commit", latestCommit, "source", gitshipApp.Spec.Source.Type, "value", gitshipApp.Spec.Source.Value)
-
-	isRebuild := gitshipApp.Spec.RebuildToken != "" && gitshipApp.Spec.RebuildToken != gitshipApp.Status.LatestRebuildToken
-
-	if gitshipApp.Status.LatestBuildID == latestCommit && !isRebuild {
-		// 0. Resolve Image
-		_, image := r.resolveImageNames(gitshipApp, latestCommit)
-
-		if err := r.ensureVolumes(ctx, gitshipApp); err != nil {
+	added := controllerutil.AddFinalizer(gitshipApp, appFinalizer)
+	if controllerutil.RemoveFinalizer(gitshipApp, legacyAppFinalizer) || added {
+		if err := r.Update(ctx, gitshipApp); err != nil {
 			return ctrl.Result{}, err
 		}
+	}
 
-		replicas := gitshipApp.Spec.Replicas
-		if replicas == 0 {
-			replicas = 1
-		}
+	if err := r.syncBuildRuns(ctx, gitshipApp); err != nil {
+		return ctrl.Result{}, err
+	}
 
-		if err := r.ensureDeployment(ctx, gitshipApp, image, replicas); err != nil {
+	if gitshipApp.Spec.CancelToken != "" && gitshipApp.Spec.CancelToken != gitshipApp.Status.LatestCancelToken {
+		cancelled, err := r.cancelBuildRuns(ctx, gitshipApp, "Cancelled by user")
+		if err != nil {
 			return ctrl.Result{}, err
 		}
-
-		if err := r.ensureService(ctx, gitshipApp); err != nil {
-			return ctrl.Result{}, err
+		if cancelled > 0 {
+			gitshipApp.Status.Phase = buildPhaseCancelled
 		}
-
-		if err := r.ensureIngress(ctx, gitshipApp); err != nil {
+		gitshipApp.Status.LatestCancelToken = gitshipApp.Spec.CancelToken
+		if err := r.Status().Update(ctx, gitshipApp); err != nil {
 			return ctrl.Result{}, err
 		}
+	}
+
+	if err := r.reconcilePreviews(ctx, gitshipApp); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if gitshipApp.Spec.Image != nil {
+		return r.reconcileImageSource(ctx, gitshipApp)
+	}
 
-		if err := r.updateAppStatus(ctx, gitshipApp, replicas); err != nil {
+	latestCommit, creds, result := r.resolveAuthAndCommit(ctx, gitshipApp)
+	if result != nil {
+		return *result, nil
+	}
+
+	log.Info("Resolved latest commit", "commit", latestCommit, "source", gitshipApp.Spec.Source.Type, "value", gitshipApp.Spec.Source.Value)
+
+	if gitshipApp.Spec.PinnedCommit != "" {
+		return r.reconcilePinned(ctx, gitshipApp, latestCommit, creds)
+	}
+	if clearPin(gitshipApp) {
+		if err := r.Status().Update(ctx, gitshipApp); err != nil {
 			return ctrl.Result{}, err
 		}
+	}
 
-		requeueAfter := 5 * time.Minute
-		if gitshipApp.Spec.UpdateStrategy.Type == "webhook" {
-			requeueAfter = 0
-		} else if gitshipApp.Spec.UpdateStrategy.Interval != "" {
-			if parsed, err := time.ParseDuration(gitshipApp.Spec.UpdateStrategy.Interval); err == nil {
-				requeueAfter = parsed
-			}
-		}
+	isRebuild := gitshipApp.Spec.RebuildToken != "" && gitshipApp.Spec.RebuildToken != gitshipApp.Status.LatestRebuildToken
+	buildHash := buildConfigHash(gitshipApp.Spec.Build)
+	buildConfigChanged := buildHash != gitshipApp.Status.BuildConfigHash
 
-		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	skipped := latestCommit == gitshipApp.Status.SkippedCommit
+	if !skipped && gitshipApp.Status.LatestBuildID != latestCommit && !isRebuild && !buildConfigChanged {
+		skipped = r.skipByPathFilter(ctx, gitshipApp, latestCommit, creds)
 	}
 
-	log.Info("Build trigger detected", "commit", latestCommit, "rebuild", isRebuild)
+	if (gitshipApp.Status.LatestBuildID == latestCommit || skipped) && !isRebuild && !buildConfigChanged {
+		return r.deploy(ctx, gitshipApp, deployCommit(gitshipApp))
+	}
+
+	log.Info("Build trigger detected", "commit", latestCommit, "rebuild", isRebuild, "buildConfigChanged", buildConfigChanged)
 
 	jobName := fmt.Sprintf("%s-build-%s", gitshipApp.Name, latestCommit[:7])
+	if buildHash != "" {
+		jobName = fmt.Sprintf("%s-%s", jobName, buildHash[:6])
+	}
 	if isRebuild {
 		token := gitshipApp.Spec.RebuildToken
 		if len(token) > 8 {
@@ -155,51 +195,140 @@ func (r *GitshipAppReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		jobName = fmt.Sprintf("%s-rebuild-%s", gitshipApp.Name, token)
 	}
 
+	build := &gitshipiov1alpha1.GitshipBuild{}
+	err := r.Get(ctx, types.NamespacedName{Name: jobName, Namespace: gitshipApp.Namespace}, build)
+	if err != nil && client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	if err == nil {
+		if build.Status.Phase == buildPhaseFailed || build.Status.Phase == buildPhaseCancelled {
+			// A failed or cancelled build is not retried until the commit, build config or rebuild token changes
+			return ctrl.Result{RequeueAfter: pollInterval(gitshipApp)}, nil
+		}
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
+	// Ship the last finished build while the next one runs, unless only the newest commit may be deployed
+	if gitshipApp.Spec.BuildPolicy != buildPolicySkipIntermediate && gitshipApp.Status.LatestBuildID != "" {
+		if err := r.deployLatestBuild(ctx, gitshipApp); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if gitshipApp.Spec.BuildPolicy == buildPolicyCancelInProgress {
+		cancelled, err := r.cancelBuildRuns(ctx, gitshipApp, fmt.Sprintf("Superseded by commit %s", latestCommit[:7]))
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if cancelled > 0 {
+			if err := r.Status().Update(ctx, gitshipApp); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
 	// Safety: Check if any active build job already exists for this app
 	existingJobs := &batchv1.JobList{}
 	if err := r.List(ctx, existingJobs,
 		client.InNamespace(gitshipApp.Namespace),
 		client.MatchingLabels{"gitship.io/app": gitshipApp.Name}); err == nil {
 		for _, ej := range existingJobs.Items {
-			if ej.Status.Succeeded == 0 && ej.Status.Failed == 0 {
+			if jobPhase(&ej) == "" && ej.DeletionTimestamp == nil {
 				log.Info("Build job already running, waiting", "job", ej.Name)
 				return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
 			}
 		}
 	}
 
-	job := &batchv1.Job{}
-	err := r.Get(ctx, types.NamespacedName{Name: jobName, Namespace: gitshipApp.Namespace}, job)
-	if err != nil && client.IgnoreNotFound(err) != nil {
+	if err := r.ensureBuildJob(ctx, gitshipApp, jobName, latestCommit, creds, isRebuild); err != nil {
 		return ctrl.Result{}, err
 	}
 
+	return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+}
+
+// deploy runs the image of commit and exposes it.
+func (r *GitshipAppReconciler) deploy(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp, commit string) (ctrl.Result, error) {
+	// 0. Resolve Image
+	_, image := r.resolveImageNames(gitshipApp, commit)
+
+	if err := r.ensureVolumes(ctx, gitshipApp); err != nil {
+		return ctrl.Result{}, r.deployFailed(ctx, gitshipApp, err)
+	}
+
+	replicas := gitshipApp.Spec.Replicas
+	if replicas == 0 {
+		replicas = 1
+	}
+
+	rollout, err := r.rollOut(ctx, gitshipApp, commit, image, replicas)
 	if err != nil {
-		if err := r.ensureBuildJob(ctx, gitshipApp, jobName, latestCommit, privateKey, isRebuild); err != nil {
-			return ctrl.Result{}, err
-		}
-	} else if job.Status.Succeeded > 0 {
-		log.Info("Build Job succeeded, recording and re-reconciling")
-		if isRebuild {
-			gitshipApp.Status.LatestRebuildToken = gitshipApp.Spec.RebuildToken
-		}
-		r.recordBuild(gitshipApp, latestCommit, "Succeeded", "Build completed successfully")
-		gitshipApp.Status.LatestBuildID = latestCommit
-		if err := r.Status().Update(ctx, gitshipApp); err != nil {
-			return ctrl.Result{}, err
-		}
+		return ctrl.Result{}, r.deployFailed(ctx, gitshipApp, err)
+	}
+	if rollout == rolloutRolledBack {
 		return ctrl.Result{Requeue: true}, nil
-	} else if job.Status.Failed > 0 {
-		log.Info("Build Job failed, recording")
-		gitshipApp.Status.Phase = "Failed"
-		r.recordBuild(gitshipApp, latestCommit, "Failed", "Build job failed")
+	}
+
+	if err := r.ensureService(ctx, gitshipApp, gitshipApp.Name, activeDeployment(gitshipApp)); err != nil {
+		return ctrl.Result{}, r.deployFailed(ctx, gitshipApp, err)
+	}
+
+	if err := r.ensureIngress(ctx, gitshipApp); err != nil {
+		return ctrl.Result{}, r.deployFailed(ctx, gitshipApp, err)
+	}
+
+	if err := r.updateAppStatus(ctx, gitshipApp, image, replicas); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	requeueAfter := pollInterval(gitshipApp)
+	if rollout == rolloutProgressing && (requeueAfter == 0 || requeueAfter > 30*time.Second) {
+		// Pod failures don't trigger a reconcile, keep watching the rollout
+		requeueAfter = 30 * time.Second
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// deployFailed records why the app couldn't be deployed and returns err.
+func (r *GitshipAppReconciler) deployFailed(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp, err error) error {
+	if setCondition(gitshipApp, gitshipiov1alpha1.ConditionDeployed, metav1.ConditionFalse, "DeployFailed", err.Error()) {
 		_ = r.Status().Update(ctx, gitshipApp)
 	}
+	return err
+}
 
-	return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+// deployLatestBuild rolls the app forward to the last successful build.
+func (r *GitshipAppReconciler) deployLatestBuild(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp) error {
+	commit := deployCommit(gitshipApp)
+	_, image := r.resolveImageNames(gitshipApp, commit)
+	if err := r.ensureVolumes(ctx, gitshipApp); err != nil {
+		return err
+	}
+	replicas := gitshipApp.Spec.Replicas
+	if replicas == 0 {
+		replicas = 1
+	}
+	_, err := r.rollOut(ctx, gitshipApp, commit, image, replicas)
+	return err
+}
+
+// pollInterval returns how often the app's source is checked for new commits,
+// 0 for webhook driven apps.
+func pollInterval(app *gitshipiov1alpha1.GitshipApp) time.Duration {
+	if app.Spec.UpdateStrategy.Type == "webhook" {
+		return 0
+	}
+	if app.Spec.UpdateStrategy.Interval != "" {
+		if parsed, err := time.ParseDuration(app.Spec.UpdateStrategy.Interval); err == nil {
+			return parsed
+		}
+	}
+	return 5 * time.Minute
 }
 
-func (r *GitshipAppReconciler) ensureService(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp) error {
+// ensureService creates or updates the Service svcName selecting the pods
+// labeled app=selectorApp.
+func (r *GitshipAppReconciler) ensureService(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp, svcName, selectorApp string) error {
 	svcPorts := make([]corev1.ServicePort, 0, len(gitshipApp.Spec.Ports))
 	for _, p := range gitshipApp.Spec.Ports {
 		name := p.Name
@@ -224,7 +353,6 @@ func (r *GitshipAppReconciler) ensureService(ctx context.Context, gitshipApp *gi
 	}
 
 	svc := &corev1.Service{}
-	svcName := gitshipApp.Name
 	err := r.Get(ctx, types.NamespacedName{Name: svcName, Namespace: gitshipApp.Namespace}, svc)
 	if err != nil && client.IgnoreNotFound(err) != nil {
 		return err
@@ -238,7 +366,7 @@ func (r *GitshipAppReconciler) ensureService(ctx context.Context, gitshipApp *gi
 				Labels:    map[string]string{"app": gitshipApp.Name},
 			},
 			Spec: corev1.ServiceSpec{
-				Selector: map[string]string{"app": gitshipApp.Name},
+				Selector: map[string]string{"app": selectorApp},
 				Ports:    svcPorts,
 				Type:     corev1.ServiceTypeClusterIP,
 			},
@@ -248,16 +376,32 @@ func (r *GitshipAppReconciler) ensureService(ctx context.Context, gitshipApp *gi
 		}
 		return r.Create(ctx, newSvc)
 	}
+	if !metav1.IsControlledBy(svc, gitshipApp) {
+		return fmt.Errorf("service %s isn't controlled by app %s", svcName, gitshipApp.Name)
+	}
 
+	changed := false
 	if !compareServicePorts(svc.Spec.Ports, svcPorts) {
 		log.Info("Updating Service Ports")
 		svc.Spec.Ports = svcPorts
+		changed = true
+	}
+	if svc.Spec.Selector["app"] != selectorApp {
+		log.Info("Switching Service selector", "service", svcName, "app", selectorApp)
+		svc.Spec.Selector = map[string]string{"app": selectorApp}
+		changed = true
+	}
+	if changed {
 		return r.Update(ctx, svc)
 	}
 	return nil
 }
 
 func (r *GitshipAppReconciler) ensureIngress(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp) error {
+	if err := r.ensureCanaryIngress(ctx, gitshipApp); err != nil {
+		return err
+	}
+
 	if len(gitshipApp.Spec.Ingresses) == 0 {
 		ing := &networkingv1.Ingress{}
 		err := r.Get(ctx, types.NamespacedName{Name: gitshipApp.Name, Namespace: gitshipApp.Namespace}, ing)
@@ -275,58 +419,23 @@ func (r *GitshipAppReconciler) ensureIngress(ctx context.Context, gitshipApp *gi
 		return err
 	}
 
-	pathType := networkingv1.PathTypePrefix
-	ingressClassName := r.Config.IngressClassName
-	if ingressClassName == "" {
-		ingressClassName = "nginx"
-	}
-
+	ingressClassName := r.ingressClassName()
 	annotations := map[string]string{
 		"kubernetes.io/ingress.class": ingressClassName,
 	}
 
-	rules := make([]networkingv1.IngressRule, 0, len(gitshipApp.Spec.Ingresses))
+	rules := ingressRules(gitshipApp, gitshipApp.Name)
 	var tls []networkingv1.IngressTLS
-	anyTlsEnabled := false
-
 	for _, ingressConfig := range gitshipApp.Spec.Ingresses {
-		path := ingressConfig.Path
-		if path == "" {
-			path = "/"
-		}
-
-		rules = append(rules, networkingv1.IngressRule{
-			Host: ingressConfig.Host,
-			IngressRuleValue: networkingv1.IngressRuleValue{
-				HTTP: &networkingv1.HTTPIngressRuleValue{
-					Paths: []networkingv1.HTTPIngressPath{
-						{
-							Path:     path,
-							PathType: &pathType,
-							Backend: networkingv1.IngressBackend{
-								Service: &networkingv1.IngressServiceBackend{
-									Name: gitshipApp.Name,
-									Port: networkingv1.ServiceBackendPort{
-										Number: ingressConfig.ServicePort,
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-		})
-
 		if ingressConfig.TLS {
-			anyTlsEnabled = true
 			tls = append(tls, networkingv1.IngressTLS{
 				Hosts:      []string{ingressConfig.Host},
-				SecretName: fmt.Sprintf("%s-%s-tls", gitshipApp.Name, strings.ReplaceAll(ingressConfig.Host, ".", "-")),
+				SecretName: tlsSecretName(gitshipApp, ingressConfig.Host),
 			})
 		}
 	}
 
-	if anyTlsEnabled {
+	if len(tls) > 0 {
 		// Check if local Issuer exists (created by User Controller)
 		localIssuer := &cmv1.Issuer{}
 		err := r.Get(ctx, types.NamespacedName{Name: "letsencrypt-prod", Namespace: gitshipApp.Namespace}, localIssuer)
@@ -368,9 +477,52 @@ func (r *GitshipAppReconciler) ensureIngress(ctx context.Context, gitshipApp *gi
 	return r.Update(ctx, ing)
 }
 
-func (r *GitshipAppReconciler) ensureDeployment(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp, image string, replicas int32) error {
+func (r *GitshipAppReconciler) ingressClassName() string {
+	if r.Config.IngressClassName != "" {
+		return r.Config.IngressClassName
+	}
+	return "nginx"
+}
+
+// ingressRules routes every ingress host of the app to serviceName.
+func ingressRules(gitshipApp *gitshipiov1alpha1.GitshipApp, serviceName string) []networkingv1.IngressRule {
+	pathType := networkingv1.PathTypePrefix
+	rules := make([]networkingv1.IngressRule, 0, len(gitshipApp.Spec.Ingresses))
+	for _, ingressConfig := range gitshipApp.Spec.Ingresses {
+		path := ingressConfig.Path
+		if path == "" {
+			path = "/"
+		}
+
+		rules = append(rules, networkingv1.IngressRule{
+			Host: ingressConfig.Host,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{
+						{
+							Path:     path,
+							PathType: &pathType,
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: serviceName,
+									Port: networkingv1.ServiceBackendPort{
+										Number: ingressConfig.ServicePort,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+	return rules
+}
+
+// ensureDeployment creates or updates the Deployment depName running image.
+// Its pods are labeled app=depName.
+func (r *GitshipAppReconciler) ensureDeployment(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp, depName, image string, replicas int32) error {
 	dep := &appsv1.Deployment{}
-	depName := gitshipApp.Name
 	err := r.Get(ctx, types.NamespacedName{Name: depName, Namespace: gitshipApp.Namespace}, dep)
 	if err != nil && client.IgnoreNotFound(err) != nil {
 		return err
@@ -392,6 +544,7 @@ func (r *GitshipAppReconciler) ensureDeployment(ctx context.Context, gitshipApp
 
 	volumes, volumeMounts := r.generatePodVolumes(gitshipApp)
 
+	progressDeadline := progressDeadlineSeconds(gitshipApp.Spec.Rollout)
 	appResources := resolveResources(gitshipApp.Spec.Resources)
 
 	var imagePullSecrets []corev1.LocalObjectReference
@@ -419,16 +572,17 @@ func (r *GitshipAppReconciler) ensureDeployment(ctx context.Context, gitshipApp
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      depName,
 				Namespace: gitshipApp.Namespace,
-				Labels:    map[string]string{"app": gitshipApp.Name},
+				Labels:    map[string]string{"app": depName},
 			},
 			Spec: appsv1.DeploymentSpec{
-				Replicas: &replicas,
+				Replicas:                &replicas,
+				ProgressDeadlineSeconds: &progressDeadline,
 				Selector: &metav1.LabelSelector{
-					MatchLabels: map[string]string{"app": gitshipApp.Name},
+					MatchLabels: map[string]string{"app": depName},
 				},
 				Template: corev1.PodTemplateSpec{
 					ObjectMeta: metav1.ObjectMeta{
-						Labels: map[string]string{"app": gitshipApp.Name},
+						Labels: map[string]string{"app": depName},
 					},
 					Spec: corev1.PodSpec{
 						SecurityContext: &corev1.PodSecurityContext{
@@ -466,8 +620,14 @@ func (r *GitshipAppReconciler) ensureDeployment(ctx context.Context, gitshipApp
 			log.Error(err, "Failed to create Deployment")
 			return err
 		}
+	} else if !metav1.IsControlledBy(dep, gitshipApp) {
+		return fmt.Errorf("deployment %s isn't controlled by app %s", depName, gitshipApp.Name)
 	} else {
 		changed := false
+		if dep.Spec.ProgressDeadlineSeconds == nil || *dep.Spec.ProgressDeadlineSeconds != progressDeadline {
+			dep.Spec.ProgressDeadlineSeconds = &progressDeadline
+			changed = true
+		}
 		if *dep.Spec.Replicas != replicas {
 			log.Info("Updating Replicas", "old", *dep.Spec.Replicas, "new", replicas)
 			dep.Spec.Replicas = &replicas
@@ -564,15 +724,31 @@ func (r *GitshipAppReconciler) ensureDeployment(ctx context.Context, gitshipApp
 	return nil
 }
 
+// volumeClaimName returns the name of the PVC backing an app's volume.
+func volumeClaimName(app *gitshipiov1alpha1.GitshipApp, vol gitshipiov1alpha1.VolumeConfig) string {
+	return fmt.Sprintf("%s-%s", app.Name, vol.Name)
+}
+
 func (r *GitshipAppReconciler) ensureVolumes(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp) error {
 	for _, vol := range gitshipApp.Spec.Volumes {
 		pvc := &corev1.PersistentVolumeClaim{}
-		pvcName := fmt.Sprintf("%s-%s", gitshipApp.Name, vol.Name)
+		pvcName := volumeClaimName(gitshipApp, vol)
 		err := r.Get(ctx, types.NamespacedName{Name: pvcName, Namespace: gitshipApp.Namespace}, pvc)
 		if err != nil && client.IgnoreNotFound(err) != nil {
 			return err
 		}
 
+		if err == nil && metav1.GetControllerOf(pvc) == nil && pvc.Labels["gitship.io/app"] == gitshipApp.Name {
+			// Retained from a deleted app of the same name
+			log.Info("Adopting retained PVC", "name", pvcName)
+			if err := ctrl.SetControllerReference(gitshipApp, pvc, r.Scheme); err != nil {
+				return err
+			}
+			if err := r.Update(ctx, pvc); err != nil {
+				return err
+			}
+		}
+
 		if err != nil {
 			log.Info("Creating PVC", "name", pvcName, "size", vol.Size)
 			storageClass := vol.StorageClass
@@ -580,6 +756,7 @@ func (r *GitshipAppReconciler) ensureVolumes(ctx context.Context, gitshipApp *gi
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      pvcName,
 					Namespace: gitshipApp.Namespace,
+					Labels:    map[string]string{"gitship.io/app": gitshipApp.Name},
 				},
 				Spec: corev1.PersistentVolumeClaimSpec{
 					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
@@ -612,7 +789,7 @@ func (r *GitshipAppReconciler) generatePodVolumes(gitshipApp *gitshipiov1alpha1.
 
 	// 1. Persistent Volumes
 	for _, v := range gitshipApp.Spec.Volumes {
-		pvcName := fmt.Sprintf("%s-%s", gitshipApp.Name, v.Name)
+		pvcName := volumeClaimName(gitshipApp, v)
 		volumeMounts = append(volumeMounts, corev1.VolumeMount{
 			Name:      v.Name,
 			MountPath: v.MountPath,
@@ -664,9 +841,10 @@ func (r *GitshipAppReconciler) generatePodVolumes(gitshipApp *gitshipiov1alpha1.
 	return volumes, volumeMounts
 }
 
-func (r *GitshipAppReconciler) updateAppStatus(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp, replicas int32) error {
+func (r *GitshipAppReconciler) updateAppStatus(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp, image string, replicas int32) error {
+	depName := activeDeployment(gitshipApp)
 	dep := &appsv1.Deployment{}
-	_ = r.Get(ctx, types.NamespacedName{Name: gitshipApp.Name, Namespace: gitshipApp.Namespace}, dep)
+	_ = r.Get(ctx, types.NamespacedName{Name: depName, Namespace: gitshipApp.Namespace}, dep)
 
 	statusChanged := false
 	if gitshipApp.Status.DesiredReplicas != replicas || gitshipApp.Status.ReadyReplicas != dep.Status.ReadyReplicas {
@@ -676,12 +854,31 @@ func (r *GitshipAppReconciler) updateAppStatus(ctx context.Context, gitshipApp *
 	}
 
 	if dep.Status.ReadyReplicas > 0 && dep.Status.ReadyReplicas >= replicas {
-		if gitshipApp.Status.Phase != phaseRunning {
+		if gitshipApp.Status.Phase != phaseRunning && gitshipApp.Status.Phase != phaseRolledBack {
+			// Recovering replicas isn't a new deployment
+			if gitshipApp.Status.Phase != phaseDegraded {
+				gitshipApp.Status.LastDeployedAt = metav1.Now().Format(time.RFC3339)
+			}
 			gitshipApp.Status.Phase = phaseRunning
-			gitshipApp.Status.LastDeployedAt = metav1.Now().Format(time.RFC3339)
 			statusChanged = true
 		}
 	}
+	if setAvailable(gitshipApp, dep, replicas) {
+		statusChanged = true
+	}
+	if setCondition(gitshipApp, gitshipiov1alpha1.ConditionDeployed, metav1.ConditionTrue, "Deployed", image) {
+		statusChanged = true
+	}
+	if r.setIngressReady(ctx, gitshipApp) {
+		statusChanged = true
+	}
+	if r.setCertificateReady(ctx, gitshipApp) {
+		statusChanged = true
+	}
+	if gitshipApp.Status.ObservedGeneration != gitshipApp.Generation {
+		gitshipApp.Status.ObservedGeneration = gitshipApp.Generation
+		statusChanged = true
+	}
 
 	// Calculate AppURL based on Ingress
 	newAppURL := ""
@@ -705,7 +902,7 @@ func (r *GitshipAppReconciler) updateAppStatus(ctx context.Context, gitshipApp *
 	}
 
 	podList := &corev1.PodList{}
-	if err := r.List(ctx, podList, client.InNamespace(gitshipApp.Namespace), client.MatchingLabels{"app": gitshipApp.Name}); err == nil {
+	if err := r.List(ctx, podList, client.InNamespace(gitshipApp.Namespace), client.MatchingLabels{"app": depName}); err == nil {
 		var totalRestarts int32
 		for _, pod := range podList.Items {
 			for _, cs := range pod.Status.ContainerStatuses {
@@ -726,27 +923,36 @@ func (r *GitshipAppReconciler) updateAppStatus(ctx context.Context, gitshipApp *
 	return nil
 }
 
-func (r *GitshipAppReconciler) resolveAuthAndCommit(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp) (string, string, string, *ctrl.Result) {
-	// 1. Try SSH Key
-	privateKey := ""
-	sshSecret := &corev1.Secret{}
-	sshSecretName := fmt.Sprintf("%s-ssh-key", gitshipApp.Name)
-	if err := r.Get(ctx, types.NamespacedName{Name: sshSecretName, Namespace: gitshipApp.Namespace}, sshSecret); err == nil {
-		privateKey = string(sshSecret.Data["ssh-privatekey"])
+func (r *GitshipAppReconciler) resolveAuthAndCommit(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp) (string, gitCredentials, *ctrl.Result) {
+	creds, err := r.loadCredentials(ctx, gitshipApp)
+	var credErr *credentialError
+	if errors.As(err, &credErr) {
+		log.Info("Invalid credentials", "authMethod", appAuthMethod(gitshipApp), "reason", credErr.reason, "error", credErr.message)
+		resolvedChanged := setCondition(gitshipApp, gitshipiov1alpha1.ConditionSourceResolved, metav1.ConditionFalse, credErr.reason, credErr.message)
+		if setCredentialsInvalid(gitshipApp, credErr) || resolvedChanged || gitshipApp.Status.Phase != phaseCredentialError {
+			gitshipApp.Status.Phase = phaseCredentialError
+			_ = r.Status().Update(ctx, gitshipApp)
+		}
+		return "", gitCredentials{}, &ctrl.Result{RequeueAfter: 5 * time.Minute}
 	}
-
-	// 2. Try GitHub Token
-	githubToken := ""
-	tokenSecret := &corev1.Secret{}
-	if err := r.Get(ctx, types.NamespacedName{Name: "gitship-github-token", Namespace: gitshipApp.Namespace}, tokenSecret); err == nil {
-		githubToken = string(tokenSecret.Data["token"])
+	if err != nil {
+		log.Error(err, "Failed to load credentials")
+		return "", gitCredentials{}, &ctrl.Result{RequeueAfter: 1 * time.Minute}
 	}
 
-	// Resolve latest commit using 3-step strategy: SSH -> Token -> Anon
+	// Resolve latest commit with the auth method's credentials
 	repoURL := gitshipApp.Spec.RepoURL
 	source := gitshipApp.Spec.Source
 
-	latestCommit, err := resolveLatestCommit(repoURL, source, privateKey, githubToken)
+	resolved, err := resolveLatestCommit(repoURL, source, creds)
+	if errors.Is(err, errRefNotFound) {
+		log.Info("Tracked ref not found", "repo", repoURL, "source", source.Type, "value", source.Value)
+		resolvedChanged := setCondition(gitshipApp, gitshipiov1alpha1.ConditionSourceResolved, metav1.ConditionFalse, "RefNotFound", err.Error())
+		if setSourceMissing(gitshipApp, "RefNotFound", err.Error()) || resolvedChanged {
+			_ = r.Status().Update(ctx, gitshipApp)
+		}
+		return "", gitCredentials{}, &ctrl.Result{RequeueAfter: 5 * time.Minute}
+	}
 	if err != nil {
 		log.Error(err, "Failed to resolve latest commit", "repo", repoURL)
 
@@ -754,19 +960,41 @@ func (r *GitshipAppReconciler) resolveAuthAndCommit(ctx context.Context, gitship
 		errMsg := strings.ToLower(err.Error())
 		if strings.Contains(errMsg, "auth") || strings.Contains(errMsg, "unauthorized") {
 			gitshipApp.Status.Phase = "AuthError"
+			setCondition(gitshipApp, gitshipiov1alpha1.ConditionSourceResolved, metav1.ConditionFalse, "AuthFailed", err.Error())
 			_ = r.Status().Update(ctx, gitshipApp)
-			return "", "", "", &ctrl.Result{RequeueAfter: 5 * time.Minute}
+			return "", gitCredentials{}, &ctrl.Result{RequeueAfter: 5 * time.Minute}
 		}
 
+		changed := setCondition(gitshipApp, gitshipiov1alpha1.ConditionSourceResolved, metav1.ConditionFalse, "ResolveFailed", err.Error())
 		if gitshipApp.Status.Phase == "Building" {
 			gitshipApp.Status.Phase = "Failed"
+			changed = true
+		}
+		if changed {
 			_ = r.Status().Update(ctx, gitshipApp)
 		}
-		return "", "", "", &ctrl.Result{RequeueAfter: 1 * time.Minute}
+		return "", gitCredentials{}, &ctrl.Result{RequeueAfter: 1 * time.Minute}
+	}
+
+	latestCommit := resolved.commit
+	sourceChanged := gitshipApp.Status.ResolvedTag != resolved.tag ||
+		gitshipApp.Status.AuthMethod != resolved.authMethod ||
+		(resolved.defaultBranch != "" && gitshipApp.Status.DefaultBranch != resolved.defaultBranch)
+	credentialsCleared := clearCredentialsInvalid(gitshipApp)
+	resolvedChanged := setCondition(gitshipApp, gitshipiov1alpha1.ConditionSourceResolved, metav1.ConditionTrue, "Resolved", latestCommit)
+	if clearSourceMissing(gitshipApp) || credentialsCleared || resolvedChanged || sourceChanged {
+		gitshipApp.Status.ResolvedTag = resolved.tag
+		gitshipApp.Status.AuthMethod = resolved.authMethod
+		if resolved.defaultBranch != "" {
+			gitshipApp.Status.DefaultBranch = resolved.defaultBranch
+		}
+		if err := r.Status().Update(ctx, gitshipApp); err != nil {
+			log.Error(err, "Failed to update resolved source")
+		}
 	}
 
-	// SUCCESS: Clear AuthError if it was set
-	if gitshipApp.Status.Phase == "AuthError" {
+	// SUCCESS: Clear AuthError or CredentialError if it was set
+	if gitshipApp.Status.Phase == "AuthError" || gitshipApp.Status.Phase == phaseCredentialError {
 		log.Info("Connection successful, clearing AuthError")
 		gitshipApp.Status.Phase = phaseRunning
 		if err := r.Status().Update(ctx, gitshipApp); err != nil {
@@ -774,19 +1002,22 @@ func (r *GitshipAppReconciler) resolveAuthAndCommit(ctx context.Context, gitship
 		}
 	}
 
-	return latestCommit, privateKey, githubToken, nil
+	return latestCommit, creds, nil
 }
 
-func (r *GitshipAppReconciler) ensureBuildJob(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp, jobName, latestCommit, privateKey string, isRebuild bool) error {
+func (r *GitshipAppReconciler) ensureBuildJob(ctx context.Context, gitshipApp *gitshipiov1alpha1.GitshipApp, jobName, latestCommit string, creds gitCredentials, isRebuild bool) error {
 	log.Info("Starting build job", "job", jobName, "rebuild", isRebuild)
 
-	pushImage, _ := r.resolveImageNames(gitshipApp, latestCommit)
+	pushImage, pullImage := r.imageNames(gitshipApp, latestCommit)
 	cacheRepo := strings.Split(pushImage, ":")[0] + "-cache"
 
-	kanikoArgs := []string{
-		"--dockerfile=Dockerfile",
-		"--context=dir:///workspace",
-		"--destination=" + pushImage,
+	kanikoArgs, kanikoEnv := kanikoBuildArgs(gitshipApp.Spec.Build)
+	// The digest is read back from the container's termination message to pin deployments to it
+	kanikoArgs = append(kanikoArgs, "--destination="+pushImage, "--digest-file=/dev/termination-log")
+	if tag := gitshipApp.Status.ResolvedTag; tag != "" {
+		// Also published under the git tag, deployments use it once the build succeeded
+		tagImage, _ := r.imageNames(gitshipApp, imageTag(tag))
+		kanikoArgs = append(kanikoArgs, "--destination="+tagImage)
 	}
 
 	if !isRebuild {
@@ -826,30 +1057,35 @@ func (r *GitshipAppReconciler) ensureBuildJob(ctx context.Context, gitshipApp *g
 	volumes := []corev1.Volume{{Name: "workspace", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}}
 
 	var initEnv []corev1.EnvVar
-	if err := r.Get(ctx, types.NamespacedName{Name: "gitship-github-token", Namespace: gitshipApp.Namespace}, &corev1.Secret{}); err == nil {
+	if gitshipApp.Spec.Source.Type == sourcePullRequest {
+		initEnv = append(initEnv, corev1.EnvVar{Name: "FETCH_REF", Value: pullRequestRef(gitshipApp.Spec.Source.Value)})
+	}
+	tokenSecretName := creds.tokenSecret
+	if creds.token != "" && !creds.tokenExpiry.IsZero() {
+		var err error
+		if tokenSecretName, err = r.ensureBuildTokenSecret(ctx, gitshipApp, jobName, creds); err != nil {
+			return err
+		}
+	}
+	if creds.token != "" {
 		initEnv = append(initEnv, corev1.EnvVar{
 			Name: "GITHUB_TOKEN",
 			ValueFrom: &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{Name: "gitship-github-token"},
-					Key:                  "token",
+					LocalObjectReference: corev1.LocalObjectReference{Name: tokenSecretName},
+					Key:                  tokenKey,
 				},
 			},
 		})
 	}
+	if creds.tokenUser != "" {
+		initEnv = append(initEnv, corev1.EnvVar{Name: "GIT_USERNAME", Value: creds.tokenUser})
+	}
 
-	gitCloneCmd := `
-		if [ -n "$GITHUB_TOKEN" ]; then 
-			REPO_URL=$(echo $REPO_URL | sed "s/https:\/\//https:\/\/oauth2:$GITHUB_TOKEN@/"); 
-		fi; 
-		git clone $REPO_URL /workspace && cd /workspace && git checkout $COMMIT_ID
-	`
-
-	if privateKey != "" {
-		sshSecretName := fmt.Sprintf("%s-ssh-key", gitshipApp.Name)
-		sshUrl := gitshipApp.Spec.RepoURL
-		if strings.HasPrefix(sshUrl, "https://github.com/") {
-			sshUrl = strings.Replace(sshUrl, "https://github.com/", "git@github.com:", 1)
+	if creds.privateKey != "" {
+		knownHostsName, err := r.ensureKnownHostsConfigMap(ctx, gitshipApp, creds.knownHosts)
+		if err != nil {
+			return err
 		}
 
 		volumeMounts = append(volumeMounts, corev1.VolumeMount{Name: "ssh-key", MountPath: "/etc/ssh-key", ReadOnly: true})
@@ -857,27 +1093,21 @@ func (r *GitshipAppReconciler) ensureBuildJob(ctx context.Context, gitshipApp *g
 			Name: "ssh-key",
 			VolumeSource: corev1.VolumeSource{
 				Secret: &corev1.SecretVolumeSource{
-					SecretName:  sshSecretName,
+					SecretName:  creds.sshSecret,
+					Items:       []corev1.KeyToPath{{Key: sshPrivateKeyKey, Path: sshPrivateKeyKey}},
 					DefaultMode: func(i int32) *int32 { return &i }(0400),
 				},
 			},
 		})
-
-		gitCloneCmd = fmt.Sprintf(`
-			mkdir -p /root/.ssh && 
-			cp /etc/ssh-key/ssh-privatekey /root/.ssh/id_rsa && 
-			chmod 600 /root/.ssh/id_rsa && 
-			export GIT_SSH_COMMAND="ssh -i /root/.ssh/id_rsa -o UserKnownHostsFile=/dev/null -o StrictHostKeyChecking=no" && 
-			if git clone %s /workspace; then 
-				cd /workspace && git checkout $COMMIT_ID; 
-			else 
-				if [ -n "$GITHUB_TOKEN" ]; then 
-					REPO_URL=$(echo $REPO_URL | sed "s/https:\/\//https:\/\/oauth2:$GITHUB_TOKEN@/"); 
-				fi; 
-				git clone $REPO_URL /workspace && cd /workspace && git checkout $COMMIT_ID; 
-			fi
-		`, sshUrl)
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{Name: "known-hosts", MountPath: knownHostsMountPath, ReadOnly: true})
+		volumes = append(volumes, corev1.Volume{
+			Name: "known-hosts",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: knownHostsName}},
+			},
+		})
 	}
+	gitCloneCmd := cloneScript(creds, gitshipApp.Spec.RepoURL, gitshipApp.Spec.Build)
 
 	newJob := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
@@ -895,15 +1125,13 @@ func (r *GitshipAppReconciler) ensureBuildJob(ctx context.Context, gitshipApp *g
 					InitContainers: []corev1.Container{{
 						Name: "git-clone", Image: r.Config.ImageGit,
 						Command: []string{"/bin/sh", "-c", gitCloneCmd},
-						Env: append([]corev1.EnvVar{
-							{Name: "REPO_URL", Value: gitshipApp.Spec.RepoURL},
-							{Name: "COMMIT_ID", Value: latestCommit},
-						}, initEnv...),
+						Env: append(append(cloneEnv(creds, gitshipApp.Spec.RepoURL),
+							corev1.EnvVar{Name: "COMMIT_ID", Value: latestCommit}), initEnv...),
 						VolumeMounts: volumeMounts, Resources: buildResources,
-					}},
+					}, r.detectContainer(gitshipApp.Spec.Build, volumeMounts, buildResources)},
 					Containers: []corev1.Container{{
-						Name: "kaniko", Image: r.Config.ImageKaniko,
-						Args: kanikoArgs, VolumeMounts: volumeMounts, Resources: buildResources,
+						Name: kanikoContainerName, Image: r.Config.ImageKaniko,
+						Args: kanikoArgs, Env: kanikoEnv, VolumeMounts: volumeMounts, Resources: buildResources,
 					}},
 					Volumes: volumes,
 				},
@@ -915,11 +1143,31 @@ func (r *GitshipAppReconciler) ensureBuildJob(ctx context.Context, gitshipApp *g
 		return err
 	}
 
+	// LatestBuildID is only advanced once the Job succeeds, so the Deployment
+	// keeps running the previous image while the build is in progress.
 	gitshipApp.Status.Phase = "Building"
-	gitshipApp.Status.LatestBuildID = latestCommit
+	gitshipApp.Status.ObservedGeneration = gitshipApp.Generation
+	setCondition(gitshipApp, gitshipiov1alpha1.ConditionBuildSucceeded, metav1.ConditionUnknown, "Building", fmt.Sprintf("Building commit %s", latestCommit))
 	_ = r.Status().Update(ctx, gitshipApp)
 
-	return r.Create(ctx, newJob)
+	if err := r.createBuildRun(ctx, gitshipApp, jobName, latestCommit, pullImage, isRebuild); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, newJob); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		// Leave no build behind that would fail for want of its Job
+		build := &gitshipiov1alpha1.GitshipBuild{ObjectMeta: metav1.ObjectMeta{Name: jobName, Namespace: gitshipApp.Namespace}}
+		if delErr := r.Delete(ctx, build); client.IgnoreNotFound(delErr) != nil {
+			log.Error(delErr, "Failed to delete build of a Job that wasn't created", "build", jobName)
+		}
+		return err
+	}
+	if tokenSecretName != creds.tokenSecret {
+		return r.adoptBuildTokenSecret(ctx, tokenSecretName, newJob)
+	}
+	return nil
 }
 
 func (r *GitshipAppReconciler) recordBuild(app *gitshipiov1alpha1.GitshipApp, commit string, status string, message string) {
@@ -929,7 +1177,10 @@ func (r *GitshipAppReconciler) recordBuild(app *gitshipiov1alpha1.GitshipApp, co
 		CompletionTime: metav1.Now().Format(time.RFC3339),
 		Message:        message,
 	}
+	r.appendBuildRecord(app, record)
+}
 
+func (r *GitshipAppReconciler) appendBuildRecord(app *gitshipiov1alpha1.GitshipApp, record gitshipiov1alpha1.BuildRecord) {
 	// Keep last 10 builds
 	history := append([]gitshipiov1alpha1.BuildRecord{record}, app.Status.BuildHistory...)
 	if len(history) > 10 {
@@ -938,79 +1189,153 @@ func (r *GitshipAppReconciler) recordBuild(app *gitshipiov1alpha1.GitshipApp, co
 	app.Status.BuildHistory = history
 }
 
-func resolveLatestCommit(repoURL string, source gitshipiov1alpha1.SourceConfig, privateKey string, token string) (string, error) {
+// resolvedSource is what an app's source resolved to on the remote.
+type resolvedSource struct {
+	commit string
+	// Tag a tag source resolved to
+	tag string
+	// Branch the remote's HEAD points to, "" if the remote doesn't advertise it
+	defaultBranch string
+	// How the remote was reached: "ssh", "token" or "anonymous"
+	authMethod string
+}
+
+// resolveLatestCommit returns the commit the source points to. Sources without
+// a branch track the remote's default branch.
+func resolveLatestCommit(repoURL string, source gitshipiov1alpha1.SourceConfig, creds gitCredentials) (resolvedSource, error) {
 	if source.Type == "commit" {
-		return source.Value, nil
+		return resolvedSource{commit: source.Value}, nil
 	}
 
-	tryFetch := func(url string, auth transport.AuthMethod) (string, error) {
+	tryFetch := func(url string, auth transport.AuthMethod) (resolvedSource, error) {
 		rem := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{url}})
 		refs, err := rem.List(&git.ListOptions{Auth: auth})
 		if err != nil {
-			return "", err
+			return resolvedSource{}, err
 		}
-		target := headRef
-		if source.Type == "branch" && source.Value != "" && source.Value != headRef {
+		resolved := resolvedSource{defaultBranch: defaultBranch(refs)}
+		var target string
+		switch {
+		case source.Type == "branch" && source.Value != "" && source.Value != headRef:
 			target = "refs/heads/" + source.Value
-		} else if source.Type == "tag" {
-			target = "refs/tags/" + source.Value
+		case source.Type == "tag":
+			if resolved.tag, err = resolveTag(source.Value, refs); err != nil {
+				return resolvedSource{}, err
+			}
+			target = "refs/tags/" + resolved.tag
+		case source.Type == sourcePullRequest:
+			target = pullRequestRef(source.Value)
+		case resolved.defaultBranch != "":
+			target = "refs/heads/" + resolved.defaultBranch
+		default:
+			target = headRef
 		}
 		for _, ref := range refs {
-			if ref.Name().String() == target {
-				return ref.Hash().String(), nil
-			}
-			if target == headRef && (ref.Name().String() == "refs/heads/main" || ref.Name().String() == "refs/heads/master") {
-				return ref.Hash().String(), nil
+			if ref.Name().String() == target && ref.Type() == plumbing.HashReference {
+				resolved.commit = ref.Hash().String()
+				return resolved, nil
 			}
 		}
-		return "", fmt.Errorf("ref %s not found", target)
+		return resolvedSource{}, fmt.Errorf("%w: %s", errRefNotFound, target)
 	}
 
 	var lastErr error
-	if privateKey != "" {
-		sshUrl := repoURL
-		if strings.Contains(sshUrl, "github.com") && !strings.HasPrefix(sshUrl, "git@") {
-			trimmed := strings.TrimPrefix(sshUrl, "https://github.com/")
-			trimmed = strings.TrimPrefix(trimmed, "http://github.com/")
-			sshUrl = "git@github.com:" + trimmed
-		}
-		publicKeys, err := ssh.NewPublicKeys("git", []byte(privateKey), "")
+	for _, remote := range remoteCandidates(repoURL, creds) {
+		resolved, err := tryFetch(remote.url, remote.auth)
 		if err == nil {
-			publicKeys.HostKeyCallback = golang_ssh.InsecureIgnoreHostKey()
-			hash, err := tryFetch(sshUrl, publicKeys)
-			if err == nil {
-				return hash, nil
-			}
-			lastErr = fmt.Errorf("SSH failed: %w", err)
+			resolved.authMethod = remote.method
+			return resolved, nil
+		}
+		if lastErr == nil {
+			lastErr = fmt.Errorf("%s failed: %w", remote.method, err)
+		} else {
+			lastErr = fmt.Errorf("%s failed: %w (prev: %v)", remote.method, err, lastErr)
 		}
 	}
+	return resolvedSource{}, fmt.Errorf("all auth methods failed. Last error: %w", lastErr)
+}
+
+type remoteCandidate struct {
+	method string
+	url    string
+	auth   transport.AuthMethod
+}
 
-	if token != "" {
-		basicAuth := &http.BasicAuth{Username: "oauth2", Password: token}
-		hash, err := tryFetch(repoURL, basicAuth)
+// remoteCandidates returns the ways to reach the repository allowed by the
+// auth method, in the order they should be tried: SSH deploy key, token, then
+// anonymous.
+func remoteCandidates(repoURL string, creds gitCredentials) []remoteCandidate {
+	var candidates []remoteCandidate
+	if creds.privateKey != "" {
+		sshUrl := creds.sshURL
+		if sshUrl == "" {
+			sshUrl = repoURL
+		}
+		publicKeys, err := ssh.NewPublicKeys("git", []byte(creds.privateKey), "")
 		if err == nil {
-			return hash, nil
+			publicKeys.HostKeyCallback, err = hostKeyCallback(creds.knownHosts)
+		}
+		if err == nil {
+			candidates = append(candidates, remoteCandidate{method: authMethodSSH, url: sshUrl, auth: publicKeys})
+		} else {
+			log.Error(err, "Skipping SSH authentication")
 		}
-		lastErr = fmt.Errorf("token failed: %w (prev: %v)", err, lastErr)
 	}
 
-	hash, err := tryFetch(repoURL, nil)
-	if err == nil {
-		return hash, nil
+	if creds.token != "" {
+		username := creds.tokenUser
+		if username == "" {
+			username = "oauth2"
+		}
+		basicAuth := &http.BasicAuth{Username: username, Password: creds.token}
+		candidates = append(candidates, remoteCandidate{method: authMethodToken, url: repoURL, auth: basicAuth})
 	}
-	return "", fmt.Errorf("all auth methods failed. Last error: %w (prev: %v)", err, lastErr)
+
+	// Without a token, the token method clones anonymously
+	if creds.method == authMethodSSH || (creds.method == authMethodToken && creds.token != "") {
+		return candidates
+	}
+	return append(candidates, remoteCandidate{method: authMethodAnonymous, url: repoURL})
 }
 
 func (r *GitshipAppReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Config.RegistryRetainBuilds > 0 && r.Config.RegistryGCInterval > 0 {
+		if err := mgr.Add(manager.RunnableFunc(r.runRegistryRetention)); err != nil {
+			return err
+		}
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&gitshipiov1alpha1.GitshipApp{}).
 		Owns(&appsv1.Deployment{}).
 		Owns(&corev1.Service{}).
 		Owns(&networkingv1.Ingress{}).
+		Owns(&batchv1.Job{}).
 		Complete(r)
 }
 
+// resolveImageNames returns the image built from commit, tagged with the git
+// tag it was built for if any and with the commit otherwise, and pinned to the
+// build's digest when known. For image sources commit is the digest of the
+// prebuilt image.
 func (r *GitshipAppReconciler) resolveImageNames(app *gitshipiov1alpha1.GitshipApp, commit string) (pushImage, pullImage string) {
+	if app.Spec.Image != nil {
+		// Prebuilt images are deployed by digest and never pushed
+		return "", imageSourceRef(app, commit)
+	}
+	tag := commit
+	if gitTag := buildTag(app, commit); gitTag != "" {
+		tag = imageTag(gitTag)
+	}
+	pushImage, pullImage = r.imageNames(app, tag)
+	if digest := buildDigest(app, commit); digest != "" {
+		// Pinned so that re-pushing the tag doesn't change what runs
+		pullImage += "@" + digest
+	}
+	return pushImage, pullImage
+}
+
+// imageNames returns the push and pull references of the app's image with tag.
+func (r *GitshipAppReconciler) imageNames(app *gitshipiov1alpha1.GitshipApp, tag string) (pushImage, pullImage string) {
 	baseName := strings.ToLower(app.Spec.ImageName)
 	if idx := strings.LastIndex(baseName, ":"); idx != -1 {
 		afterColon := baseName[idx+1:]
@@ -1034,10 +1359,10 @@ func (r *GitshipAppReconciler) resolveImageNames(app *gitshipiov1alpha1.GitshipA
 			}
 		}
 
-		pushImage = fmt.Sprintf("%s/%s:%s", pushRepo, baseName, commit)
-		pullImage = fmt.Sprintf("%s/%s:%s", pullRepo, baseName, commit)
+		pushImage = fmt.Sprintf("%s/%s:%s", pushRepo, baseName, tag)
+		pullImage = fmt.Sprintf("%s/%s:%s", pullRepo, baseName, tag)
 	} else {
-		pushImage = fmt.Sprintf("%s:%s", baseName, commit)
+		pushImage = fmt.Sprintf("%s:%s", baseName, tag)
 		pullImage = pushImage
 	}
 	return pushImage, pullImage
@@ -202,8 +202,11 @@ func (r *GitshipUserReconciler) ensureRegistrySecret(ctx context.Context, namesp
 	return nil
 }
 
+// userQuotaName is the ResourceQuota enforcing a user's quotas in each of their namespaces.
+const userQuotaName = "user-quota"
+
 func (r *GitshipUserReconciler) ensureResourceQuota(ctx context.Context, namespace string, quotas gitshipiov1alpha1.UserQuotas) error {
-	quotaName := "user-quota"
+	quotaName := userQuotaName
 
 	// Use defaults from config if not set in CRD
 	cpu := quotas.CPU
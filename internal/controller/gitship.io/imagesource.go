@@ -0,0 +1,151 @@
+package gitshipio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/gitutil"
+	"github.com/gitshipio/gitship/internal/registry"
+)
+
+// Image source policies
+const (
+	imagePolicyExact  = "exact"
+	imagePolicySemver = "semver"
+	imagePolicyDigest = "digest"
+)
+
+// errNoMatchingTag is returned when no tag of the image repository satisfies
+// the semver constraint.
+var errNoMatchingTag = errors.New("no tag matches")
+
+// reconcileImageSource deploys the digest the app's image source resolves
+// to. A new digest is recorded in the build history like a finished build,
+// so that rollbacks can return to earlier digests.
+func (r *GitshipAppReconciler) reconcileImageSource(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) (ctrl.Result, error) {
+	source := app.Spec.Image
+	tag, digest, err := r.resolveImageSource(ctx, app)
+	if errors.Is(err, registry.ErrNotFound) || errors.Is(err, errNoMatchingTag) {
+		log.Info("Image not found", "repository", source.Repository, "policy", source.Policy, "tag", source.Tag)
+		resolvedChanged := setCondition(app, gitshipiov1alpha1.ConditionSourceResolved, metav1.ConditionFalse, "ImageNotFound", err.Error())
+		if setSourceMissing(app, "ImageNotFound", err.Error()) || resolvedChanged {
+			_ = r.Status().Update(ctx, app)
+		}
+		if app.Status.LatestBuildID == "" {
+			return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+		}
+		// Keep running the last image
+		return r.deploy(ctx, app, deployCommit(app))
+	}
+	if err != nil {
+		log.Error(err, "Failed to resolve image", "repository", source.Repository)
+		if setCondition(app, gitshipiov1alpha1.ConditionSourceResolved, metav1.ConditionFalse, "ResolveFailed", err.Error()) {
+			_ = r.Status().Update(ctx, app)
+		}
+		return ctrl.Result{RequeueAfter: 1 * time.Minute}, nil
+	}
+
+	changed := clearSourceMissing(app)
+	if setCondition(app, gitshipiov1alpha1.ConditionSourceResolved, metav1.ConditionTrue, "Resolved", imageSourceRef(app, digest)) {
+		changed = true
+	}
+	// Nothing is built, the image is ready to run
+	if setCondition(app, gitshipiov1alpha1.ConditionBuildSucceeded, metav1.ConditionTrue, "PrebuiltImage", imageSourceRef(app, digest)) {
+		changed = true
+	}
+	if app.Status.LatestBuildID != digest {
+		log.Info("New image digest", "repository", source.Repository, "tag", tag, "digest", digest)
+		r.appendBuildRecord(app, gitshipiov1alpha1.BuildRecord{
+			CommitID:       digest,
+			Status:         buildPhaseSucceeded,
+			CompletionTime: metav1.Now().Format(time.RFC3339),
+			Message:        "Image " + imageSourceRef(app, digest),
+			Tag:            tag,
+		})
+		app.Status.LatestBuildID = digest
+		changed = true
+	}
+	if app.Status.ResolvedTag != tag {
+		app.Status.ResolvedTag = tag
+		changed = true
+	}
+	if changed {
+		if err := r.Status().Update(ctx, app); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return r.deploy(ctx, app, deployCommit(app))
+}
+
+// resolveImageSource returns the tag, if any, and the digest the app's image
+// source currently points to.
+func (r *GitshipAppReconciler) resolveImageSource(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) (string, string, error) {
+	source := app.Spec.Image
+	ref, err := registry.ParseReference(imageRepository(source.Repository))
+	if err != nil {
+		return "", "", err
+	}
+	client, err := r.registryClient(ctx, app)
+	if err != nil {
+		return "", "", err
+	}
+
+	tag := source.Tag
+	switch source.Policy {
+	case imagePolicyDigest:
+		if source.Digest == "" {
+			return "", "", fmt.Errorf("image source %s has no digest", source.Repository)
+		}
+		ref.Tag, ref.Digest = "", source.Digest
+		if _, err := client.ManifestDigest(ctx, ref); err != nil {
+			return "", "", err
+		}
+		return "", source.Digest, nil
+	case imagePolicySemver:
+		tags, err := client.Tags(ctx, ref)
+		if err != nil {
+			return "", "", err
+		}
+		if tag, err = gitutil.HighestSemverTag(source.Tag, tags); err != nil {
+			return "", "", err
+		}
+		if tag == "" {
+			return "", "", fmt.Errorf("%w %q in %s", errNoMatchingTag, source.Tag, source.Repository)
+		}
+	default:
+		if tag == "" {
+			tag = "latest"
+		}
+	}
+
+	ref.Tag = tag
+	digest, err := client.ManifestDigest(ctx, ref)
+	if err != nil {
+		return "", "", err
+	}
+	if digest == "" {
+		return "", "", fmt.Errorf("registry returned no digest for %s", ref)
+	}
+	return tag, digest, nil
+}
+
+// imageRepository strips a tag or digest from an image repository.
+func imageRepository(repository string) string {
+	repository, _, _ = strings.Cut(repository, "@")
+	if i := strings.LastIndex(repository, ":"); i != -1 && !strings.Contains(repository[i+1:], "/") {
+		repository = repository[:i]
+	}
+	return repository
+}
+
+// imageSourceRef returns the reference of the app's image with digest.
+func imageSourceRef(app *gitshipiov1alpha1.GitshipApp, digest string) string {
+	return imageRepository(app.Spec.Image.Repository) + "@" + digest
+}
@@ -0,0 +1,103 @@
+package gitshipio
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/registry"
+)
+
+// imageSourceApp returns an app following team/app of a fake registry.
+func imageSourceApp(t *testing.T) (*GitshipAppReconciler, *gitshipiov1alpha1.GitshipApp) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/team/app/tags/list", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"name":"team/app","tags":["latest","v1.0.0","v1.2.0","v2.0.0-rc.1","v2.0.0"]}`)
+	})
+	mux.HandleFunc("/v2/team/app/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		ref := strings.TrimPrefix(r.URL.Path, "/v2/team/app/manifests/")
+		if ref == "v9" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if strings.HasPrefix(ref, "sha256:") {
+			w.Header().Set("Docker-Content-Digest", ref)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", "sha256:"+strings.ReplaceAll(ref, ".", ""))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	app := credentialsApp("", "")
+	app.Spec.RepoURL = ""
+	app.Spec.Image = &gitshipiov1alpha1.ImageSource{Repository: strings.TrimPrefix(server.URL, "http://") + "/team/app"}
+	return credentialsReconciler(), app
+}
+
+func TestResolveImageSourceExactTag(t *testing.T) {
+	g := NewWithT(t)
+	r, app := imageSourceApp(t)
+	app.Spec.Image.Policy = imagePolicyExact
+	app.Spec.Image.Tag = "v1.0.0"
+	tag, digest, err := r.resolveImageSource(context.Background(), app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tag).To(Equal("v1.0.0"))
+	g.Expect(digest).To(Equal("sha256:v100"))
+
+	app.Spec.Image.Tag = ""
+	tag, _, err = r.resolveImageSource(context.Background(), app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tag).To(Equal("latest"))
+}
+
+func TestResolveImageSourceSemver(t *testing.T) {
+	g := NewWithT(t)
+	r, app := imageSourceApp(t)
+	app.Spec.Image.Policy = imagePolicySemver
+	app.Spec.Image.Tag = "^1"
+	tag, digest, err := r.resolveImageSource(context.Background(), app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tag).To(Equal("v1.2.0"))
+	g.Expect(digest).To(Equal("sha256:v120"))
+
+	app.Spec.Image.Tag = ">=3"
+	_, _, err = r.resolveImageSource(context.Background(), app)
+	g.Expect(err).To(MatchError(errNoMatchingTag))
+}
+
+func TestResolveImageSourceDigest(t *testing.T) {
+	g := NewWithT(t)
+	r, app := imageSourceApp(t)
+	app.Spec.Image.Policy = imagePolicyDigest
+	app.Spec.Image.Digest = "sha256:abc"
+	tag, digest, err := r.resolveImageSource(context.Background(), app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tag).To(BeEmpty())
+	g.Expect(digest).To(Equal("sha256:abc"))
+
+	_, image := r.resolveImageNames(app, digest)
+	g.Expect(image).To(Equal(app.Spec.Image.Repository + "@sha256:abc"))
+}
+
+func TestResolveImageSourceMissingTag(t *testing.T) {
+	g := NewWithT(t)
+	r, app := imageSourceApp(t)
+	app.Spec.Image.Tag = "v9"
+	_, _, err := r.resolveImageSource(context.Background(), app)
+	g.Expect(err).To(MatchError(registry.ErrNotFound))
+}
+
+func TestImageRepository(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(imageRepository("ghcr.io/team/app:v1")).To(Equal("ghcr.io/team/app"))
+	g.Expect(imageRepository("localhost:5000/team/app@sha256:abc")).To(Equal("localhost:5000/team/app"))
+	g.Expect(imageRepository("localhost:5000/team/app")).To(Equal("localhost:5000/team/app"))
+}
@@ -0,0 +1,143 @@
+package gitshipio
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	golang_ssh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+const (
+	// knownHostsConfigMap in the system namespace holds the SSH host keys of
+	// the git hosts apps are cloned from
+	knownHostsConfigMap = "gitship-known-hosts"
+	knownHostsKey       = "known_hosts"
+	// knownHostsMountPath is where the app's known_hosts are mounted in the git-clone container
+	knownHostsMountPath = "/etc/ssh-known-hosts"
+)
+
+// knownHosts returns the known_hosts entries of the cluster with the app's own
+// entries replacing those of the same hosts.
+func (r *GitshipAppReconciler) knownHosts(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) (string, error) {
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: knownHostsConfigMap, Namespace: r.Config.SystemNamespace}, cm)
+	// Without the cluster ConfigMap only the app's own entries are trusted
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	return mergeKnownHosts(cm.Data[knownHostsKey], app.Spec.KnownHosts), nil
+}
+
+// mergeKnownHosts returns the cluster entries of hosts that have no entry in
+// override, followed by the override entries.
+func mergeKnownHosts(cluster, override string) string {
+	overridden := map[string]bool{}
+	for _, line := range knownHostsLines(override) {
+		for _, host := range entryHosts(line) {
+			overridden[host] = true
+		}
+	}
+
+	var lines []string
+	for _, line := range knownHostsLines(cluster) {
+		kept := false
+		for _, host := range entryHosts(line) {
+			kept = kept || !overridden[host]
+		}
+		if kept {
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, knownHostsLines(override)...)
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// knownHostsLines returns the entries of a known_hosts file.
+func knownHostsLines(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// entryHosts returns the host patterns of a known_hosts entry, skipping a
+// leading marker such as @cert-authority.
+func entryHosts(line string) []string {
+	fields := strings.Fields(line)
+	if strings.HasPrefix(fields[0], "@") && len(fields) > 1 {
+		fields = fields[1:]
+	}
+	return strings.Split(fields[0], ",")
+}
+
+// hostKeyCallback verifies SSH host keys against known_hosts entries. Hosts
+// without an entry are rejected.
+func hostKeyCallback(knownHostsContent string) (golang_ssh.HostKeyCallback, error) {
+	// knownhosts only reads files
+	f, err := os.CreateTemp("", "known_hosts")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+	if _, err := f.WriteString(knownHostsContent); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	callback, err := knownhosts.New(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("invalid known_hosts: %w", err)
+	}
+	return callback, nil
+}
+
+// ensureKnownHostsConfigMap stores the app's known_hosts in its namespace so
+// that the build Job can mount them. It returns the ConfigMap's name.
+func (r *GitshipAppReconciler) ensureKnownHostsConfigMap(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, content string) (string, error) {
+	name := fmt.Sprintf("%s-known-hosts", app.Name)
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	if err != nil {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: app.Namespace,
+				Labels:    map[string]string{"gitship.io/app": app.Name},
+			},
+			Data: map[string]string{knownHostsKey: content},
+		}
+		if err := ctrl.SetControllerReference(app, cm, r.Scheme); err != nil {
+			return "", err
+		}
+		return name, r.Create(ctx, cm)
+	}
+	if cm.Data[knownHostsKey] != content {
+		cm.Data = map[string]string{knownHostsKey: content}
+		return name, r.Update(ctx, cm)
+	}
+	return name, nil
+}
@@ -0,0 +1,39 @@
+package gitshipio
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	golang_ssh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestMergeKnownHosts(t *testing.T) {
+	g := NewWithT(t)
+	cluster := "# seeded\ngithub.com ssh-ed25519 AAAA1\ngitlab.com ssh-ed25519 AAAA2\n"
+	app := "github.com ssh-ed25519 AAAA3\n"
+	g.Expect(mergeKnownHosts(cluster, app)).To(Equal("gitlab.com ssh-ed25519 AAAA2\ngithub.com ssh-ed25519 AAAA3\n"))
+	g.Expect(mergeKnownHosts("", "")).To(BeEmpty())
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	g := NewWithT(t)
+	newKey := func() golang_ssh.PublicKey {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		g.Expect(err).NotTo(HaveOccurred())
+		key, err := golang_ssh.NewPublicKey(pub)
+		g.Expect(err).NotTo(HaveOccurred())
+		return key
+	}
+	trusted, other := newKey(), newKey()
+	callback, err := hostKeyCallback(knownhosts.Line([]string{"git.example.com"}, trusted))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22}
+	g.Expect(callback("git.example.com:22", addr, trusted)).To(Succeed())
+	g.Expect(callback("git.example.com:22", addr, other)).NotTo(Succeed())
+	g.Expect(callback("other.example.com:22", addr, trusted)).NotTo(Succeed())
+}
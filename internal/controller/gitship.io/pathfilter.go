@@ -0,0 +1,147 @@
+package gitshipio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/gitutil"
+)
+
+// pathFilterDepths are the history depths fetched, in turn, to reach the last
+// built commit. Commits further behind are built without filtering.
+var pathFilterDepths = []int{50, 1000}
+
+// skipByPathFilter reports whether latestCommit can be skipped because none of
+// the files changed since the last successful build match the app's path
+// filters. Either outcome is recorded in the app status, so that each commit
+// is only fetched once. Any error while computing the diff results in a build.
+func (r *GitshipAppReconciler) skipByPathFilter(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, latestCommit string, creds gitCredentials) bool {
+	filter := app.Spec.Paths
+	if len(filter.Include) == 0 && len(filter.Exclude) == 0 {
+		return false
+	}
+	if app.Status.LatestBuildID == "" || app.Spec.Source.Type == "commit" || app.Status.PathsMatchedCommit == latestCommit {
+		return false
+	}
+
+	files, err := changedFiles(app.Spec.RepoURL, sourceRefName(app.Spec.Source), creds, app.Status.LatestBuildID, latestCommit)
+	if err != nil {
+		log.Error(err, "Failed to compute changed files, building anyway", "app", app.Name)
+	}
+	if err != nil || gitutil.MatchesPathFilter(filter.Include, filter.Exclude, files) {
+		app.Status.PathsMatchedCommit = latestCommit
+		if err := r.Status().Update(ctx, app); err != nil {
+			log.Error(err, "Failed to record path filter match", "app", app.Name)
+		}
+		return false
+	}
+
+	log.Info("No changed file matches the path filters, skipping build", "app", app.Name, "commit", latestCommit, "changedFiles", len(files))
+	r.recordBuild(app, latestCommit, "Skipped", fmt.Sprintf("No matching paths changed (%d files changed)", len(files)))
+	app.Status.SkippedCommit = latestCommit
+	if err := r.Status().Update(ctx, app); err != nil {
+		log.Error(err, "Failed to record skipped build", "app", app.Name)
+	}
+	return true
+}
+
+// sourceRefName returns the ref to fetch for the source, or "" for the remote HEAD.
+func sourceRefName(source gitshipiov1alpha1.SourceConfig) plumbing.ReferenceName {
+	switch source.Type {
+	case "branch":
+		if source.Value != "" && source.Value != headRef {
+			return plumbing.NewBranchReferenceName(source.Value)
+		}
+	case "tag":
+		if gitutil.IsTagPattern(source.Value) || gitutil.IsSemverConstraint(source.Value) {
+			// The tag is only known once resolved, fetch the default branch
+			return ""
+		}
+		return plumbing.NewTagReferenceName(source.Value)
+	case sourcePullRequest:
+		return plumbing.ReferenceName(pullRequestRef(source.Value))
+	}
+	return ""
+}
+
+// fetchRepository fetches the last depth commits of ref into memory without a
+// worktree.
+func fetchRepository(repoURL string, ref plumbing.ReferenceName, creds gitCredentials, depth int) (*git.Repository, error) {
+	var lastErr error
+	for _, remote := range remoteCandidates(repoURL, creds) {
+		repo, err := git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
+			URL:           remote.url,
+			Auth:          remote.auth,
+			ReferenceName: ref,
+			SingleBranch:  true,
+			NoCheckout:    true,
+			Tags:          git.NoTags,
+			Depth:         depth,
+		})
+		if err == nil {
+			return repo, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("failed to fetch repository: %w", lastErr)
+}
+
+// changedFiles lists the paths that differ between two commits. The history
+// is fetched no deeper than needed to reach from.
+func changedFiles(repoURL string, ref plumbing.ReferenceName, creds gitCredentials, from, to string) ([]string, error) {
+	var (
+		repo     *git.Repository
+		fromTree *object.Tree
+		err      error
+	)
+	for _, depth := range pathFilterDepths {
+		repo, err = fetchRepository(repoURL, ref, creds, depth)
+		if err != nil {
+			return nil, err
+		}
+		fromTree, err = commitTree(repo, from)
+		if !errors.Is(err, plumbing.ErrObjectNotFound) {
+			break
+		}
+	}
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return nil, fmt.Errorf("commit %s is more than %d commits behind", from, pathFilterDepths[len(pathFilterDepths)-1])
+	}
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := commitTree(repo, to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(changes))
+	for _, change := range changes {
+		if change.From.Name != "" {
+			files = append(files, change.From.Name)
+		}
+		if change.To.Name != "" && change.To.Name != change.From.Name {
+			files = append(files, change.To.Name)
+		}
+	}
+	return files, nil
+}
+
+func commitTree(repo *git.Repository, hash string) (*object.Tree, error) {
+	commit, err := repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, fmt.Errorf("commit %s: %w", hash, err)
+	}
+	return commit.Tree()
+}
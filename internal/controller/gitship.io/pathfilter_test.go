@@ -0,0 +1,23 @@
+package gitshipio
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestChangedFilesFetchesDeeper(t *testing.T) {
+	g := NewWithT(t)
+	dir, commits := testRepository(t, "README.md", "api/main.go", "web/app.tsx")
+
+	depths := pathFilterDepths
+	t.Cleanup(func() { pathFilterDepths = depths })
+	pathFilterDepths = []int{1, 3}
+	files, err := changedFiles(dir, "", gitCredentials{}, commits[0], commits[2])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(files).To(ConsistOf("api/main.go", "web/app.tsx"))
+
+	pathFilterDepths = []int{1}
+	_, err = changedFiles(dir, "", gitCredentials{}, commits[0], commits[2])
+	g.Expect(err).To(MatchError(ContainSubstring("more than 1 commits behind")))
+}
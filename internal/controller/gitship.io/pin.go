@@ -0,0 +1,125 @@
+package gitshipio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/registry"
+)
+
+// Stop counting after this many commits, CommitsBehind is capped at this value.
+const maxCommitsBehind = 1000
+
+// reconcilePinned runs the image of spec.pinnedCommit and reports how far the
+// tracked source has moved on since. If the pin can't be deployed the current
+// Deployment is left alone.
+func (r *GitshipAppReconciler) reconcilePinned(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, latestCommit string, creds gitCredentials) (ctrl.Result, error) {
+	commit, pinErr := r.resolvePin(ctx, app)
+	changed := app.Status.PinError != pinErr
+	app.Status.PinError = pinErr
+	if pinErr != "" {
+		log.Info("Cannot pin app", "app", app.Name, "pinnedCommit", app.Spec.PinnedCommit, "reason", pinErr)
+		if changed {
+			if err := r.Status().Update(ctx, app); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: pollInterval(app)}, nil
+	}
+
+	if app.Status.PinnedCommit != commit || app.Status.TrackedCommit != latestCommit {
+		behind, err := commitsBehind(app.Spec.RepoURL, sourceRefName(app.Spec.Source), creds, commit, latestCommit)
+		if err != nil {
+			log.Error(err, "Failed to count commits behind the pinned commit", "app", app.Name)
+		}
+		app.Status.PinnedCommit = commit
+		app.Status.TrackedCommit = latestCommit
+		app.Status.CommitsBehind = behind
+		changed = true
+	}
+	if changed {
+		if err := r.Status().Update(ctx, app); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return r.deploy(ctx, app, commit)
+}
+
+// resolvePin expands spec.pinnedCommit to a successfully built commit whose
+// image is still in the registry. The registry is only checked when the pin
+// changes.
+func (r *GitshipAppReconciler) resolvePin(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) (string, string) {
+	pinned := strings.ToLower(app.Spec.PinnedCommit)
+	if len(pinned) < 7 {
+		return "", "Pinned commit must have at least 7 characters"
+	}
+
+	commit := ""
+	for _, record := range app.Status.BuildHistory {
+		if record.Status == buildPhaseSucceeded && strings.HasPrefix(record.CommitID, pinned) {
+			commit = record.CommitID
+			break
+		}
+	}
+	if commit == "" {
+		return "", fmt.Sprintf("Commit %s has no successful build in the build history", app.Spec.PinnedCommit)
+	}
+	if commit == app.Status.PinnedCommit {
+		return commit, ""
+	}
+
+	pushImage, _ := r.resolveImageNames(app, commit)
+	if _, err := r.imageDigest(ctx, app, pushImage); err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			return "", fmt.Sprintf("Image %s is no longer in the registry", pushImage)
+		}
+		return "", fmt.Sprintf("Failed to look up image %s: %v", pushImage, err)
+	}
+	return commit, ""
+}
+
+// clearPin resets the pin status once spec.pinnedCommit is removed.
+func clearPin(app *gitshipiov1alpha1.GitshipApp) bool {
+	if app.Status.PinnedCommit == "" && app.Status.PinError == "" && app.Status.TrackedCommit == "" {
+		return false
+	}
+	app.Status.PinnedCommit = ""
+	app.Status.PinError = ""
+	app.Status.TrackedCommit = ""
+	app.Status.CommitsBehind = 0
+	return true
+}
+
+// commitsBehind counts the commits reachable from latest before pinned is met.
+func commitsBehind(repoURL string, ref plumbing.ReferenceName, creds gitCredentials, pinned, latest string) (int32, error) {
+	if pinned == latest {
+		return 0, nil
+	}
+	repo, err := fetchRepository(repoURL, ref, creds, maxCommitsBehind+1)
+	if err != nil {
+		return 0, err
+	}
+	iter, err := repo.Log(&git.LogOptions{From: plumbing.NewHash(latest)})
+	if err != nil {
+		return 0, err
+	}
+	var behind int32
+	err = iter.ForEach(func(c *object.Commit) error {
+		if c.Hash.String() == pinned || behind == maxCommitsBehind {
+			return storer.ErrStop
+		}
+		behind++
+		return nil
+	})
+	return behind, err
+}
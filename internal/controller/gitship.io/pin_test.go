@@ -0,0 +1,47 @@
+package gitshipio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/onsi/gomega"
+)
+
+// testRepository creates a repository with a commit per file, each adding the
+// file, and returns the commit hashes in order.
+func testRepository(t *testing.T, files ...string) (string, []string) {
+	t.Helper()
+	g := NewWithT(t)
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	worktree, err := repo.Worktree()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var commits []string
+	for i, name := range files {
+		g.Expect(os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644)).To(Succeed())
+		_, err := worktree.Add(name)
+		g.Expect(err).NotTo(HaveOccurred())
+		hash, err := worktree.Commit(name, &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(int64(i), 0)},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+		commits = append(commits, hash.String())
+	}
+	return dir, commits
+}
+
+func TestCommitsBehind(t *testing.T) {
+	g := NewWithT(t)
+	dir, commits := testRepository(t, "one.txt", "two.txt", "three.txt")
+
+	behind, err := commitsBehind(dir, "", gitCredentials{}, commits[0], commits[2])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(behind).To(Equal(int32(2)))
+}
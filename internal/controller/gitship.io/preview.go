@@ -0,0 +1,274 @@
+package gitshipio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+const sourcePullRequest = "pullRequest"
+
+const (
+	labelPreviewOf        = "gitship.io/preview-of"
+	labelPullRequest      = "gitship.io/pull-request"
+	annotationPreviewHead = "gitship.io/preview-head"
+	// Generation of the parent app the preview's spec was derived from
+	annotationPreviewGeneration = "gitship.io/preview-generation"
+)
+
+const defaultMaxPreviews = 3
+
+// pullRequestRef is the ref GitHub publishes the head of a pull request
+// under, also for pull requests from forks.
+func pullRequestRef(number string) string {
+	return "refs/pull/" + number + "/head"
+}
+
+// openPullRequests returns the head commit of each open pull request recorded
+// by the webhook receiver, nil when previews are disabled.
+func openPullRequests(app *gitshipiov1alpha1.GitshipApp) map[int32]string {
+	if !app.Spec.Previews.Enabled {
+		return nil
+	}
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(app.Annotations[gitshipiov1alpha1.PullRequestsAnnotation]), &raw); err != nil {
+		return nil
+	}
+	open := make(map[int32]string, len(raw))
+	for number, head := range raw {
+		if n, err := strconv.ParseInt(number, 10, 32); err == nil && n > 0 {
+			open[int32(n)] = head
+		}
+	}
+	return open
+}
+
+// reconcilePreviews creates a preview app for each open pull request, within
+// the preview limit and the namespace quota, updates them when the pull
+// request or the app changed, and deletes the previews of closed pull
+// requests. Apps the app doesn't control are never touched, even if they are
+// labeled as its previews.
+func (r *GitshipAppReconciler) reconcilePreviews(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) error {
+	if app.Spec.Source.Type == sourcePullRequest {
+		return nil
+	}
+
+	children := &gitshipiov1alpha1.GitshipAppList{}
+	if err := r.List(ctx, children, client.InNamespace(app.Namespace), client.MatchingLabels{labelPreviewOf: app.Name}); err != nil {
+		return err
+	}
+	open := openPullRequests(app)
+	existing := map[int32]*gitshipiov1alpha1.GitshipApp{}
+	for i := range children.Items {
+		child := &children.Items[i]
+		if !metav1.IsControlledBy(child, app) {
+			continue
+		}
+		number, _ := strconv.ParseInt(child.Labels[labelPullRequest], 10, 32)
+		if _, ok := open[int32(number)]; ok {
+			existing[int32(number)] = child
+			continue
+		}
+		log.Info("Deleting preview of closed pull request", "app", app.Name, "preview", child.Name)
+		if err := r.Delete(ctx, child); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	numbers := make([]int32, 0, len(open))
+	for number := range open {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	maxPreviews := int(app.Spec.Previews.MaxPreviews)
+	if maxPreviews == 0 {
+		maxPreviews = defaultMaxPreviews
+	}
+
+	var previews []gitshipiov1alpha1.PreviewStatus
+	for _, number := range numbers {
+		status := gitshipiov1alpha1.PreviewStatus{PullRequest: number, HeadCommit: open[number]}
+		child, ok := existing[number]
+		switch {
+		case ok && (child.Annotations[annotationPreviewHead] != open[number] ||
+			child.Annotations[annotationPreviewGeneration] != strconv.FormatInt(app.Generation, 10)):
+			// New commits were pushed to the pull request, or the app changed
+			desired := previewApp(app, number, open[number])
+			patch := client.MergeFrom(child.DeepCopy())
+			if child.Annotations == nil {
+				child.Annotations = map[string]string{}
+			}
+			for k, v := range desired.Annotations {
+				child.Annotations[k] = v
+			}
+			child.Spec = desired.Spec
+			if err := r.Patch(ctx, child, patch); err != nil {
+				return err
+			}
+		case !ok && len(existing) >= maxPreviews:
+			status.Message = fmt.Sprintf("Preview limit of %d reached", maxPreviews)
+		case !ok:
+			child = previewApp(app, number, open[number])
+			message, err := r.previewQuotaExceeded(ctx, child)
+			if err != nil {
+				return err
+			}
+			if message != "" {
+				status.Message = message
+				break
+			}
+			if err := ctrl.SetControllerReference(app, child, r.Scheme); err != nil {
+				return err
+			}
+			log.Info("Creating preview", "app", app.Name, "preview", child.Name, "pullRequest", number)
+			err = r.Create(ctx, child)
+			if apierrors.IsAlreadyExists(err) {
+				err = r.Get(ctx, client.ObjectKeyFromObject(child), child)
+				if err == nil && !metav1.IsControlledBy(child, app) {
+					status.Message = fmt.Sprintf("App %s already exists", child.Name)
+					break
+				}
+			}
+			if err != nil {
+				return err
+			}
+			existing[number] = child
+		}
+		if status.Message == "" {
+			status.AppName = child.Name
+			status.URL = previewURL(child)
+		}
+		previews = append(previews, status)
+	}
+
+	if !reflect.DeepEqual(app.Status.Previews, previews) {
+		app.Status.Previews = previews
+		return r.Status().Update(ctx, app)
+	}
+	return nil
+}
+
+// previewApp returns the preview of pull request number: a single replica of
+// the parent app built from the pull request head, served on hostnames
+// derived from the parent's. The parent's Secrets are left out unless it
+// opted in with previews.includeSecrets.
+func previewApp(parent *gitshipiov1alpha1.GitshipApp, number int32, head string) *gitshipiov1alpha1.GitshipApp {
+	spec := parent.Spec.DeepCopy()
+	if !spec.Previews.IncludeSecrets {
+		spec.SecretRefs = nil
+		spec.SecretMounts = nil
+		spec.Build.Args = withoutSecretArgs(spec.Build.Args)
+	}
+	spec.Source = gitshipiov1alpha1.SourceConfig{Type: sourcePullRequest, Value: strconv.Itoa(int(number))}
+	spec.Paths = gitshipiov1alpha1.PathFilter{}
+	spec.Replicas = 1
+	spec.Rollout.Strategy = ""
+	spec.RebuildToken = ""
+	spec.CancelToken = ""
+	spec.PinnedCommit = ""
+	spec.Previews = gitshipiov1alpha1.PreviewConfig{}
+	for i := range spec.Ingresses {
+		spec.Ingresses[i].Host = previewHost(spec.Ingresses[i].Host, number)
+	}
+	for i := range spec.Volumes {
+		// Preview data isn't worth keeping
+		spec.Volumes[i].DeletionPolicy = volumeDelete
+	}
+
+	return &gitshipiov1alpha1.GitshipApp{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-pr-%d", parent.Name, number),
+			Namespace: parent.Namespace,
+			Labels: map[string]string{
+				labelPreviewOf:   parent.Name,
+				labelPullRequest: strconv.Itoa(int(number)),
+			},
+			Annotations: map[string]string{
+				annotationPreviewHead:       head,
+				annotationPreviewGeneration: strconv.FormatInt(parent.Generation, 10),
+			},
+		},
+		Spec: *spec,
+	}
+}
+
+// withoutSecretArgs returns the build arguments that don't read a Secret.
+func withoutSecretArgs(args []gitshipiov1alpha1.BuildArg) []gitshipiov1alpha1.BuildArg {
+	var kept []gitshipiov1alpha1.BuildArg
+	for _, arg := range args {
+		if arg.SecretRef == nil {
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
+
+// previewHost derives the hostname of a preview from the parent's, e.g.
+// app.example.com becomes app-pr-42.example.com.
+func previewHost(host string, number int32) string {
+	label, domain, found := strings.Cut(host, ".")
+	label = fmt.Sprintf("%s-pr-%d", label, number)
+	if !found {
+		return label
+	}
+	return label + "." + domain
+}
+
+func previewURL(app *gitshipiov1alpha1.GitshipApp) string {
+	if len(app.Spec.Ingresses) == 0 {
+		return ""
+	}
+	if app.Spec.Ingresses[0].TLS {
+		return "https://" + app.Spec.Ingresses[0].Host
+	}
+	return "http://" + app.Spec.Ingresses[0].Host
+}
+
+// previewQuotaExceeded checks whether the preview fits into what is left of
+// the owner's quota in the namespace, and describes the exhausted resource if
+// it doesn't.
+func (r *GitshipAppReconciler) previewQuotaExceeded(ctx context.Context, preview *gitshipiov1alpha1.GitshipApp) (string, error) {
+	quota := &corev1.ResourceQuota{}
+	if err := r.Get(ctx, types.NamespacedName{Name: userQuotaName, Namespace: preview.Namespace}, quota); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+
+	resources := resolveResources(preview.Spec.Resources)
+	required := []struct {
+		name     corev1.ResourceName
+		quantity resource.Quantity
+	}{
+		{corev1.ResourcePods, resource.MustParse("1")},
+		{corev1.ResourceRequestsCPU, resources.Requests[corev1.ResourceCPU]},
+		{corev1.ResourceRequestsMemory, resources.Requests[corev1.ResourceMemory]},
+		{corev1.ResourceLimitsCPU, resources.Limits[corev1.ResourceCPU]},
+		{corev1.ResourceLimitsMemory, resources.Limits[corev1.ResourceMemory]},
+	}
+	for _, req := range required {
+		hard, ok := quota.Status.Hard[req.name]
+		if !ok {
+			continue
+		}
+		used := quota.Status.Used[req.name].DeepCopy()
+		used.Add(req.quantity)
+		if used.Cmp(hard) > 0 {
+			return fmt.Sprintf("Not enough quota for a preview: %s would exceed %s", req.name, hard.String()), nil
+		}
+	}
+	return "", nil
+}
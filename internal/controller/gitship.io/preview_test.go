@@ -0,0 +1,128 @@
+package gitshipio
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+func previewParent() *gitshipiov1alpha1.GitshipApp {
+	app := &gitshipiov1alpha1.GitshipApp{}
+	app.Name = "web"
+	app.Namespace = "u-1"
+	app.Spec.Source = gitshipiov1alpha1.SourceConfig{Type: "branch", Value: "main"}
+	app.Spec.Replicas = 3
+	app.Spec.Previews.Enabled = true
+	app.Spec.Ingresses = []gitshipiov1alpha1.IngressRuleConfig{{Host: "web.example.com", ServicePort: 80, TLS: true}}
+	return app
+}
+
+func TestOpenPullRequests(t *testing.T) {
+	g := NewWithT(t)
+	app := previewParent()
+	app.Annotations = map[string]string{gitshipiov1alpha1.PullRequestsAnnotation: `{"42":"abc123","x":"def"}`}
+	g.Expect(openPullRequests(app)).To(Equal(map[int32]string{42: "abc123"}))
+
+	app.Spec.Previews.Enabled = false
+	g.Expect(openPullRequests(app)).To(BeNil())
+}
+
+func TestPreviewApp(t *testing.T) {
+	g := NewWithT(t)
+	preview := previewApp(previewParent(), 42, "abc123")
+
+	g.Expect(preview.Name).To(Equal("web-pr-42"))
+	g.Expect(preview.Labels).To(HaveKeyWithValue(labelPreviewOf, "web"))
+	g.Expect(preview.Annotations).To(HaveKeyWithValue(annotationPreviewHead, "abc123"))
+	g.Expect(preview.Spec.Source).To(Equal(gitshipiov1alpha1.SourceConfig{Type: sourcePullRequest, Value: "42"}))
+	g.Expect(preview.Spec.Replicas).To(Equal(int32(1)))
+	g.Expect(preview.Spec.Previews.Enabled).To(BeFalse())
+	g.Expect(preview.Spec.Ingresses[0].Host).To(Equal("web-pr-42.example.com"))
+	g.Expect(previewURL(preview)).To(Equal("https://web-pr-42.example.com"))
+}
+
+func TestPreviewAppDropsSecrets(t *testing.T) {
+	g := NewWithT(t)
+	parent := previewParent()
+	parent.Spec.SecretRefs = []string{"web-env"}
+	parent.Spec.SecretMounts = []gitshipiov1alpha1.SecretMountConfig{{SecretName: "web-config", MountPath: "/config"}}
+	parent.Spec.Build.Args = []gitshipiov1alpha1.BuildArg{
+		{Name: "VERSION", Value: "1"},
+		{Name: "NPM_TOKEN", SecretRef: &gitshipiov1alpha1.SecretKeyRef{Name: "npm", Key: "token"}},
+	}
+	parent.Spec.Env = map[string]string{"MODE": "preview"}
+
+	preview := previewApp(parent, 42, "abc123")
+	g.Expect(preview.Spec.SecretRefs).To(BeEmpty())
+	g.Expect(preview.Spec.SecretMounts).To(BeEmpty())
+	g.Expect(preview.Spec.Build.Args).To(Equal([]gitshipiov1alpha1.BuildArg{{Name: "VERSION", Value: "1"}}))
+	g.Expect(preview.Spec.Env).To(HaveKeyWithValue("MODE", "preview"))
+	g.Expect(parent.Spec.Build.Args).To(HaveLen(2))
+
+	parent.Spec.Previews.IncludeSecrets = true
+	preview = previewApp(parent, 42, "abc123")
+	g.Expect(preview.Spec.SecretRefs).To(Equal([]string{"web-env"}))
+	g.Expect(preview.Spec.SecretMounts).To(HaveLen(1))
+	g.Expect(preview.Spec.Build.Args).To(HaveLen(2))
+}
+
+func TestReconcilePreviewsLeavesOtherApps(t *testing.T) {
+	g := NewWithT(t)
+	parent := previewParent()
+	parent.UID = "web-uid"
+	parent.Annotations = map[string]string{gitshipiov1alpha1.PullRequestsAnnotation: `{"1":"abc123"}`}
+	// An app that happens to be named like the preview, and one labeled as a
+	// preview of a pull request that was closed
+	named := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{Name: "web-pr-1", Namespace: "u-1"}}
+	named.Spec.Replicas = 2
+	labeled := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{
+		Name:      "api",
+		Namespace: "u-1",
+		Labels:    map[string]string{labelPreviewOf: "web", labelPullRequest: "7"},
+	}}
+	r := buildReconciler(t, parent, named, labeled)
+	ctx := context.Background()
+
+	g.Expect(r.reconcilePreviews(ctx, parent)).To(Succeed())
+	g.Expect(parent.Status.Previews).To(Equal([]gitshipiov1alpha1.PreviewStatus{
+		{PullRequest: 1, HeadCommit: "abc123", Message: "App web-pr-1 already exists"},
+	}))
+	g.Expect(r.Get(ctx, types.NamespacedName{Name: "web-pr-1", Namespace: "u-1"}, named)).To(Succeed())
+	g.Expect(named.Spec.Replicas).To(Equal(int32(2)))
+	g.Expect(named.OwnerReferences).To(BeEmpty())
+	g.Expect(r.Get(ctx, types.NamespacedName{Name: "api", Namespace: "u-1"}, labeled)).To(Succeed())
+}
+
+func TestReconcilePreviewsFollowsAppChanges(t *testing.T) {
+	g := NewWithT(t)
+	parent := previewParent()
+	parent.UID = "web-uid"
+	parent.Generation = 1
+	parent.Annotations = map[string]string{gitshipiov1alpha1.PullRequestsAnnotation: `{"2":"abc123"}`}
+	r := buildReconciler(t, parent)
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "web-pr-2", Namespace: "u-1"}
+
+	g.Expect(r.reconcilePreviews(ctx, parent)).To(Succeed())
+	preview := &gitshipiov1alpha1.GitshipApp{}
+	g.Expect(r.Get(ctx, key, preview)).To(Succeed())
+	g.Expect(preview.Spec.Env).To(BeEmpty())
+
+	parent.Generation = 2
+	parent.Spec.Env = map[string]string{"LOG_LEVEL": "debug"}
+	g.Expect(r.reconcilePreviews(ctx, parent)).To(Succeed())
+	g.Expect(r.Get(ctx, key, preview)).To(Succeed())
+	g.Expect(preview.Spec.Env).To(HaveKeyWithValue("LOG_LEVEL", "debug"))
+	g.Expect(preview.Spec.Replicas).To(Equal(int32(1)))
+	g.Expect(preview.Annotations).To(HaveKeyWithValue(annotationPreviewGeneration, "2"))
+
+	parent.Annotations[gitshipiov1alpha1.PullRequestsAnnotation] = `{"2":"def456"}`
+	g.Expect(r.reconcilePreviews(ctx, parent)).To(Succeed())
+	g.Expect(r.Get(ctx, key, preview)).To(Succeed())
+	g.Expect(preview.Annotations).To(HaveKeyWithValue(annotationPreviewHead, "def456"))
+}
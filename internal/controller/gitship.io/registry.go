@@ -0,0 +1,44 @@
+package gitshipio
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/registry"
+)
+
+// registryClient returns a client for the app's registry, authenticated with
+// the docker config of spec.registrySecretRef when set.
+func (r *GitshipAppReconciler) registryClient(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) (*registry.Client, error) {
+	auths := map[string]registry.Credentials{}
+	if app.Spec.RegistrySecretRef != "" {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: app.Spec.RegistrySecretRef, Namespace: app.Namespace}, secret); err != nil {
+			return nil, err
+		}
+		if data, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
+			parsed, err := registry.ParseDockerConfig(data)
+			if err != nil {
+				return nil, err
+			}
+			auths = parsed
+		}
+	}
+	return registry.NewClient(auths), nil
+}
+
+// imageDigest returns the digest of image, or registry.ErrNotFound.
+func (r *GitshipAppReconciler) imageDigest(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, image string) (string, error) {
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	client, err := r.registryClient(ctx, app)
+	if err != nil {
+		return "", err
+	}
+	return client.ManifestDigest(ctx, ref)
+}
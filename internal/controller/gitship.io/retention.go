@@ -0,0 +1,249 @@
+package gitshipio
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/registry"
+)
+
+const (
+	// kanikoCacheTTL matches Kaniko's default --cache-ttl. Older cache
+	// entries are never used again.
+	kanikoCacheTTL = 14 * 24 * time.Hour
+	// cacheRepositorySuffix names the repository Kaniko caches layers in
+	cacheRepositorySuffix = "-cache"
+)
+
+// usesBuiltInRegistry reports whether the app's images are pushed to the
+// built-in registry, whose contents gitship manages.
+func usesBuiltInRegistry(app *gitshipiov1alpha1.GitshipApp) bool {
+	return app.Spec.Image == nil && app.Spec.RegistrySecretRef == ""
+}
+
+// imageRepositoryRef returns the repository the app's images are pushed to.
+func (r *GitshipAppReconciler) imageRepositoryRef(app *gitshipiov1alpha1.GitshipApp) (registry.Reference, error) {
+	pushImage, _ := r.imageNames(app, "latest")
+	ref, err := registry.ParseReference(pushImage)
+	ref.Tag = ""
+	return ref, err
+}
+
+// retainedImages returns the tags and digests of the app's images that must
+// survive pruning: those of its build history and those it runs or may roll
+// back to.
+func retainedImages(app *gitshipiov1alpha1.GitshipApp, tags, digests map[string]bool) {
+	for _, record := range app.Status.BuildHistory {
+		tags[record.CommitID] = true
+		if record.Tag != "" {
+			tags[imageTag(record.Tag)] = true
+		}
+		if record.Digest != "" {
+			digests[record.Digest] = true
+		}
+	}
+	commits := []string{
+		deployCommit(app),
+		app.Status.LatestBuildID,
+		app.Status.LastHealthyCommit,
+		app.Status.CandidateCommit,
+		app.Status.PinnedCommit,
+	}
+	for _, commit := range commits {
+		if commit == "" {
+			continue
+		}
+		tags[commit] = true
+		if gitTag := buildTag(app, commit); gitTag != "" {
+			tags[imageTag(gitTag)] = true
+		}
+		if digest := buildDigest(app, commit); digest != "" {
+			digests[digest] = true
+		}
+	}
+}
+
+// pruneImages deletes the images of ref's repository that none of apps
+// retains, keeping the keep most recently built ones.
+func pruneImages(ctx context.Context, client *registry.Client, ref registry.Reference, apps []*gitshipiov1alpha1.GitshipApp, keep int) error {
+	tags, err := client.Tags(ctx, ref)
+	if errors.Is(err, registry.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	retainedTags, retained := map[string]bool{}, map[string]bool{}
+	for _, app := range apps {
+		retainedImages(app, retainedTags, retained)
+	}
+	images := map[string]registry.Image{}
+	for _, tag := range tags {
+		tagRef := ref
+		tagRef.Tag = tag
+		image, err := client.Image(ctx, tagRef)
+		if errors.Is(err, registry.ErrNotFound) {
+			// Deleted since the tags were listed
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		images[image.Digest] = image
+		if retainedTags[tag] {
+			retained[image.Digest] = true
+		}
+	}
+
+	newest := make([]registry.Image, 0, len(images))
+	for _, image := range images {
+		newest = append(newest, image)
+	}
+	sort.Slice(newest, func(i, j int) bool {
+		return newest[i].Created.After(newest[j].Created)
+	})
+	for i, image := range newest {
+		if i < keep || retained[image.Digest] {
+			continue
+		}
+		log.Info("Pruning image", "repository", ref.Repository, "digest", image.Digest, "created", image.Created)
+		if err := client.DeleteManifest(ctx, ref, image.Digest); err != nil && !errors.Is(err, registry.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneCache deletes the entries of ref's Kaniko cache repository that have
+// expired.
+func pruneCache(ctx context.Context, client *registry.Client, ref registry.Reference, now time.Time) error {
+	ref.Repository += cacheRepositorySuffix
+	return deleteImages(ctx, client, ref, func(image registry.Image) bool {
+		return !image.Created.IsZero() && image.Created.Add(kanikoCacheTTL).Before(now)
+	})
+}
+
+// deleteImages deletes the images of ref's repository that match.
+func deleteImages(ctx context.Context, client *registry.Client, ref registry.Reference, match func(registry.Image) bool) error {
+	tags, err := client.Tags(ctx, ref)
+	if errors.Is(err, registry.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	deleted := map[string]bool{}
+	for _, tag := range tags {
+		tagRef := ref
+		tagRef.Tag = tag
+		image, err := client.Image(ctx, tagRef)
+		if errors.Is(err, registry.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if deleted[image.Digest] || !match(image) {
+			continue
+		}
+		if err := client.DeleteManifest(ctx, ref, image.Digest); err != nil && !errors.Is(err, registry.ErrNotFound) {
+			return err
+		}
+		deleted[image.Digest] = true
+	}
+	return nil
+}
+
+// pruneRegistry applies the retention policy to the repositories of all apps
+// using the built-in registry. Apps sharing a repository, such as previews
+// and their parent, retain their images together.
+func (r *GitshipAppReconciler) pruneRegistry(ctx context.Context) error {
+	apps := &gitshipiov1alpha1.GitshipAppList{}
+	if err := r.List(ctx, apps); err != nil {
+		return err
+	}
+	repositories := map[string][]*gitshipiov1alpha1.GitshipApp{}
+	refs := map[string]registry.Reference{}
+	for i := range apps.Items {
+		app := &apps.Items[i]
+		if !usesBuiltInRegistry(app) {
+			continue
+		}
+		ref, err := r.imageRepositoryRef(app)
+		if err != nil {
+			log.Error(err, "Skipping app in registry pruning", "app", app.Name, "namespace", app.Namespace)
+			continue
+		}
+		key := ref.Registry + "/" + ref.Repository
+		repositories[key] = append(repositories[key], app)
+		refs[key] = ref
+	}
+
+	client := registry.NewClient(nil)
+	var errs []error
+	for key, repoApps := range repositories {
+		if err := pruneImages(ctx, client, refs[key], repoApps, r.Config.RegistryRetainBuilds); err != nil {
+			errs = append(errs, err)
+		}
+		if err := pruneCache(ctx, client, refs[key], time.Now()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runRegistryRetention prunes the built-in registry every
+// RegistryGCInterval until ctx is done.
+func (r *GitshipAppReconciler) runRegistryRetention(ctx context.Context) error {
+	ticker := time.NewTicker(r.Config.RegistryGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := r.pruneRegistry(ctx); err != nil {
+				log.Error(err, "Failed to prune registry")
+			}
+		}
+	}
+}
+
+// deleteAppImages deletes the image and cache repositories of an app using
+// the built-in registry, unless another app pushes to the same repository.
+func (r *GitshipAppReconciler) deleteAppImages(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) error {
+	if !usesBuiltInRegistry(app) {
+		return nil
+	}
+	ref, err := r.imageRepositoryRef(app)
+	if err != nil {
+		return err
+	}
+	apps := &gitshipiov1alpha1.GitshipAppList{}
+	if err := r.List(ctx, apps); err != nil {
+		return err
+	}
+	for i := range apps.Items {
+		other := &apps.Items[i]
+		if other.UID == app.UID || other.DeletionTimestamp != nil || !usesBuiltInRegistry(other) {
+			continue
+		}
+		if otherRef, err := r.imageRepositoryRef(other); err == nil && otherRef == ref {
+			// Still in use, e.g. by the parent of a preview
+			return nil
+		}
+	}
+
+	log.Info("Deleting images", "app", app.Name, "repository", ref.Repository)
+	client := registry.NewClient(nil)
+	all := func(registry.Image) bool { return true }
+	if err := deleteImages(ctx, client, ref, all); err != nil {
+		return err
+	}
+	ref.Repository += cacheRepositorySuffix
+	return deleteImages(ctx, client, ref, all)
+}
@@ -0,0 +1,213 @@
+package gitshipio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/registry"
+)
+
+// testImage is an image of a testRegistry repository.
+type testImage struct {
+	digest  string
+	created time.Time
+}
+
+// testRegistry serves repositories of tagged images and accepts deletes.
+type testRegistry struct {
+	repositories map[string]map[string]testImage
+}
+
+func (t *testRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	for name, tags := range t.repositories {
+		rest, ok := strings.CutPrefix(path, name+"/")
+		if !ok {
+			continue
+		}
+		kind, ident, _ := strings.Cut(rest, "/")
+		switch {
+		case rest == "tags/list":
+			list := []string{}
+			for tag := range tags {
+				list = append(list, tag)
+			}
+			sort.Strings(list)
+			_ = json.NewEncoder(w).Encode(map[string]any{"name": name, "tags": list})
+			return
+		case kind == "manifests" && r.Method == http.MethodDelete:
+			found := false
+			for tag, image := range tags {
+				if image.digest == ident {
+					delete(tags, tag)
+					found = true
+				}
+			}
+			if found {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+		case kind == "manifests":
+			if image, ok := tags[ident]; ok {
+				w.Header().Set("Docker-Content-Digest", image.digest)
+				_, _ = fmt.Fprintf(w, `{"config":{"digest":"config-%s"}}`, image.digest)
+				return
+			}
+		case kind == "blobs":
+			for _, image := range tags {
+				if ident == "config-"+image.digest {
+					_, _ = fmt.Fprintf(w, `{"created":%q}`, image.created.Format(time.RFC3339))
+					return
+				}
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+// tags returns the remaining tags of a repository.
+func (t *testRegistry) tags(repository string) []string {
+	var list []string
+	for tag := range t.repositories[repository] {
+		list = append(list, tag)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func retentionReconciler(t *testing.T, host string, objs ...client.Object) *GitshipAppReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := gitshipiov1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &GitshipAppReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		Scheme: scheme,
+		Config: ControllerConfig{SystemNamespace: "gitship-system", RegistryPushURL: host, RegistryRetainBuilds: 1},
+	}
+}
+
+func retentionDay(n int) time.Time {
+	return time.Date(2026, 10, n, 0, 0, 0, 0, time.UTC)
+}
+
+// retentionRegistry serves the images of team/web and its cache, and returns
+// the app pushing them with the registry's host.
+func retentionRegistry(t *testing.T) (*testRegistry, string, *gitshipiov1alpha1.GitshipApp) {
+	t.Helper()
+	reg := &testRegistry{repositories: map[string]map[string]testImage{
+		"team/web": {
+			"c1":     {digest: "sha256:1", created: retentionDay(1)},
+			"c2":     {digest: "sha256:2", created: retentionDay(2)},
+			"v1.0.0": {digest: "sha256:2", created: retentionDay(2)},
+			"c3":     {digest: "sha256:3", created: retentionDay(3)},
+			"c4":     {digest: "sha256:4", created: retentionDay(4)},
+			"c5":     {digest: "sha256:5", created: retentionDay(5)},
+		},
+		"team/web-cache": {
+			"layer-old": {digest: "sha256:old", created: retentionDay(1)},
+			"layer-new": {digest: "sha256:new", created: retentionDay(16)},
+		},
+	}}
+	server := httptest.NewServer(reg)
+	t.Cleanup(server.Close)
+
+	app := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team", UID: "web-uid"}}
+	app.Spec.RepoURL = "https://github.com/team/web"
+	app.Spec.ImageName = "team/web"
+	return reg, strings.TrimPrefix(server.URL, "http://"), app
+}
+
+func TestPruneImagesKeepsNewestHistoryAndDeployed(t *testing.T) {
+	g := NewWithT(t)
+	reg, host, app := retentionRegistry(t)
+	app.Status.LatestBuildID = "c3"
+	app.Status.BuildHistory = []gitshipiov1alpha1.BuildRecord{
+		{CommitID: "c3", Status: buildPhaseSucceeded},
+		{CommitID: "c9", Status: buildPhaseSucceeded, Tag: "v1.0.0"},
+	}
+	r := retentionReconciler(t, host, app)
+	ref, err := r.imageRepositoryRef(app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ref.Repository).To(Equal("team/web"))
+
+	client := registry.NewClient(nil)
+	g.Expect(pruneImages(context.Background(), client, ref, []*gitshipiov1alpha1.GitshipApp{app}, 1)).To(Succeed())
+	// c5 is the newest, c3 is deployed and v1.0.0 is in the history
+	g.Expect(reg.tags("team/web")).To(Equal([]string{"c2", "c3", "c5", "v1.0.0"}))
+}
+
+func TestPruneCache(t *testing.T) {
+	g := NewWithT(t)
+	reg, host, _ := retentionRegistry(t)
+	ref := registry.Reference{Registry: host, Repository: "team/web"}
+	g.Expect(pruneCache(context.Background(), registry.NewClient(nil), ref, retentionDay(20))).To(Succeed())
+	g.Expect(reg.tags("team/web-cache")).To(Equal([]string{"layer-new"}))
+}
+
+func TestPruneRegistry(t *testing.T) {
+	g := NewWithT(t)
+	reg, host, app := retentionRegistry(t)
+	app.Status.LatestBuildID = "c1"
+	external := app.DeepCopy()
+	external.Name, external.UID = "ext", "ext-uid"
+	external.Spec.ImageName = "team/ext"
+	external.Spec.RegistrySecretRef = "ghcr"
+	r := retentionReconciler(t, host, app, external)
+
+	g.Expect(r.pruneRegistry(context.Background())).To(Succeed())
+	g.Expect(reg.tags("team/web")).To(Equal([]string{"c1", "c5"}))
+	g.Expect(reg.tags("team/web-cache")).To(Equal([]string{"layer-new"}))
+}
+
+func TestFinalizeAppDeletesImages(t *testing.T) {
+	g := NewWithT(t)
+	reg, host, app := retentionRegistry(t)
+	now := metav1.Now()
+	app.Finalizers = []string{appFinalizer}
+	app.DeletionTimestamp = &now
+	r := retentionReconciler(t, host, app)
+
+	_, err := r.finalizeApp(context.Background(), app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reg.tags("team/web")).To(BeEmpty())
+	g.Expect(reg.tags("team/web-cache")).To(BeEmpty())
+	err = r.Get(context.Background(), types.NamespacedName{Name: "web", Namespace: "team"}, &gitshipiov1alpha1.GitshipApp{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestFinalizeAppKeepsSharedImages(t *testing.T) {
+	g := NewWithT(t)
+	reg, host, app := retentionRegistry(t)
+	now := metav1.Now()
+	preview := app.DeepCopy()
+	preview.Name, preview.UID = "web-pr-1", "preview-uid"
+	preview.Finalizers = []string{appFinalizer}
+	preview.DeletionTimestamp = &now
+	r := retentionReconciler(t, host, app, preview)
+
+	_, err := r.finalizeApp(context.Background(), preview)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reg.tags("team/web")).To(HaveLen(6))
+}
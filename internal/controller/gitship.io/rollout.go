@@ -0,0 +1,178 @@
+package gitshipio
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+const phaseRolledBack = "RolledBack"
+
+const defaultProgressDeadlineSeconds = 300
+
+type rolloutState int
+
+const (
+	rolloutProgressing rolloutState = iota
+	rolloutHealthy
+	rolloutFailed
+	rolloutRolledBack
+)
+
+// Container waiting reasons that won't resolve without a new image or config.
+// ErrImagePull isn't one: a pull often fails while the registry catches up, and
+// one that keeps failing backs off or runs into the progress deadline.
+var failedWaitingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+}
+
+// deployCommit returns the commit whose image should run: the latest build,
+// or the rollback target while the latest build's rollout is reverted.
+func deployCommit(app *gitshipiov1alpha1.GitshipApp) string {
+	if app.Status.RolledBackCommit != "" && app.Status.RolledBackCommit == app.Status.LatestBuildID {
+		if target := rollbackTarget(app, app.Status.RolledBackCommit); target != "" {
+			return target
+		}
+	}
+	return app.Status.LatestBuildID
+}
+
+// rollbackTarget picks the commit to revert to: the last commit seen healthy,
+// otherwise the newest other successful build in the history.
+func rollbackTarget(app *gitshipiov1alpha1.GitshipApp, failedCommit string) string {
+	if app.Status.LastHealthyCommit != "" && app.Status.LastHealthyCommit != failedCommit {
+		return app.Status.LastHealthyCommit
+	}
+	for _, record := range app.Status.BuildHistory {
+		if record.Status == buildPhaseSucceeded && record.CommitID != failedCommit {
+			return record.CommitID
+		}
+	}
+	return ""
+}
+
+func progressDeadlineSeconds(rollout gitshipiov1alpha1.RolloutConfig) int32 {
+	if rollout.ProgressDeadlineSeconds > 0 {
+		return rollout.ProgressDeadlineSeconds
+	}
+	return defaultProgressDeadlineSeconds
+}
+
+// clearStaleRollback forgets a reverted rollout once a newer build replaced it.
+func clearStaleRollback(app *gitshipiov1alpha1.GitshipApp) bool {
+	if app.Status.RolledBackCommit == "" || app.Status.RolledBackCommit == app.Status.LatestBuildID {
+		return false
+	}
+	app.Status.RolledBackCommit = ""
+	app.Status.RollbackReason = ""
+	if app.Status.Phase == phaseRolledBack {
+		app.Status.Phase = "Deploying"
+	}
+	return true
+}
+
+// checkRollout watches the rolling update of the Deployment depName to commit
+// and reverts it when it fails. The app status is updated when the outcome
+// changes.
+func (r *GitshipAppReconciler) checkRollout(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, depName, commit, image string, replicas int32) (rolloutState, error) {
+	dep, pods, err := r.deploymentAndPods(ctx, app.Namespace, depName)
+	if err != nil || dep == nil {
+		return rolloutProgressing, err
+	}
+
+	changed := false
+	state, reason := rolloutHealth(dep, pods, image, replicas)
+	switch state {
+	case rolloutHealthy:
+		if app.Status.LastHealthyCommit != commit {
+			app.Status.LastHealthyCommit = commit
+			changed = true
+		}
+	case rolloutFailed:
+		autoRollback := app.Spec.Rollout.AutoRollback == nil || *app.Spec.Rollout.AutoRollback
+		target := rollbackTarget(app, commit)
+		if commit != app.Status.LatestBuildID || !autoRollback || target == "" {
+			// Already running the rollback target, or nothing to revert to
+			log.Info("Rollout failed", "app", app.Name, "commit", commit, "reason", reason)
+			break
+		}
+		log.Info("Rollout failed, rolling back", "app", app.Name, "commit", commit, "target", target, "reason", reason)
+		app.Status.RolledBackCommit = commit
+		app.Status.RollbackReason = reason
+		app.Status.Phase = phaseRolledBack
+		changed = true
+		state = rolloutRolledBack
+	}
+
+	if changed {
+		if err := r.Status().Update(ctx, app); err != nil {
+			return state, err
+		}
+	}
+	return state, nil
+}
+
+// deploymentAndPods returns the Deployment depName, nil if it doesn't exist,
+// and the pods labeled app=depName.
+func (r *GitshipAppReconciler) deploymentAndPods(ctx context.Context, namespace, depName string) (*appsv1.Deployment, []corev1.Pod, error) {
+	dep := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Name: depName, Namespace: namespace}, dep); err != nil {
+		return nil, nil, client.IgnoreNotFound(err)
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(namespace), client.MatchingLabels{"app": depName}); err != nil {
+		return nil, nil, err
+	}
+	return dep, pods.Items, nil
+}
+
+// rolloutHealth evaluates the rollout of image from the Deployment status and
+// the state of the pods running that image.
+func rolloutHealth(dep *appsv1.Deployment, pods []corev1.Pod, image string, replicas int32) (rolloutState, string) {
+	if len(dep.Spec.Template.Spec.Containers) == 0 || dep.Spec.Template.Spec.Containers[0].Image != image ||
+		dep.Status.ObservedGeneration < dep.Generation {
+		// The Deployment status doesn't reflect the image update yet
+		return rolloutProgressing, ""
+	}
+	for _, cond := range dep.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			return rolloutFailed, "Rollout exceeded its progress deadline: " + cond.Message
+		}
+	}
+
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || !podRunsImage(&pod, image) {
+			continue
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Waiting != nil && failedWaitingReasons[cs.State.Waiting.Reason] {
+				return rolloutFailed, fmt.Sprintf("Pod %s: %s: %s", pod.Name, cs.State.Waiting.Reason, cs.State.Waiting.Message)
+			}
+		}
+	}
+
+	if dep.Status.UpdatedReplicas >= replicas &&
+		dep.Status.AvailableReplicas >= replicas &&
+		dep.Status.Replicas == dep.Status.UpdatedReplicas {
+		return rolloutHealthy, ""
+	}
+	return rolloutProgressing, ""
+}
+
+func podRunsImage(pod *corev1.Pod, image string) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Image == image {
+			return true
+		}
+	}
+	return false
+}
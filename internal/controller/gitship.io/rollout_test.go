@@ -0,0 +1,356 @@
+package gitshipio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+const rolloutImage = "registry/app:abc1234"
+
+func rolloutDeployment() *appsv1.Deployment {
+	dep := &appsv1.Deployment{}
+	dep.Generation = 2
+	dep.Spec.Template.Spec.Containers = []corev1.Container{{Name: "app", Image: rolloutImage}}
+	dep.Status.ObservedGeneration = 2
+	return dep
+}
+
+func TestRolloutHealthy(t *testing.T) {
+	g := NewWithT(t)
+	dep := rolloutDeployment()
+	dep.Status.Replicas, dep.Status.UpdatedReplicas, dep.Status.AvailableReplicas = 2, 2, 2
+	state, _ := rolloutHealth(dep, nil, rolloutImage, 2)
+	g.Expect(state).To(Equal(rolloutHealthy))
+}
+
+func TestRolloutProgressingUntilObserved(t *testing.T) {
+	g := NewWithT(t)
+	dep := rolloutDeployment()
+	dep.Status.ObservedGeneration = 1
+	dep.Status.Replicas, dep.Status.UpdatedReplicas, dep.Status.AvailableReplicas = 1, 1, 1
+	state, _ := rolloutHealth(dep, nil, rolloutImage, 1)
+	g.Expect(state).To(Equal(rolloutProgressing))
+}
+
+func TestRolloutFailsPastProgressDeadline(t *testing.T) {
+	g := NewWithT(t)
+	dep := rolloutDeployment()
+	dep.Status.Conditions = []appsv1.DeploymentCondition{{
+		Type:    appsv1.DeploymentProgressing,
+		Status:  corev1.ConditionFalse,
+		Reason:  "ProgressDeadlineExceeded",
+		Message: "ReplicaSet has timed out progressing.",
+	}}
+	state, reason := rolloutHealth(dep, nil, rolloutImage, 1)
+	g.Expect(state).To(Equal(rolloutFailed))
+	g.Expect(reason).To(ContainSubstring("timed out"))
+}
+
+// waitingPod returns a pod running rolloutImage whose container waits for reason.
+func waitingPod(reason string) corev1.Pod {
+	pod := corev1.Pod{}
+	pod.Name = "app-1"
+	pod.Spec.Containers = []corev1.Container{{Name: "app", Image: rolloutImage}}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  "app",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}},
+	}}
+	return pod
+}
+
+func TestRolloutFailsOnCrashLoop(t *testing.T) {
+	g := NewWithT(t)
+	state, reason := rolloutHealth(rolloutDeployment(), []corev1.Pod{waitingPod("CrashLoopBackOff")}, rolloutImage, 1)
+	g.Expect(state).To(Equal(rolloutFailed))
+	g.Expect(reason).To(HavePrefix("Pod app-1: CrashLoopBackOff"))
+}
+
+func TestRolloutRetriesImagePull(t *testing.T) {
+	g := NewWithT(t)
+	state, _ := rolloutHealth(rolloutDeployment(), []corev1.Pod{waitingPod("ErrImagePull")}, rolloutImage, 1)
+	g.Expect(state).To(Equal(rolloutProgressing))
+
+	state, reason := rolloutHealth(rolloutDeployment(), []corev1.Pod{waitingPod("ImagePullBackOff")}, rolloutImage, 1)
+	g.Expect(state).To(Equal(rolloutFailed))
+	g.Expect(reason).To(HavePrefix("Pod app-1: ImagePullBackOff"))
+}
+
+func TestRollbackTarget(t *testing.T) {
+	g := NewWithT(t)
+	app := &gitshipiov1alpha1.GitshipApp{}
+	app.Status.BuildHistory = []gitshipiov1alpha1.BuildRecord{
+		{CommitID: "ccc", Status: buildPhaseSucceeded},
+		{CommitID: "bbb", Status: buildPhaseFailed},
+		{CommitID: "aaa", Status: buildPhaseSucceeded},
+	}
+	g.Expect(rollbackTarget(app, "ccc")).To(Equal("aaa"))
+
+	app.Status.LastHealthyCommit = "zzz"
+	g.Expect(rollbackTarget(app, "ccc")).To(Equal("zzz"))
+
+	app.Status.LatestBuildID = "ccc"
+	app.Status.RolledBackCommit = "ccc"
+	g.Expect(deployCommit(app)).To(Equal("zzz"))
+}
+
+func TestRestartedPod(t *testing.T) {
+	g := NewWithT(t)
+	pod := corev1.Pod{}
+	pod.Name = "app-canary-1"
+	pod.Spec.Containers = []corev1.Container{{Name: "app", Image: rolloutImage}}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "app"}}
+	g.Expect(restartedPod([]corev1.Pod{pod}, rolloutImage)).To(BeEmpty())
+
+	pod.Status.ContainerStatuses[0].RestartCount = 1
+	g.Expect(restartedPod([]corev1.Pod{pod}, "registry/app:other")).To(BeEmpty())
+	g.Expect(restartedPod([]corev1.Pod{pod}, rolloutImage)).To(Equal("Pod app-canary-1: container app restarted 1 times"))
+}
+
+func TestHealthWindowElapsed(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	since := now.Add(-30 * time.Second).Format(time.RFC3339)
+	g.Expect(healthWindowElapsed(since, time.Minute, now)).To(BeFalse())
+	g.Expect(healthWindowElapsed(since, 30*time.Second, now)).To(BeTrue())
+	g.Expect(healthWindowElapsed("", time.Minute, now)).To(BeFalse())
+}
+
+func TestBlueGreenSlots(t *testing.T) {
+	g := NewWithT(t)
+	app := &gitshipiov1alpha1.GitshipApp{}
+	app.Name = "web"
+	g.Expect(activeDeployment(app)).To(Equal("web"))
+	g.Expect(slotDeployment(app, idleSlot(app))).To(Equal("web-green"))
+
+	app.Status.ActiveSlot = slotGreen
+	g.Expect(activeDeployment(app)).To(Equal("web-green"))
+	g.Expect(slotDeployment(app, idleSlot(app))).To(Equal("web"))
+}
+
+// rolloutApp returns the app team/web rolling out with strategy.
+func rolloutApp(strategy string) *gitshipiov1alpha1.GitshipApp {
+	app := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team", UID: "web-uid"}}
+	app.Spec.Rollout.Strategy = strategy
+	app.Spec.Ingresses = []gitshipiov1alpha1.IngressRuleConfig{{Host: "web.example.com", Path: "/", ServicePort: 80}}
+	return app
+}
+
+// controllerRef returns the owner references of an object controlled by app.
+func controllerRef(app *gitshipiov1alpha1.GitshipApp) []metav1.OwnerReference {
+	return []metav1.OwnerReference{*metav1.NewControllerRef(app, gitshipiov1alpha1.GroupVersion.WithKind("GitshipApp"))}
+}
+
+func TestRollOutLeavesSiblingAppObjects(t *testing.T) {
+	g := NewWithT(t)
+	app := rolloutApp("")
+	sibling := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{Name: "web-canary", Namespace: "team", UID: "sibling-uid"}}
+	meta := metav1.ObjectMeta{Name: "web-canary", Namespace: "team", OwnerReferences: controllerRef(sibling)}
+	siblingObjs := []client.Object{
+		&appsv1.Deployment{ObjectMeta: meta},
+		&corev1.Service{ObjectMeta: meta},
+		&networkingv1.Ingress{ObjectMeta: meta},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web-green", Namespace: "team"}},
+	}
+	r := buildReconciler(t, append([]client.Object{app, sibling}, siblingObjs...)...)
+	ctx := context.Background()
+
+	_, err := r.rollOut(ctx, app, "abc123", rolloutImage, 1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.ensureCanaryIngress(ctx, app)).To(Succeed())
+	for _, obj := range siblingObjs {
+		g.Expect(r.Get(ctx, client.ObjectKeyFromObject(obj), obj)).To(Succeed())
+	}
+
+	// A canary of web mustn't take over the sibling's Deployment either
+	app.Spec.Rollout.Strategy = rolloutStrategyCanary
+	_, err = r.rollOut(ctx, app, "def456", "registry/app:def4567", 1)
+	g.Expect(err).To(MatchError(ContainSubstring("isn't controlled by app web")))
+	dep := &appsv1.Deployment{}
+	g.Expect(r.Get(ctx, types.NamespacedName{Name: "web-canary", Namespace: "team"}, dep)).To(Succeed())
+	g.Expect(metav1.IsControlledBy(dep, sibling)).To(BeTrue())
+	g.Expect(dep.Spec.Template.Spec.Containers).To(BeEmpty())
+}
+
+// availableDeployment reports the Deployment name of r as fully rolled out.
+func availableDeployment(t *testing.T, r *GitshipAppReconciler, name string) {
+	t.Helper()
+	dep := &appsv1.Deployment{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "team"}, dep); err != nil {
+		t.Fatal(err)
+	}
+	dep.Status.ObservedGeneration = dep.Generation
+	dep.Status.Replicas = *dep.Spec.Replicas
+	dep.Status.UpdatedReplicas = *dep.Spec.Replicas
+	dep.Status.AvailableReplicas = *dep.Spec.Replicas
+	if err := r.Status().Update(context.Background(), dep); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stableRollout returns a reconciler with app running rolloutImage, and the
+// image of the commit being rolled out.
+func stableRollout(t *testing.T, app *gitshipiov1alpha1.GitshipApp) (*GitshipAppReconciler, string) {
+	t.Helper()
+	r := buildReconciler(t, app)
+	ctx := context.Background()
+	if err := r.ensureDeployment(ctx, app, app.Name, rolloutImage, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ensureService(ctx, app, app.Name, app.Name); err != nil {
+		t.Fatal(err)
+	}
+	availableDeployment(t, r, app.Name)
+	app.Status.LatestBuildID = "def456"
+	return r, "registry/app:def4567"
+}
+
+// elapseHealthWindow moves the start of the candidate's health window back
+// past its end.
+func elapseHealthWindow(app *gitshipiov1alpha1.GitshipApp) {
+	app.Status.CandidateHealthySince = time.Now().Add(-2 * healthWindow(app.Spec.Rollout)).Format(time.RFC3339)
+}
+
+func TestRollOutBlueGreenPromotesAfterHealthWindow(t *testing.T) {
+	g := NewWithT(t)
+	app := rolloutApp(rolloutStrategyBlueGreen)
+	r, image := stableRollout(t, app)
+	ctx := context.Background()
+	svcKey := types.NamespacedName{Name: "web", Namespace: "team"}
+
+	state, err := r.rollOut(ctx, app, "def456", image, 1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(state).To(Equal(rolloutProgressing))
+	g.Expect(r.deploymentImage(ctx, "team", "web-green")).To(Equal(image))
+	g.Expect(app.Status.CandidateCommit).To(Equal("def456"))
+
+	// Healthy, but not for the whole health window yet
+	availableDeployment(t, r, "web-green")
+	state, err = r.rollOut(ctx, app, "def456", image, 1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(state).To(Equal(rolloutProgressing))
+	g.Expect(app.Status.CandidateHealthySince).NotTo(BeEmpty())
+	svc := &corev1.Service{}
+	g.Expect(r.Get(ctx, svcKey, svc)).To(Succeed())
+	g.Expect(svc.Spec.Selector).To(HaveKeyWithValue("app", "web"))
+
+	elapseHealthWindow(app)
+	_, err = r.rollOut(ctx, app, "def456", image, 1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(app.Status.ActiveSlot).To(Equal(slotGreen))
+	g.Expect(app.Status.LastHealthyCommit).To(Equal("def456"))
+	g.Expect(app.Status.CandidateCommit).To(BeEmpty())
+	g.Expect(r.Get(ctx, svcKey, svc)).To(Succeed())
+	g.Expect(svc.Spec.Selector).To(HaveKeyWithValue("app", "web-green"))
+
+	// The previous slot is removed once traffic moved
+	state, err = r.rollOut(ctx, app, "def456", image, 1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(state).To(Equal(rolloutHealthy))
+	g.Expect(r.deploymentImage(ctx, "team", "web")).To(BeEmpty())
+}
+
+func TestRollOutCanaryPromotesAfterHealthWindow(t *testing.T) {
+	g := NewWithT(t)
+	app := rolloutApp(rolloutStrategyCanary)
+	app.Spec.Rollout.CanaryWeight = 25
+	r, image := stableRollout(t, app)
+	ctx := context.Background()
+	canaryKey := types.NamespacedName{Name: "web-canary", Namespace: "team"}
+
+	_, err := r.rollOut(ctx, app, "def456", image, 2)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.ensureCanaryIngress(ctx, app)).To(Succeed())
+	dep := &appsv1.Deployment{}
+	g.Expect(r.Get(ctx, canaryKey, dep)).To(Succeed())
+	g.Expect(*dep.Spec.Replicas).To(Equal(int32(1)))
+	g.Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal(image))
+	svc := &corev1.Service{}
+	g.Expect(r.Get(ctx, canaryKey, svc)).To(Succeed())
+	g.Expect(svc.Spec.Selector).To(HaveKeyWithValue("app", "web-canary"))
+	ing := &networkingv1.Ingress{}
+	g.Expect(r.Get(ctx, canaryKey, ing)).To(Succeed())
+	g.Expect(ing.Annotations).To(HaveKeyWithValue("nginx.ingress.kubernetes.io/canary", "true"))
+	g.Expect(ing.Annotations).To(HaveKeyWithValue("nginx.ingress.kubernetes.io/canary-weight", "25"))
+	g.Expect(ing.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name).To(Equal("web-canary"))
+	g.Expect(r.deploymentImage(ctx, "team", "web")).To(Equal(rolloutImage))
+
+	availableDeployment(t, r, "web-canary")
+	_, err = r.rollOut(ctx, app, "def456", image, 2)
+	g.Expect(err).NotTo(HaveOccurred())
+	elapseHealthWindow(app)
+	_, err = r.rollOut(ctx, app, "def456", image, 2)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.deploymentImage(ctx, "team", "web")).To(Equal(image))
+
+	// The canary is removed once the stable Deployment runs the image
+	_, err = r.rollOut(ctx, app, "def456", image, 2)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(app.Status.CandidateCommit).To(BeEmpty())
+	g.Expect(r.ensureCanaryIngress(ctx, app)).To(Succeed())
+	g.Expect(apierrors.IsNotFound(r.Get(ctx, canaryKey, &appsv1.Deployment{}))).To(BeTrue())
+	g.Expect(apierrors.IsNotFound(r.Get(ctx, canaryKey, &corev1.Service{}))).To(BeTrue())
+	g.Expect(apierrors.IsNotFound(r.Get(ctx, canaryKey, &networkingv1.Ingress{}))).To(BeTrue())
+}
+
+func TestRollOutCanaryAbortsOnRestart(t *testing.T) {
+	g := NewWithT(t)
+	app := rolloutApp(rolloutStrategyCanary)
+	r, image := stableRollout(t, app)
+	ctx := context.Background()
+	canaryKey := types.NamespacedName{Name: "web-canary", Namespace: "team"}
+
+	_, err := r.rollOut(ctx, app, "def456", image, 1)
+	g.Expect(err).NotTo(HaveOccurred())
+	availableDeployment(t, r, "web-canary")
+	pod := waitingPod("")
+	pod.Namespace = "team"
+	pod.Labels = map[string]string{"app": "web-canary"}
+	pod.Spec.Containers[0].Image = image
+	pod.Status.ContainerStatuses[0].RestartCount = 1
+	g.Expect(r.Create(ctx, &pod)).To(Succeed())
+
+	state, err := r.rollOut(ctx, app, "def456", image, 1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(state).To(Equal(rolloutRolledBack))
+	g.Expect(app.Status.Phase).To(Equal(phaseRolledBack))
+	g.Expect(app.Status.RolledBackCommit).To(Equal("def456"))
+	g.Expect(app.Status.RollbackReason).To(ContainSubstring("restarted 1 times"))
+	g.Expect(apierrors.IsNotFound(r.Get(ctx, canaryKey, &appsv1.Deployment{}))).To(BeTrue())
+	g.Expect(r.deploymentImage(ctx, "team", "web")).To(Equal(rolloutImage))
+}
+
+func TestCheckCandidateAbortsWhenReadinessIsLost(t *testing.T) {
+	g := NewWithT(t)
+	app := rolloutApp(rolloutStrategyBlueGreen)
+	r, image := stableRollout(t, app)
+	ctx := context.Background()
+	g.Expect(r.ensureDeployment(ctx, app, "web-green", image, 1)).To(Succeed())
+	availableDeployment(t, r, "web-green")
+
+	state, err := r.checkCandidate(ctx, app, "web-green", "def456", image, 1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(state).To(Equal(rolloutProgressing))
+	g.Expect(app.Status.CandidateHealthySince).NotTo(BeEmpty())
+
+	dep := &appsv1.Deployment{}
+	g.Expect(r.Get(ctx, types.NamespacedName{Name: "web-green", Namespace: "team"}, dep)).To(Succeed())
+	dep.Status.AvailableReplicas = 0
+	g.Expect(r.Status().Update(ctx, dep)).To(Succeed())
+	state, err = r.checkCandidate(ctx, app, "web-green", "def456", image, 1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(state).To(Equal(rolloutRolledBack))
+	g.Expect(app.Status.RollbackReason).To(Equal("Candidate lost readiness during the health window"))
+	g.Expect(app.Status.CandidateCommit).To(BeEmpty())
+}
@@ -0,0 +1,422 @@
+package gitshipio
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+const (
+	rolloutStrategyBlueGreen = "blueGreen"
+	rolloutStrategyCanary    = "canary"
+)
+
+const (
+	slotBlue  = "blue"
+	slotGreen = "green"
+)
+
+const (
+	defaultHealthWindowSeconds = 60
+	defaultCanaryWeight        = 10
+)
+
+// slotDeployment returns the Deployment of a blue/green slot: the app name for
+// blue, <app>-green for green.
+func slotDeployment(app *gitshipiov1alpha1.GitshipApp, slot string) string {
+	if slot == slotGreen {
+		return app.Name + "-green"
+	}
+	return app.Name
+}
+
+// activeDeployment returns the Deployment the app's Service points at.
+func activeDeployment(app *gitshipiov1alpha1.GitshipApp) string {
+	return slotDeployment(app, app.Status.ActiveSlot)
+}
+
+func idleSlot(app *gitshipiov1alpha1.GitshipApp) string {
+	if app.Status.ActiveSlot == slotGreen {
+		return slotBlue
+	}
+	return slotGreen
+}
+
+// canaryName names the canary Deployment, Service and Ingress.
+func canaryName(app *gitshipiov1alpha1.GitshipApp) string {
+	return app.Name + "-canary"
+}
+
+func healthWindow(rollout gitshipiov1alpha1.RolloutConfig) time.Duration {
+	if rollout.HealthWindowSeconds > 0 {
+		return time.Duration(rollout.HealthWindowSeconds) * time.Second
+	}
+	return defaultHealthWindowSeconds * time.Second
+}
+
+func canaryWeight(rollout gitshipiov1alpha1.RolloutConfig) int32 {
+	if rollout.CanaryWeight > 0 {
+		return rollout.CanaryWeight
+	}
+	return defaultCanaryWeight
+}
+
+// rollOut moves the app to the image of commit using the configured strategy.
+func (r *GitshipAppReconciler) rollOut(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, commit, image string, replicas int32) (rolloutState, error) {
+	if clearStaleRollback(app) {
+		if err := r.Status().Update(ctx, app); err != nil {
+			return rolloutProgressing, err
+		}
+	}
+
+	switch app.Spec.Rollout.Strategy {
+	case rolloutStrategyBlueGreen:
+		return r.rollOutBlueGreen(ctx, app, commit, image, replicas)
+	case rolloutStrategyCanary:
+		return r.rollOutCanary(ctx, app, commit, image, replicas)
+	default:
+		return r.rollOutRolling(ctx, app, commit, image, replicas)
+	}
+}
+
+// rollOutRolling updates the app's Deployment in place. Traffic moves back
+// from a green slot left by a blue/green rollout once the update is healthy.
+func (r *GitshipAppReconciler) rollOutRolling(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, commit, image string, replicas int32) (rolloutState, error) {
+	if err := r.ensureDeployment(ctx, app, app.Name, image, replicas); err != nil {
+		return rolloutProgressing, err
+	}
+	state, err := r.checkRollout(ctx, app, app.Name, commit, image, replicas)
+	if err != nil {
+		return state, err
+	}
+
+	if app.Status.ActiveSlot == slotGreen {
+		if state != rolloutHealthy {
+			return state, nil
+		}
+		log.Info("Moving traffic back to the blue slot", "app", app.Name)
+		if err := r.ensureService(ctx, app, app.Name, app.Name); err != nil {
+			return state, err
+		}
+		app.Status.ActiveSlot = ""
+		if err := r.Status().Update(ctx, app); err != nil {
+			return state, err
+		}
+	}
+	if err := r.deleteDeployment(ctx, app, slotDeployment(app, slotGreen)); err != nil {
+		return state, err
+	}
+	return state, r.cleanupCanary(ctx, app)
+}
+
+// rollOutBlueGreen starts the new image in the idle slot next to the active
+// one and switches the Service over once it stayed healthy for the health
+// window. The idle slot is removed once traffic moved.
+func (r *GitshipAppReconciler) rollOutBlueGreen(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, commit, image string, replicas int32) (rolloutState, error) {
+	if err := r.cleanupCanary(ctx, app); err != nil {
+		return rolloutProgressing, err
+	}
+
+	active := activeDeployment(app)
+	idle := slotDeployment(app, idleSlot(app))
+	activeImage, err := r.deploymentImage(ctx, app.Namespace, active)
+	if err != nil {
+		return rolloutProgressing, err
+	}
+
+	if activeImage == "" || activeImage == image {
+		// First deployment, or nothing new: keep the active slot up to date
+		if err := r.ensureDeployment(ctx, app, active, image, replicas); err != nil {
+			return rolloutProgressing, err
+		}
+		if err := r.deleteDeployment(ctx, app, idle); err != nil {
+			return rolloutProgressing, err
+		}
+		if err := r.clearCandidate(ctx, app); err != nil {
+			return rolloutProgressing, err
+		}
+		return r.checkRollout(ctx, app, active, commit, image, replicas)
+	}
+	if commit == app.Status.RolledBackCommit {
+		// The candidate failed and there is nothing to revert to, keep serving the active slot
+		return rolloutFailed, r.deleteDeployment(ctx, app, idle)
+	}
+
+	if err := r.ensureDeployment(ctx, app, idle, image, replicas); err != nil {
+		return rolloutProgressing, err
+	}
+	state, err := r.checkCandidate(ctx, app, idle, commit, image, replicas)
+	if err != nil {
+		return state, err
+	}
+	switch state {
+	case rolloutHealthy:
+		log.Info("Promoting blue/green slot", "app", app.Name, "commit", commit, "slot", idleSlot(app))
+		if err := r.ensureService(ctx, app, app.Name, idle); err != nil {
+			return state, err
+		}
+		app.Status.ActiveSlot = idleSlot(app)
+		app.Status.LastHealthyCommit = commit
+		app.Status.CandidateCommit = ""
+		app.Status.CandidateHealthySince = ""
+		if err := r.Status().Update(ctx, app); err != nil {
+			return state, err
+		}
+		// The previous slot is removed on the next reconcile
+		return rolloutProgressing, nil
+	case rolloutRolledBack:
+		return state, r.deleteDeployment(ctx, app, idle)
+	}
+	return state, nil
+}
+
+// rollOutCanary runs a single replica of the new image behind a weighted
+// canary Ingress and rolls the stable Deployment forward once the canary
+// stayed healthy for the health window.
+func (r *GitshipAppReconciler) rollOutCanary(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, commit, image string, replicas int32) (rolloutState, error) {
+	if app.Status.ActiveSlot == slotGreen {
+		// Left over from a blue/green rollout, move back to the app's Deployment first
+		return r.rollOutRolling(ctx, app, commit, image, replicas)
+	}
+
+	stableImage, err := r.deploymentImage(ctx, app.Namespace, app.Name)
+	if err != nil {
+		return rolloutProgressing, err
+	}
+	if stableImage == "" || stableImage == image {
+		if err := r.cleanupCanary(ctx, app); err != nil {
+			return rolloutProgressing, err
+		}
+		if err := r.clearCandidate(ctx, app); err != nil {
+			return rolloutProgressing, err
+		}
+		if err := r.ensureDeployment(ctx, app, app.Name, image, replicas); err != nil {
+			return rolloutProgressing, err
+		}
+		return r.checkRollout(ctx, app, app.Name, commit, image, replicas)
+	}
+	if commit == app.Status.RolledBackCommit {
+		// The canary failed and there is nothing to revert to, keep serving the stable image
+		return rolloutFailed, r.cleanupCanary(ctx, app)
+	}
+
+	canary := canaryName(app)
+	if err := r.ensureDeployment(ctx, app, canary, image, 1); err != nil {
+		return rolloutProgressing, err
+	}
+	if err := r.ensureService(ctx, app, canary, canary); err != nil {
+		return rolloutProgressing, err
+	}
+	state, err := r.checkCandidate(ctx, app, canary, commit, image, 1)
+	if err != nil {
+		return state, err
+	}
+	switch state {
+	case rolloutHealthy:
+		log.Info("Promoting canary", "app", app.Name, "commit", commit)
+		if err := r.ensureDeployment(ctx, app, app.Name, image, replicas); err != nil {
+			return state, err
+		}
+		// The canary is removed once the stable Deployment runs the image
+		return rolloutProgressing, nil
+	case rolloutRolledBack:
+		return state, r.cleanupCanary(ctx, app)
+	}
+	return state, nil
+}
+
+// checkCandidate watches the Deployment depName running a blue/green or canary
+// candidate. It reports healthy once the candidate stayed healthy for the
+// health window, and aborts the rollout when the candidate fails, a container
+// restarts, or it loses readiness.
+func (r *GitshipAppReconciler) checkCandidate(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, depName, commit, image string, replicas int32) (rolloutState, error) {
+	changed := false
+	if app.Status.CandidateCommit != commit {
+		app.Status.CandidateCommit = commit
+		app.Status.CandidateHealthySince = ""
+		changed = true
+	}
+
+	dep, pods, err := r.deploymentAndPods(ctx, app.Namespace, depName)
+	if err != nil {
+		return rolloutProgressing, err
+	}
+	state, reason := rolloutProgressing, ""
+	if dep != nil {
+		state, reason = rolloutHealth(dep, pods, image, replicas)
+	}
+	if state != rolloutFailed {
+		if restarted := restartedPod(pods, image); restarted != "" {
+			state, reason = rolloutFailed, restarted
+		} else if state == rolloutProgressing && app.Status.CandidateHealthySince != "" {
+			state, reason = rolloutFailed, "Candidate lost readiness during the health window"
+		}
+	}
+
+	now := time.Now()
+	switch state {
+	case rolloutFailed:
+		if commit != app.Status.LatestBuildID {
+			log.Info("Candidate failed", "app", app.Name, "commit", commit, "reason", reason)
+			break
+		}
+		log.Info("Candidate failed, aborting rollout", "app", app.Name, "commit", commit, "reason", reason)
+		app.Status.RolledBackCommit = commit
+		app.Status.RollbackReason = reason
+		app.Status.Phase = phaseRolledBack
+		app.Status.CandidateCommit = ""
+		app.Status.CandidateHealthySince = ""
+		changed = true
+		state = rolloutRolledBack
+	case rolloutHealthy:
+		if app.Status.CandidateHealthySince == "" {
+			app.Status.CandidateHealthySince = now.Format(time.RFC3339)
+			changed = true
+		}
+		if !healthWindowElapsed(app.Status.CandidateHealthySince, healthWindow(app.Spec.Rollout), now) {
+			state = rolloutProgressing
+		}
+	}
+
+	if changed {
+		if err := r.Status().Update(ctx, app); err != nil {
+			return state, err
+		}
+	}
+	return state, nil
+}
+
+// healthWindowElapsed reports whether a candidate healthy since the RFC 3339
+// time since has been healthy for window.
+func healthWindowElapsed(since string, window time.Duration, now time.Time) bool {
+	t, err := time.Parse(time.RFC3339, since)
+	return err == nil && now.Sub(t) >= window
+}
+
+// restartedPod describes the first pod running image with a restarted
+// container, empty if there is none.
+func restartedPod(pods []corev1.Pod, image string) string {
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || !podRunsImage(&pod, image) {
+			continue
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.RestartCount > 0 {
+				return fmt.Sprintf("Pod %s: container %s restarted %d times", pod.Name, cs.Name, cs.RestartCount)
+			}
+		}
+	}
+	return ""
+}
+
+func (r *GitshipAppReconciler) clearCandidate(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) error {
+	if app.Status.CandidateCommit == "" && app.Status.CandidateHealthySince == "" {
+		return nil
+	}
+	app.Status.CandidateCommit = ""
+	app.Status.CandidateHealthySince = ""
+	return r.Status().Update(ctx, app)
+}
+
+// deploymentImage returns the image the Deployment name runs, empty if it
+// doesn't exist.
+func (r *GitshipAppReconciler) deploymentImage(ctx context.Context, namespace, name string) (string, error) {
+	dep := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, dep); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	if len(dep.Spec.Template.Spec.Containers) == 0 {
+		return "", nil
+	}
+	return dep.Spec.Template.Spec.Containers[0].Image, nil
+}
+
+// ensureCanaryIngress routes the canary weight of the app's ingress traffic to
+// the canary Service while a canary rollout is in progress, and removes the
+// canary Ingress otherwise. TLS is terminated by the main Ingress.
+func (r *GitshipAppReconciler) ensureCanaryIngress(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) error {
+	name := canaryName(app)
+	if app.Spec.Rollout.Strategy != rolloutStrategyCanary || app.Status.CandidateCommit == "" || len(app.Spec.Ingresses) == 0 {
+		return r.deleteOwned(ctx, app, &networkingv1.Ingress{}, name)
+	}
+
+	ingressClassName := r.ingressClassName()
+	annotations := map[string]string{
+		"kubernetes.io/ingress.class":               ingressClassName,
+		"nginx.ingress.kubernetes.io/canary":        "true",
+		"nginx.ingress.kubernetes.io/canary-weight": strconv.Itoa(int(canaryWeight(app.Spec.Rollout))),
+	}
+	rules := ingressRules(app, name)
+
+	ing := &networkingv1.Ingress{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, ing)
+	if err != nil && client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err != nil {
+		log.Info("Creating canary Ingress", "app", app.Name, "weight", annotations["nginx.ingress.kubernetes.io/canary-weight"])
+		newIng := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   app.Namespace,
+				Annotations: annotations,
+			},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: &ingressClassName,
+				Rules:            rules,
+			},
+		}
+		if err := ctrl.SetControllerReference(app, newIng, r.Scheme); err != nil {
+			return err
+		}
+		return r.Create(ctx, newIng)
+	}
+	if !metav1.IsControlledBy(ing, app) {
+		return fmt.Errorf("ingress %s isn't controlled by app %s", name, app.Name)
+	}
+
+	ing.Annotations = annotations
+	ing.Spec.IngressClassName = &ingressClassName
+	ing.Spec.Rules = rules
+	return r.Update(ctx, ing)
+}
+
+// cleanupCanary removes the canary Deployment, Service and Ingress.
+func (r *GitshipAppReconciler) cleanupCanary(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) error {
+	name := canaryName(app)
+	if err := r.deleteOwned(ctx, app, &networkingv1.Ingress{}, name); err != nil {
+		return err
+	}
+	if err := r.deleteOwned(ctx, app, &corev1.Service{}, name); err != nil {
+		return err
+	}
+	return r.deleteDeployment(ctx, app, name)
+}
+
+func (r *GitshipAppReconciler) deleteDeployment(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, name string) error {
+	return r.deleteOwned(ctx, app, &appsv1.Deployment{}, name)
+}
+
+// deleteOwned deletes the object name if it's in the cache and controlled by
+// the app. Objects of the same name owned by someone else are left alone.
+func (r *GitshipAppReconciler) deleteOwned(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, obj client.Object, name string) error {
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(obj, app) {
+		return nil
+	}
+	log.Info("Deleting", "kind", fmt.Sprintf("%T", obj), "name", name)
+	return client.IgnoreNotFound(r.Delete(ctx, obj))
+}
@@ -0,0 +1,135 @@
+package gitshipio
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/gitutil"
+)
+
+// errRefNotFound is returned when the branch or tag an app tracks doesn't exist.
+var errRefNotFound = errors.New("ref not found")
+
+// invalidImageTagChars matches the characters a git tag may contain but an image tag may not.
+var invalidImageTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// resolveTag returns the tag a tag source selects among refs: the highest
+// version satisfying a semver constraint, the highest tag matching a pattern,
+// or the tag itself.
+func resolveTag(value string, refs []*plumbing.Reference) (string, error) {
+	switch {
+	case gitutil.IsSemverConstraint(value):
+		tag, err := gitutil.HighestSemverTag(value, tagNames(refs))
+		if err != nil {
+			return "", err
+		}
+		if tag == "" {
+			return "", fmt.Errorf("%w: no tag satisfies %q", errRefNotFound, value)
+		}
+		return tag, nil
+	case gitutil.IsTagPattern(value):
+		tag := gitutil.LatestTag(value, tagNames(refs))
+		if tag == "" {
+			return "", fmt.Errorf("%w: no tag matches %q", errRefNotFound, value)
+		}
+		return tag, nil
+	}
+	return value, nil
+}
+
+// buildTag returns the git tag commit was last successfully built for, "" if none.
+func buildTag(app *gitshipiov1alpha1.GitshipApp, commit string) string {
+	for _, record := range app.Status.BuildHistory {
+		if record.CommitID == commit && record.Status == buildPhaseSucceeded {
+			return record.Tag
+		}
+	}
+	return ""
+}
+
+// imageTag turns a git tag into a valid image tag.
+func imageTag(tag string) string {
+	tag = invalidImageTagChars.ReplaceAllString(tag, "-")
+	tag = strings.TrimLeft(tag, ".-")
+	if len(tag) > 128 {
+		tag = tag[:128]
+	}
+	return tag
+}
+
+// defaultBranch returns the branch the remote's HEAD points to. Servers that
+// don't advertise HEAD as a symbolic ref get the branch at the same commit,
+// preferring main and master.
+func defaultBranch(refs []*plumbing.Reference) string {
+	var head *plumbing.Reference
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD {
+			head = ref
+		}
+	}
+	if head == nil {
+		return ""
+	}
+	if head.Type() == plumbing.SymbolicReference {
+		if head.Target().IsBranch() {
+			return head.Target().Short()
+		}
+		return ""
+	}
+
+	branch := ""
+	for _, ref := range refs {
+		if !ref.Name().IsBranch() || ref.Hash() != head.Hash() {
+			continue
+		}
+		name := ref.Name().Short()
+		if branch == "" || name == "main" || (name == "master" && branch != "main") {
+			branch = name
+		}
+	}
+	return branch
+}
+
+// tagNames returns the short names of the tags among refs.
+func tagNames(refs []*plumbing.Reference) []string {
+	var tags []string
+	for _, ref := range refs {
+		if ref.Name().IsTag() {
+			tags = append(tags, strings.TrimSuffix(ref.Name().Short(), "^{}"))
+		}
+	}
+	return tags
+}
+
+// setSourceMissing marks the app's tracked branch or tag as missing. It
+// reports whether the condition changed.
+func setSourceMissing(app *gitshipiov1alpha1.GitshipApp, reason, message string) bool {
+	return meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               gitshipiov1alpha1.ConditionSourceMissing,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: app.Generation,
+	})
+}
+
+// clearSourceMissing marks the tracked branch or tag as found again. It
+// reports whether the condition changed.
+func clearSourceMissing(app *gitshipiov1alpha1.GitshipApp) bool {
+	if meta.FindStatusCondition(app.Status.Conditions, gitshipiov1alpha1.ConditionSourceMissing) == nil {
+		return false
+	}
+	return meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               gitshipiov1alpha1.ConditionSourceMissing,
+		Status:             metav1.ConditionFalse,
+		Reason:             "RefFound",
+		ObservedGeneration: app.Generation,
+	})
+}
@@ -0,0 +1,71 @@
+package gitshipio
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+func TestSourceMissingCondition(t *testing.T) {
+	g := NewWithT(t)
+	app := &gitshipiov1alpha1.GitshipApp{}
+	g.Expect(clearSourceMissing(app)).To(BeFalse())
+
+	g.Expect(setSourceMissing(app, "RefNotFound", "ref not found: refs/heads/feature")).To(BeTrue())
+	g.Expect(setSourceMissing(app, "RefNotFound", "ref not found: refs/heads/feature")).To(BeFalse())
+	g.Expect(meta.IsStatusConditionTrue(app.Status.Conditions, gitshipiov1alpha1.ConditionSourceMissing)).To(BeTrue())
+
+	g.Expect(clearSourceMissing(app)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionFalse(app.Status.Conditions, gitshipiov1alpha1.ConditionSourceMissing)).To(BeTrue())
+}
+
+func TestResolveTag(t *testing.T) {
+	g := NewWithT(t)
+	refs := []*plumbing.Reference{
+		plumbing.NewHashReference("refs/heads/main", plumbing.ZeroHash),
+		plumbing.NewHashReference("refs/tags/v1.4.0", plumbing.ZeroHash),
+		plumbing.NewHashReference("refs/tags/v1.4.3", plumbing.ZeroHash),
+		plumbing.NewHashReference("refs/tags/v1.5.0", plumbing.ZeroHash),
+	}
+	g.Expect(resolveTag("~1.4", refs)).To(Equal("v1.4.3"))
+	g.Expect(resolveTag("v1.*", refs)).To(Equal("v1.5.0"))
+	g.Expect(resolveTag("v1.4.0", refs)).To(Equal("v1.4.0"))
+	_, err := resolveTag(">=2", refs)
+	g.Expect(err).To(MatchError(errRefNotFound))
+}
+
+func TestResolveImageNamesUsesBuiltTag(t *testing.T) {
+	g := NewWithT(t)
+	app := &gitshipiov1alpha1.GitshipApp{}
+	app.Spec.ImageName = "web"
+	app.Spec.RegistrySecretRef = "registry"
+	app.Status.BuildHistory = []gitshipiov1alpha1.BuildRecord{
+		{CommitID: "bbb", Status: buildPhaseFailed, Tag: "v1.5.0"},
+		{CommitID: "aaa", Status: buildPhaseSucceeded, Tag: "v1.4.3+build/1"},
+	}
+	r := &GitshipAppReconciler{}
+
+	_, image := r.resolveImageNames(app, "aaa")
+	g.Expect(image).To(Equal("web:v1.4.3-build-1"))
+	_, image = r.resolveImageNames(app, "bbb")
+	g.Expect(image).To(Equal("web:bbb"))
+}
+
+func TestDefaultBranch(t *testing.T) {
+	g := NewWithT(t)
+	hash := plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904")
+	develop := plumbing.NewHashReference("refs/heads/develop", hash)
+	main := plumbing.NewHashReference("refs/heads/main", plumbing.ZeroHash)
+
+	symbolic := plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/develop")
+	g.Expect(defaultBranch([]*plumbing.Reference{symbolic, main, develop})).To(Equal("develop"))
+
+	// Without the symref capability HEAD is only a commit
+	detached := plumbing.NewHashReference(plumbing.HEAD, hash)
+	g.Expect(defaultBranch([]*plumbing.Reference{detached, main, develop})).To(Equal("develop"))
+	g.Expect(defaultBranch([]*plumbing.Reference{main, develop})).To(BeEmpty())
+}
@@ -39,6 +39,16 @@ import (
 
 // These tests use Ginkgo (BDD-style Go testing framework). Refer to
 // http://onsi.github.io/ginkgo/ to learn more about Ginkgo.
+//
+// Only specs that need a cluster belong to this suite, as its BeforeSuite
+// starts envtest's kube-apiserver and etcd and fails without them. Tests that
+// get by with the fake client are plain Go tests using gomega's NewWithT, so
+// that they run without envtest:
+//
+//	go test ./internal/controller/gitship.io/ -skip TestControllers
+//
+// Packages without envtest, such as internal/webhook, keep their tests in a
+// Ginkgo suite of their own.
 
 var (
 	ctx       context.Context
@@ -0,0 +1,275 @@
+package gitshipio
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/cloudflare"
+	"github.com/gitshipio/gitship/internal/githubapp"
+)
+
+const (
+	// appFinalizer tears down what a deleted app leaves behind outside of
+	// its owner references
+	appFinalizer = "gitship.io/cleanup"
+	// legacyAppFinalizer is what appFinalizer was called when it only deleted
+	// registry images. Apps still carrying it are finalized the same way.
+	legacyAppFinalizer = "gitship.io/registry-cleanup"
+	// teardownTimeout is how long a deleted app retries failing teardown
+	// steps before giving up on them
+	teardownTimeout = 10 * time.Minute
+
+	// Deletion policies of volumes
+	volumeRetain = "Retain"
+	volumeDelete = "Delete"
+	// labelManaged marks the Secrets the dashboard creates for an app
+	labelManaged = "gitship.io/managed"
+	// webhookPath is where the dashboard points an app's repository webhook
+	webhookPath = "/api/webhooks/"
+	// Config keys of Cloudflare tunnel integrations. The API token is
+	// optional and lets routes to deleted apps be removed.
+	tunnelTokenKey    = "token"
+	tunnelAPITokenKey = "apiToken"
+)
+
+// cloudflareAPIURL and githubAPIURL are replaced in tests
+var (
+	cloudflareAPIURL = cloudflare.DefaultBaseURL
+	githubAPIURL     = githubapp.DefaultBaseURL
+)
+
+// teardownStep removes one kind of artifact of a deleted app.
+type teardownStep struct {
+	name string
+	run  func(context.Context, *gitshipiov1alpha1.GitshipApp) error
+	// Never given up on, as giving up would lose data
+	required bool
+}
+
+// teardownSteps lists the steps run when an app is deleted, in order.
+func (r *GitshipAppReconciler) teardownSteps() []teardownStep {
+	return []teardownStep{
+		// First, so that the garbage collector can't delete them meanwhile
+		{name: "volumes", run: r.retainVolumes, required: true},
+		// Before the images, so that no delivery starts another build
+		{name: "webhooks", run: r.deleteRepositoryWebhooks},
+		{name: "tunnels", run: r.deleteTunnelRoutes},
+		{name: "images", run: r.deleteAppImages},
+		{name: "secrets", run: r.deleteAppSecrets},
+	}
+}
+
+// finalizeApp runs the teardown steps of a deleted app and then lets it go.
+// Failing steps are retried for teardownTimeout.
+func (r *GitshipAppReconciler) finalizeApp(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(app, appFinalizer) && !controllerutil.ContainsFinalizer(app, legacyAppFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	expired := time.Since(app.DeletionTimestamp.Time) > teardownTimeout
+	for _, step := range r.teardownSteps() {
+		err := step.run(ctx, app)
+		if err == nil {
+			continue
+		}
+		if step.required || !expired {
+			log.Error(err, "Teardown failed, retrying", "app", app.Name, "step", step.name)
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+		}
+		log.Error(err, "Giving up on teardown step", "app", app.Name, "step", step.name)
+	}
+	controllerutil.RemoveFinalizer(app, appFinalizer)
+	controllerutil.RemoveFinalizer(app, legacyAppFinalizer)
+	return ctrl.Result{}, r.Update(ctx, app)
+}
+
+// retainVolumes releases the PVCs of volumes with the Retain deletion policy
+// from the app, so that they outlive it.
+func (r *GitshipAppReconciler) retainVolumes(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) error {
+	for _, vol := range app.Spec.Volumes {
+		if vol.DeletionPolicy != volumeRetain {
+			continue
+		}
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := r.Get(ctx, types.NamespacedName{Name: volumeClaimName(app, vol), Namespace: app.Namespace}, pvc)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !metav1.IsControlledBy(pvc, app) {
+			continue
+		}
+		log.Info("Retaining PVC", "app", app.Name, "pvc", pvc.Name)
+		pvc.OwnerReferences = removeOwner(pvc.OwnerReferences, app.UID)
+		if err := r.Update(ctx, pvc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeOwner returns refs without the reference to the owner with uid.
+func removeOwner(refs []metav1.OwnerReference, uid types.UID) []metav1.OwnerReference {
+	var kept []metav1.OwnerReference
+	for _, ref := range refs {
+		if ref.UID != uid {
+			kept = append(kept, ref)
+		}
+	}
+	return kept
+}
+
+// deleteRepositoryWebhooks deletes the webhooks the dashboard registered on
+// the app's GitHub repository, using the namespace's GitHub token. Nothing is
+// deleted without one.
+func (r *GitshipAppReconciler) deleteRepositoryWebhooks(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) error {
+	if app.Spec.RepoURL == "" || app.Labels[labelPreviewOf] != "" {
+		return nil
+	}
+	owner, repo, ok := githubRepository(app.Spec.RepoURL, githubAPIURL)
+	if !ok {
+		return nil
+	}
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: defaultTokenSecret, Namespace: app.Namespace}, secret)
+	if apierrors.IsNotFound(err) || (err == nil && len(secret.Data[tokenKey]) == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	shared, err := r.repositoryShared(ctx, app, owner, repo)
+	if err != nil {
+		return err
+	}
+
+	github := r.GitHubApp
+	if github == nil {
+		// The hooks are deleted with the user's token, no GitHub App needed
+		github = githubapp.NewClient()
+	}
+	deleted, err := github.DeleteHooks(ctx, githubAPIURL, string(secret.Data[tokenKey]), owner, repo, func(hookURL string) bool {
+		return isAppWebhook(hookURL, app, shared)
+	})
+	if deleted > 0 {
+		log.Info("Deleted repository webhooks", "app", app.Name, "repository", owner+"/"+repo, "count", deleted)
+	}
+	return err
+}
+
+// repositoryShared reports whether another app, in any namespace, builds
+// owner/repo on GitHub. Previews are left out, as they are deleted with their
+// parent.
+func (r *GitshipAppReconciler) repositoryShared(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, owner, repo string) (bool, error) {
+	apps := &gitshipiov1alpha1.GitshipAppList{}
+	if err := r.List(ctx, apps); err != nil {
+		return false, err
+	}
+	for _, other := range apps.Items {
+		if other.UID == app.UID || other.DeletionTimestamp != nil || other.Labels[labelPreviewOf] != "" {
+			continue
+		}
+		otherOwner, otherRepo, ok := githubRepository(other.Spec.RepoURL, githubAPIURL)
+		if ok && strings.EqualFold(otherOwner, owner) && strings.EqualFold(otherRepo, repo) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isAppWebhook reports whether a webhook of the app's repository delivers to
+// the app, e.g. https://gitship.example.com/api/webhooks/github?app=team%2Fweb.
+// Hooks registered before the app parameter was added deliver to every app of
+// the repository, so they are only the app's if the repository isn't shared.
+func isAppWebhook(hookURL string, app *gitshipiov1alpha1.GitshipApp, shared bool) bool {
+	u, err := url.Parse(hookURL)
+	if err != nil || !strings.Contains(u.Path, webhookPath) {
+		return false
+	}
+	if target := u.Query().Get("app"); target != "" {
+		return target == app.Namespace+"/"+app.Name
+	}
+	return !shared
+}
+
+// deleteTunnelRoutes removes the routes to the app from the Cloudflare
+// tunnels of its namespace. Routes of tunnels whose integration has no API
+// token are left in place.
+func (r *GitshipAppReconciler) deleteTunnelRoutes(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) error {
+	integrations := &gitshipiov1alpha1.GitshipIntegrationList{}
+	if err := r.List(ctx, integrations, client.InNamespace(app.Namespace)); err != nil {
+		return err
+	}
+	for _, integration := range integrations.Items {
+		if !strings.EqualFold(integration.Spec.Type, "cloudflare-tunnel") {
+			continue
+		}
+		apiToken := integration.Spec.Config[tunnelAPITokenKey]
+		if apiToken == "" {
+			log.Info("Leaving tunnel routes to the app in place, the integration has no API token", "app", app.Name, "integration", integration.Name)
+			continue
+		}
+		tunnel, err := cloudflare.ParseTunnelToken(integration.Spec.Config[tunnelTokenKey])
+		if err != nil {
+			return fmt.Errorf("integration %s: %w", integration.Name, err)
+		}
+		cf := cloudflare.NewClient(apiToken)
+		cf.BaseURL = cloudflareAPIURL
+		removed, err := cf.RemoveRoutes(ctx, tunnel, func(service string) bool {
+			return isAppService(service, app)
+		})
+		if err != nil {
+			return fmt.Errorf("integration %s: %w", integration.Name, err)
+		}
+		if removed > 0 {
+			log.Info("Removed tunnel routes", "app", app.Name, "integration", integration.Name, "count", removed)
+		}
+	}
+	return nil
+}
+
+// isAppService reports whether a tunnel route's service is one of the app's
+// Services, e.g. http://web:8080 or http://web-canary.team.svc:8080.
+func isAppService(service string, app *gitshipiov1alpha1.GitshipApp) bool {
+	u, err := url.Parse(service)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.TrimSuffix(u.Hostname(), ".cluster.local"), ".svc")
+	for _, name := range []string{app.Name, canaryName(app)} {
+		if host == name || host == name+"."+app.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteAppSecrets deletes the Secrets the dashboard created for the app,
+// such as its deploy key and webhook secret.
+func (r *GitshipAppReconciler) deleteAppSecrets(ctx context.Context, app *gitshipiov1alpha1.GitshipApp) error {
+	secrets := &corev1.SecretList{}
+	if err := r.List(ctx, secrets,
+		client.InNamespace(app.Namespace),
+		client.MatchingLabels{"gitship.io/app": app.Name, labelManaged: "true"}); err != nil {
+		return err
+	}
+	for i := range secrets.Items {
+		if err := r.Delete(ctx, &secrets.Items[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
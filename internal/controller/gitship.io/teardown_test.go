@@ -0,0 +1,225 @@
+package gitshipio
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/cloudflare"
+	"github.com/gitshipio/gitship/internal/githubapp"
+)
+
+// deletedApp returns a deleted app with a retained and a deleted volume.
+func deletedApp() *gitshipiov1alpha1.GitshipApp {
+	now := metav1.Now()
+	app := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{
+		Name:              "web",
+		Namespace:         "team",
+		UID:               "web-uid",
+		Finalizers:        []string{appFinalizer},
+		DeletionTimestamp: &now,
+	}}
+	app.Spec.Image = &gitshipiov1alpha1.ImageSource{Repository: "ghcr.io/team/web"}
+	app.Spec.Volumes = []gitshipiov1alpha1.VolumeConfig{
+		{Name: "data", MountPath: "/data", Size: "1Gi", DeletionPolicy: volumeRetain},
+		{Name: "tmp", MountPath: "/tmp", Size: "1Gi", DeletionPolicy: volumeDelete},
+	}
+	return app
+}
+
+func ownedClaim(app *gitshipiov1alpha1.GitshipApp, name string) *corev1.PersistentVolumeClaim {
+	isController := true
+	return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: "team",
+		Labels:    map[string]string{"gitship.io/app": "web"},
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: gitshipiov1alpha1.GroupVersion.String(),
+			Kind:       "GitshipApp",
+			Name:       "web",
+			UID:        app.UID,
+			Controller: &isController,
+		}},
+	}}
+}
+
+func TestFinalizeAppRetainsVolumesAndDeletesSecrets(t *testing.T) {
+	g := NewWithT(t)
+	app := deletedApp()
+	managed := testSecret("web-ssh-key", map[string]string{sshPrivateKeyKey: "key"})
+	managed.Labels = map[string]string{"gitship.io/app": "web", labelManaged: "true"}
+	unmanaged := testSecret("web-db", map[string]string{"password": "pw"})
+	unmanaged.Labels = map[string]string{"gitship.io/app": "web"}
+	r := retentionReconciler(t, "", app, ownedClaim(app, "web-data"), ownedClaim(app, "web-tmp"), managed, unmanaged)
+	ctx := context.Background()
+
+	_, err := r.finalizeApp(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	g.Expect(r.Get(ctx, types.NamespacedName{Name: "web-data", Namespace: "team"}, pvc)).To(Succeed())
+	g.Expect(pvc.OwnerReferences).To(BeEmpty())
+	g.Expect(r.Get(ctx, types.NamespacedName{Name: "web-tmp", Namespace: "team"}, pvc)).To(Succeed())
+	g.Expect(pvc.OwnerReferences).To(HaveLen(1))
+
+	err = r.Get(ctx, types.NamespacedName{Name: "web-ssh-key", Namespace: "team"}, &corev1.Secret{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	g.Expect(r.Get(ctx, types.NamespacedName{Name: "web-db", Namespace: "team"}, &corev1.Secret{})).To(Succeed())
+
+	err = r.Get(ctx, types.NamespacedName{Name: "web", Namespace: "team"}, &gitshipiov1alpha1.GitshipApp{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestFinalizeAppRemovesLegacyFinalizer(t *testing.T) {
+	g := NewWithT(t)
+	app := deletedApp()
+	app.Finalizers = []string{legacyAppFinalizer}
+	r := retentionReconciler(t, "", app, ownedClaim(app, "web-data"))
+	ctx := context.Background()
+
+	_, err := r.finalizeApp(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	g.Expect(r.Get(ctx, types.NamespacedName{Name: "web-data", Namespace: "team"}, pvc)).To(Succeed())
+	g.Expect(pvc.OwnerReferences).To(BeEmpty())
+	err = r.Get(ctx, types.NamespacedName{Name: "web", Namespace: "team"}, &gitshipiov1alpha1.GitshipApp{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestEnsureVolumesAdoptsRetainedClaim(t *testing.T) {
+	g := NewWithT(t)
+	app := deletedApp()
+	app.DeletionTimestamp = nil
+	app.Finalizers = nil
+	retained := ownedClaim(app, "web-data")
+	retained.OwnerReferences = nil
+	r := retentionReconciler(t, "", app, retained, ownedClaim(app, "web-tmp"))
+	ctx := context.Background()
+
+	g.Expect(r.ensureVolumes(ctx, app)).To(Succeed())
+	pvc := &corev1.PersistentVolumeClaim{}
+	g.Expect(r.Get(ctx, types.NamespacedName{Name: "web-data", Namespace: "team"}, pvc)).To(Succeed())
+	g.Expect(metav1.IsControlledBy(pvc, app)).To(BeTrue())
+}
+
+func TestIsAppWebhook(t *testing.T) {
+	g := NewWithT(t)
+	app := deletedApp()
+	g.Expect(isAppWebhook("https://gitship.example.com/api/webhooks/github?app=team%2Fweb", app, true)).To(BeTrue())
+	g.Expect(isAppWebhook("https://gitship.example.com/api/webhooks/github?app=team%2Fweb-2", app, false)).To(BeFalse())
+	g.Expect(isAppWebhook("https://ci.example.com/hook?app=team%2Fweb", app, false)).To(BeFalse())
+
+	// Registered without the app parameter
+	g.Expect(isAppWebhook("https://gitship.example.com/api/webhooks/github", app, false)).To(BeTrue())
+	g.Expect(isAppWebhook("https://gitship.example.com/api/webhooks/github", app, true)).To(BeFalse())
+	g.Expect(isAppWebhook("https://ci.example.com/hook", app, false)).To(BeFalse())
+}
+
+func TestRepositoryShared(t *testing.T) {
+	g := NewWithT(t)
+	app := deletedApp()
+	app.Spec.RepoURL = "https://github.com/team/web"
+	preview := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{
+		Name: "web-pr-1", Namespace: "team", UID: "preview-uid",
+		Labels: map[string]string{labelPreviewOf: "web"},
+	}}
+	preview.Spec.RepoURL = app.Spec.RepoURL
+	other := &gitshipiov1alpha1.GitshipApp{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "team", UID: "api-uid"}}
+	other.Spec.RepoURL = "https://github.com/team/api"
+	r := retentionReconciler(t, "", app, preview, other)
+	ctx := context.Background()
+
+	shared, err := r.repositoryShared(ctx, app, "team", "web")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(shared).To(BeFalse())
+
+	other.Spec.RepoURL = "git@github.com:Team/Web.git"
+	g.Expect(r.Update(ctx, other)).To(Succeed())
+	shared, err = r.repositoryShared(ctx, app, "team", "web")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(shared).To(BeTrue())
+}
+
+func TestIsAppService(t *testing.T) {
+	g := NewWithT(t)
+	app := deletedApp()
+	g.Expect(isAppService("http://web:8080", app)).To(BeTrue())
+	g.Expect(isAppService("http://web-canary.team.svc.cluster.local:8080", app)).To(BeTrue())
+	g.Expect(isAppService("https://web.team.svc", app)).To(BeTrue())
+	g.Expect(isAppService("http://web.other:8080", app)).To(BeFalse())
+	g.Expect(isAppService("http://web-api:8080", app)).To(BeFalse())
+	g.Expect(isAppService("http_status:404", app)).To(BeFalse())
+}
+
+func TestFinalizeAppRemovesTunnelRoutes(t *testing.T) {
+	g := NewWithT(t)
+	var written string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			body, _ := io.ReadAll(r.Body)
+			written = string(body)
+		}
+		_, _ = fmt.Fprint(w, `{"success":true,"result":{"config":{"ingress":[{"hostname":"web.example.com","service":"http://web:8080"},{"service":"http_status:404"}]}}}`)
+	}))
+	defer server.Close()
+	cloudflareAPIURL = server.URL
+	defer func() { cloudflareAPIURL = cloudflare.DefaultBaseURL }()
+
+	tunnel := &gitshipiov1alpha1.GitshipIntegration{ObjectMeta: metav1.ObjectMeta{Name: "cloudflare-tunnel", Namespace: "team"}}
+	tunnel.Spec.Type = "cloudflare-tunnel"
+	tunnel.Spec.Config = map[string]string{
+		tunnelTokenKey:    base64.StdEncoding.EncodeToString([]byte(`{"a":"account","t":"tunnel","s":"secret"}`)),
+		tunnelAPITokenKey: "cf_api",
+	}
+	app := deletedApp()
+	r := retentionReconciler(t, "", app, tunnel)
+
+	_, err := r.finalizeApp(context.Background(), app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(written).To(Equal(`{"config":{"ingress":[{"service":"http_status:404"}]}}`))
+}
+
+func TestDeleteRepositoryWebhooksWithToken(t *testing.T) {
+	g := NewWithT(t)
+	var deleted []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/team/web/hooks", func(w http.ResponseWriter, r *http.Request) {
+		g.Expect(r.Header.Get("Authorization")).To(Equal("Bearer gho_user"))
+		_, _ = fmt.Fprint(w, `[{"id":1,"config":{"url":"https://gitship.example.com/api/webhooks/github?app=team%2Fweb"}}]`)
+	})
+	mux.HandleFunc("DELETE /repos/team/web/hooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		deleted = append(deleted, r.PathValue("id"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	githubAPIURL = server.URL
+	defer func() { githubAPIURL = githubapp.DefaultBaseURL }()
+
+	app := deletedApp()
+	app.Spec.RepoURL = server.URL + "/team/web"
+	ctx := context.Background()
+
+	// Without the namespace's token the webhooks are left alone
+	r := retentionReconciler(t, "", app)
+	g.Expect(r.deleteRepositoryWebhooks(ctx, app)).To(Succeed())
+	g.Expect(deleted).To(BeEmpty())
+
+	// The GitHub App isn't needed to delete them with the token
+	r = retentionReconciler(t, "", app, testSecret(defaultTokenSecret, map[string]string{tokenKey: "gho_user"}))
+	g.Expect(r.GitHubApp).To(BeNil())
+	g.Expect(r.deleteRepositoryWebhooks(ctx, app)).To(Succeed())
+	g.Expect(deleted).To(Equal([]string{"1"}))
+}
@@ -0,0 +1,266 @@
+package githubapp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultBaseURL is the API of github.com. GitHub Enterprise Server serves it
+// at https://<host>/api/v3.
+const DefaultBaseURL = "https://api.github.com"
+
+// ErrNotInstalled is returned when the GitHub App isn't installed on a repository.
+var ErrNotInstalled = errors.New("github app is not installed on the repository")
+
+// refreshBefore is how long before expiry a cached token is replaced, so that
+// a build started with it can still clone.
+const refreshBefore = 15 * time.Minute
+
+// notInstalledRetry is how long a repository the app isn't installed on is
+// remembered before asking GitHub again.
+const notInstalledRetry = 5 * time.Minute
+
+// App identifies a GitHub App and holds the key its JWTs are signed with.
+type App struct {
+	ID      int64
+	Key     *rsa.PrivateKey
+	BaseURL string
+}
+
+// ParseApp reads a GitHub App's ID and PEM encoded private key. An empty
+// baseURL selects github.com.
+func ParseApp(id string, privateKey []byte, baseURL string) (App, error) {
+	appID, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+	if err != nil {
+		return App{}, fmt.Errorf("invalid app ID %q", id)
+	}
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return App{}, errors.New("private key is not PEM encoded")
+	}
+	var key *rsa.PrivateKey
+	if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		parsed, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		var ok bool
+		if key, ok = parsed.(*rsa.PrivateKey); pkcs8Err != nil || !ok {
+			return App{}, fmt.Errorf("invalid private key: %w", err)
+		}
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return App{ID: appID, Key: key, BaseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// JWT returns a token authenticating as the app, valid for nine minutes.
+func (a App) JWT(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	// Backdated against clock drift, as GitHub recommends
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Token is an installation access token.
+type Token struct {
+	Value     string
+	ExpiresAt time.Time
+}
+
+// Client mints installation tokens scoped to single repositories, caching
+// them until shortly before they expire.
+type Client struct {
+	HTTP *http.Client
+	// now is replaced in tests
+	now func() time.Time
+
+	// mints deduplicates concurrent requests for a repository's token
+	mints singleflight.Group
+
+	mu            sync.Mutex
+	installations map[string]int64
+	tokens        map[string]Token
+	// When repositories were found without an installation
+	notInstalled map[string]time.Time
+	// The app last parsed by LoadApp and the version of its credentials
+	app        App
+	appErr     error
+	appVersion string
+}
+
+// NewClient returns a Client with an empty token cache.
+func NewClient() *Client {
+	return &Client{
+		HTTP:          &http.Client{Timeout: 15 * time.Second},
+		now:           time.Now,
+		installations: map[string]int64{},
+		tokens:        map[string]Token{},
+		notInstalled:  map[string]time.Time{},
+	}
+}
+
+// LoadApp is ParseApp, but only parses the credentials again when version
+// differs from that of the previous call. Callers pass e.g. the resource
+// version of the Secret the credentials are read from.
+func (c *Client) LoadApp(version, id string, privateKey []byte, baseURL string) (App, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if version == "" || version != c.appVersion {
+		c.app, c.appErr = ParseApp(id, privateKey, baseURL)
+		c.appVersion = version
+	}
+	return c.app, c.appErr
+}
+
+// RepositoryToken returns a token of the app's installation on owner/repo
+// that can read the repository's contents.
+func (c *Client) RepositoryToken(ctx context.Context, app App, owner, repo string) (Token, error) {
+	key := fmt.Sprintf("%s/%d/%s/%s", app.BaseURL, app.ID, strings.ToLower(owner), strings.ToLower(repo))
+	c.mu.Lock()
+	token, cached := c.tokens[key]
+	checked, notInstalled := c.notInstalled[key]
+	c.mu.Unlock()
+	if cached && c.now().Add(refreshBefore).Before(token.ExpiresAt) {
+		return token, nil
+	}
+	if notInstalled && c.now().Before(checked.Add(notInstalledRetry)) {
+		return Token{}, ErrNotInstalled
+	}
+
+	minted, err, _ := c.mints.Do(key, func() (any, error) {
+		return c.mint(ctx, app, key, owner, repo)
+	})
+	if err != nil {
+		return Token{}, err
+	}
+	return minted.(Token), nil
+}
+
+// mint requests a new token of the app's installation on owner/repo and
+// caches it under key. The lock is only held while the caches are accessed.
+func (c *Client) mint(ctx context.Context, app App, key, owner, repo string) (Token, error) {
+	jwt, err := app.JWT(c.now())
+	if err != nil {
+		return Token{}, err
+	}
+	c.mu.Lock()
+	installation, ok := c.installations[key]
+	c.mu.Unlock()
+	if !ok {
+		var body struct {
+			ID int64 `json:"id"`
+		}
+		err = c.do(ctx, http.MethodGet, fmt.Sprintf("%s/repos/%s/%s/installation", app.BaseURL, owner, repo), jwt, nil, &body)
+		if errors.Is(err, ErrNotInstalled) {
+			c.mu.Lock()
+			c.notInstalled[key] = c.now()
+			c.mu.Unlock()
+		}
+		if err != nil {
+			return Token{}, err
+		}
+		installation = body.ID
+		c.mu.Lock()
+		delete(c.notInstalled, key)
+		c.installations[key] = installation
+		c.mu.Unlock()
+	}
+
+	request := map[string]any{
+		"repositories": []string{repo},
+		"permissions":  map[string]string{"contents": "read"},
+	}
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	err = c.do(ctx, http.MethodPost, fmt.Sprintf("%s/app/installations/%d/access_tokens", app.BaseURL, installation), jwt, request, &body)
+	if errors.Is(err, ErrNotInstalled) {
+		// The installation was removed since it was looked up
+		c.mu.Lock()
+		delete(c.installations, key)
+		c.mu.Unlock()
+	}
+	if err != nil {
+		return Token{}, err
+	}
+	token := Token{Value: body.Token, ExpiresAt: body.ExpiresAt}
+	c.mu.Lock()
+	c.tokens[key] = token
+	c.mu.Unlock()
+	return token, nil
+}
+
+// do sends a request authenticated with a bearer token and decodes the JSON
+// response into out, unless out is nil. A 404 is reported as ErrNotInstalled.
+func (c *Client) do(ctx context.Context, method, url, token string, in, out any) error {
+	_, err := c.send(ctx, method, url, token, in, out)
+	return err
+}
+
+// send is do, but also returns the response headers.
+func (c *Client) send(ctx context.Context, method, url, token string, in, out any) (http.Header, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotInstalled
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return resp.Header, nil
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,154 @@
+package githubapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		key    *rsa.PrivateKey
+		app    App
+		server *httptest.Server
+		client *Client
+		now    time.Time
+		minted atomic.Int32
+		// Holds token requests back while set
+		release chan struct{}
+	)
+
+	BeforeEach(func() {
+		var err error
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		now = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		minted.Store(0)
+		release = nil
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /repos/team/app/installation", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprint(w, `{"id":42}`)
+		})
+		mux.HandleFunc("POST /app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Repositories []string `json:"repositories"`
+			}
+			Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+			Expect(body.Repositories).To(Equal([]string{"app"}))
+			if release != nil {
+				<-release
+			}
+			n := minted.Add(1)
+			_, _ = fmt.Fprintf(w, `{"token":"ghs_%d","expires_at":%q}`, n, now.Add(time.Hour).Format(time.RFC3339))
+		})
+		server = httptest.NewServer(mux)
+
+		block := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		app, err = ParseApp("1234", block, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		client = NewClient()
+		client.now = func() time.Time { return now }
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("signs JWTs with the app's key", func() {
+		jwt, err := app.JWT(now)
+		Expect(err).NotTo(HaveOccurred())
+		parts := strings.Split(jwt, ".")
+		Expect(parts).To(HaveLen(3))
+
+		claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(claims)).To(ContainSubstring(`"iss":1234`))
+
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		Expect(err).NotTo(HaveOccurred())
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		Expect(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature)).To(Succeed())
+	})
+
+	It("caches tokens until they are about to expire", func() {
+		token, err := client.RepositoryToken(context.Background(), app, "team", "app")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.Value).To(Equal("ghs_1"))
+
+		now = now.Add(30 * time.Minute)
+		token, err = client.RepositoryToken(context.Background(), app, "team", "app")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.Value).To(Equal("ghs_1"))
+
+		now = now.Add(20 * time.Minute)
+		token, err = client.RepositoryToken(context.Background(), app, "team", "app")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.Value).To(Equal("ghs_2"))
+	})
+
+	It("mints one token for concurrent requests without blocking other repositories", func() {
+		release = make(chan struct{})
+		tokens := make(chan string, 3)
+		for range 3 {
+			go func() {
+				defer GinkgoRecover()
+				token, err := client.RepositoryToken(context.Background(), app, "team", "app")
+				Expect(err).NotTo(HaveOccurred())
+				tokens <- token.Value
+			}()
+		}
+
+		// Answered while the token requests above are still pending
+		_, err := client.RepositoryToken(context.Background(), app, "team", "other")
+		Expect(err).To(MatchError(ErrNotInstalled))
+
+		close(release)
+		for range 3 {
+			Eventually(tokens).Should(Receive(Equal("ghs_1")))
+		}
+		Expect(minted.Load()).To(Equal(int32(1)))
+	})
+
+	It("parses app credentials again only when their version changes", func() {
+		block := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		first, err := client.LoadApp("1", "1234", block, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		again, err := client.LoadApp("1", "1234", block, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(again.Key).To(BeIdenticalTo(first.Key))
+
+		_, err = client.LoadApp("2", "1234", []byte("not a key"), server.URL)
+		Expect(err).To(HaveOccurred())
+		updated, err := client.LoadApp("3", "5678", block, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.ID).To(Equal(int64(5678)))
+	})
+
+	It("reports repositories the app isn't installed on", func() {
+		_, err := client.RepositoryToken(context.Background(), app, "team", "other")
+		Expect(err).To(MatchError(ErrNotInstalled))
+	})
+
+	It("rejects malformed app credentials", func() {
+		_, err := ParseApp("abc", nil, "")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseApp("1234", []byte("not a key"), "")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,14 @@
+package githubapp
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGitHubApp(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "GitHub App Suite")
+}
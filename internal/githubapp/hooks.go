@@ -0,0 +1,67 @@
+package githubapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type repositoryHook struct {
+	ID     int64 `json:"id"`
+	Config struct {
+		URL string `json:"url"`
+	} `json:"config"`
+}
+
+// DeleteHooks deletes the webhooks of owner/repo whose URL matches, using a
+// user token allowed to administer them, such as an OAuth token with the
+// admin:repo_hook scope. It returns how many were deleted. A repository the
+// token can't see has none to delete.
+func (c *Client) DeleteHooks(ctx context.Context, baseURL, token, owner, repo string, match func(url string) bool) (int, error) {
+	// List every page before deleting, deletions would shift the later pages
+	var hooks []repositoryHook
+	page := fmt.Sprintf("%s/repos/%s/%s/hooks?per_page=100", baseURL, owner, repo)
+	for page != "" {
+		var pageHooks []repositoryHook
+		header, err := c.send(ctx, http.MethodGet, page, token, nil, &pageHooks)
+		if errors.Is(err, ErrNotInstalled) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		hooks = append(hooks, pageHooks...)
+		page = nextPage(header.Get("Link"), baseURL)
+	}
+
+	deleted := 0
+	for _, hook := range hooks {
+		if !match(hook.Config.URL) {
+			continue
+		}
+		err := c.do(ctx, http.MethodDelete, fmt.Sprintf("%s/repos/%s/%s/hooks/%d", baseURL, owner, repo, hook.ID), token, nil, nil)
+		if err != nil && !errors.Is(err, ErrNotInstalled) {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// nextPage returns the rel="next" URL of a Link header, empty on the last
+// page. Only URLs below baseURL are followed, so the token stays with the API.
+func nextPage(link, baseURL string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		target = strings.Trim(strings.TrimSpace(target), "<>")
+		if strings.HasPrefix(target, baseURL+"/") {
+			return target
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package githubapp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DeleteHooks", func() {
+	It("deletes the matching hooks of a repository", func() {
+		var deleted []string
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /repos/team/app/hooks", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Authorization")).To(Equal("Bearer gho_user"))
+			_, _ = fmt.Fprint(w, `[{"id":1,"config":{"url":"https://ci.example.com/hook"}},{"id":2,"config":{"url":"https://gitship.example.com/api/webhooks/github?app=team%2Fapp"}}]`)
+		})
+		mux.HandleFunc("DELETE /repos/team/app/hooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+			deleted = append(deleted, r.PathValue("id"))
+			w.WriteHeader(http.StatusNoContent)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		client := NewClient()
+		match := func(url string) bool { return strings.Contains(url, "/api/webhooks/") }
+		n, err := client.DeleteHooks(context.Background(), server.URL, "gho_user", "team", "app", match)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(1))
+		Expect(deleted).To(Equal([]string{"2"}))
+
+		n, err = client.DeleteHooks(context.Background(), server.URL, "gho_user", "team", "gone", match)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(BeZero())
+	})
+
+	It("deletes matching hooks from every page", func() {
+		var deleted []string
+		var server *httptest.Server
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /repos/team/app/hooks", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("page") == "" {
+				w.Header().Set("Link", fmt.Sprintf(`<%s/repositories/1/hooks?per_page=100&page=2>; rel="next", <%[1]s/repositories/1/hooks?per_page=100&page=2>; rel="last"`, server.URL))
+				_, _ = fmt.Fprint(w, `[{"id":1,"config":{"url":"https://ci.example.com/hook"}}]`)
+				return
+			}
+			Fail("unexpected page " + r.URL.RawQuery)
+		})
+		mux.HandleFunc("GET /repositories/1/hooks", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Query().Get("page")).To(Equal("2"))
+			_, _ = fmt.Fprint(w, `[{"id":2,"config":{"url":"https://gitship.example.com/api/webhooks/github?app=team%2Fapp"}}]`)
+		})
+		mux.HandleFunc("DELETE /repos/team/app/hooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+			deleted = append(deleted, r.PathValue("id"))
+			w.WriteHeader(http.StatusNoContent)
+		})
+		server = httptest.NewServer(mux)
+		defer server.Close()
+
+		match := func(url string) bool { return strings.Contains(url, "/api/webhooks/") }
+		n, err := NewClient().DeleteHooks(context.Background(), server.URL, "gho_user", "team", "app", match)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(1))
+		Expect(deleted).To(Equal([]string{"2"}))
+	})
+
+	It("only follows next links to the API", func() {
+		Expect(nextPage(`<https://api.github.com/repositories/1/hooks?page=2>; rel="next"`, "https://api.github.com")).
+			To(Equal("https://api.github.com/repositories/1/hooks?page=2"))
+		Expect(nextPage(`<https://evil.example.com/hooks?page=2>; rel="next"`, "https://api.github.com")).To(BeEmpty())
+		Expect(nextPage(`<https://api.github.com/repositories/1/hooks?page=1>; rel="prev"`, "https://api.github.com")).To(BeEmpty())
+		Expect(nextPage("", "https://api.github.com")).To(BeEmpty())
+	})
+})
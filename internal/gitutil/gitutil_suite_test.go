@@ -0,0 +1,14 @@
+package gitutil
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGitutil(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Gitutil Suite")
+}
@@ -0,0 +1,65 @@
+package gitutil
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchGlob reports whether the slash separated file path matches pattern.
+// Each segment follows path.Match; "**" matches any number of directories and
+// a trailing "/" matches everything below that directory.
+func MatchGlob(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(strings.TrimPrefix(name, "/"), "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// MatchesPathFilter reports whether any of the changed files is selected by
+// the include globs (all files when empty) and not rejected by the exclude globs.
+func MatchesPathFilter(include, exclude, files []string) bool {
+	for _, file := range files {
+		if len(include) > 0 && !matchAny(include, file) {
+			continue
+		}
+		if matchAny(exclude, file) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func matchAny(patterns []string, file string) bool {
+	for _, p := range patterns {
+		if MatchGlob(p, file) {
+			return true
+		}
+	}
+	return false
+}
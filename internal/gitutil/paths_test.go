@@ -0,0 +1,34 @@
+package gitutil
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Path filters", func() {
+	DescribeTable("MatchGlob",
+		func(pattern, name string, expected bool) {
+			Expect(MatchGlob(pattern, name)).To(Equal(expected))
+		},
+		Entry("exact file", "go.mod", "go.mod", true),
+		Entry("single segment wildcard", "services/*/main.go", "services/api/main.go", true),
+		Entry("wildcard does not cross directories", "services/*.go", "services/api/main.go", false),
+		Entry("double star prefix", "services/api/**", "services/api/cmd/main.go", true),
+		Entry("double star in the middle", "**/*.md", "docs/guide/intro.md", true),
+		Entry("double star matches zero directories", "**/*.md", "README.md", true),
+		Entry("trailing slash matches the directory tree", "web/", "web/src/app.tsx", true),
+		Entry("other directory", "services/api/**", "services/worker/main.go", false),
+	)
+
+	It("builds when an included file changed", func() {
+		Expect(MatchesPathFilter([]string{"services/api/**"}, nil, []string{"README.md", "services/api/main.go"})).To(BeTrue())
+	})
+
+	It("skips when only excluded files changed", func() {
+		Expect(MatchesPathFilter(nil, []string{"**/*.md"}, []string{"README.md", "docs/intro.md"})).To(BeFalse())
+	})
+
+	It("applies exclusions inside included directories", func() {
+		Expect(MatchesPathFilter([]string{"services/api/**"}, []string{"**/*_test.go"}, []string{"services/api/main_test.go"})).To(BeFalse())
+	})
+})
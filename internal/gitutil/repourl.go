@@ -0,0 +1,82 @@
+package gitutil
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// RepoURL is a parsed git repository URL.
+type RepoURL struct {
+	// "https", "http", "ssh" or "git"; "ssh" for scp-like URLs
+	Scheme string
+	User   string
+	Host   string
+	// Empty for the scheme's default port
+	Port string
+	// Repository path without leading slash, e.g. "team/app.git"
+	Path string
+}
+
+// ParseRepoURL parses URLs such as https://host[:port]/path,
+// ssh://user@host[:port]/path and the scp-like user@host:path.
+func ParseRepoURL(raw string) (*RepoURL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		// scp-like syntax, the path follows the first colon
+		hostPart, path, ok := strings.Cut(raw, ":")
+		if !ok || hostPart == "" || path == "" || strings.Contains(hostPart, "/") {
+			return nil, fmt.Errorf("invalid repository URL %q", raw)
+		}
+		repo := &RepoURL{Scheme: "ssh", Host: hostPart, Path: strings.TrimPrefix(path, "/")}
+		if user, host, ok := strings.Cut(hostPart, "@"); ok {
+			repo.User, repo.Host = user, host
+		}
+		return repo, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository URL %q: %w", raw, err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid repository URL %q: no host", raw)
+	}
+	repo := &RepoURL{
+		Scheme: u.Scheme,
+		Host:   u.Hostname(),
+		Port:   u.Port(),
+		Path:   strings.TrimPrefix(u.Path, "/"),
+	}
+	if u.User != nil {
+		repo.User = u.User.Username()
+	}
+	return repo, nil
+}
+
+// SSHURL returns the URL to clone the repository over SSH: scp-like on the
+// default port, ssh:// when an SSH URL names a port. Ports of HTTP URLs
+// aren't carried over. The user defaults to "git".
+func (r *RepoURL) SSHURL() string {
+	user := r.User
+	if user == "" || r.Scheme != "ssh" {
+		user = "git"
+	}
+	if r.Scheme == "ssh" && r.Port != "" {
+		return fmt.Sprintf("ssh://%s@%s:%s/%s", user, r.Host, r.Port, r.Path)
+	}
+	return fmt.Sprintf("%s@%s:%s", user, r.Host, r.Path)
+}
+
+// HTTPSURL returns the URL to clone the repository over HTTPS.
+func (r *RepoURL) HTTPSURL() string {
+	host := r.Host
+	if r.Port != "" && (r.Scheme == "https" || r.Scheme == "http") {
+		host += ":" + r.Port
+	}
+	scheme := "https"
+	if r.Scheme == "http" {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/%s", scheme, host, r.Path)
+}
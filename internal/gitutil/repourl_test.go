@@ -0,0 +1,38 @@
+package gitutil
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Repository URLs", func() {
+	DescribeTable("SSHURL",
+		func(raw, expected string) {
+			repo, err := ParseRepoURL(raw)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repo.SSHURL()).To(Equal(expected))
+		},
+		Entry("GitHub", "https://github.com/team/app", "git@github.com:team/app"),
+		Entry("GitLab subgroup", "https://gitlab.com/group/sub/app.git", "git@gitlab.com:group/sub/app.git"),
+		Entry("self-hosted with HTTP port", "https://git.example.com:8443/team/app.git", "git@git.example.com:team/app.git"),
+		Entry("scp-like", "gitea@git.example.com:team/app.git", "gitea@git.example.com:team/app.git"),
+		Entry("ssh with port", "ssh://git@git.example.com:2222/team/app.git", "ssh://git@git.example.com:2222/team/app.git"),
+	)
+
+	It("parses the parts of a URL", func() {
+		repo, err := ParseRepoURL("ssh://deploy@git.example.com:2222/team/app.git")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*repo).To(Equal(RepoURL{Scheme: "ssh", User: "deploy", Host: "git.example.com", Port: "2222", Path: "team/app.git"}))
+
+		repo, err = ParseRepoURL("git@github.com:team/app.git")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(repo.HTTPSURL()).To(Equal("https://github.com/team/app.git"))
+	})
+
+	It("rejects URLs without a host", func() {
+		_, err := ParseRepoURL("team/app")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseRepoURL("file:///srv/app.git")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,217 @@
+package gitutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// IsSemverConstraint reports whether a tag source is a semver constraint
+// (e.g. ">=1.2 <2", "~1.4", "^2") rather than a tag name or pattern.
+func IsSemverConstraint(value string) bool {
+	value = strings.TrimSpace(value)
+	return value != "" && (strings.ContainsAny(value[:1], "~^<>=!") || strings.Contains(value, " "))
+}
+
+// Constraint is a parsed semver constraint: alternatives separated by "||",
+// each a set of comparisons that must all hold.
+type Constraint [][]comparison
+
+type comparison struct {
+	op      string
+	version semver.Version
+}
+
+// ParseConstraint parses a constraint of space separated comparisons
+// (=, !=, <, <=, >, >=), tilde (~1.4: >=1.4.0 <1.5.0) and caret (^1.4:
+// >=1.4.0 <2.0.0) ranges. Versions may be partial (1.4) or wildcards (1.x).
+func ParseConstraint(s string) (Constraint, error) {
+	var constraint Constraint
+	for _, alternative := range strings.Split(s, "||") {
+		var comparisons []comparison
+		fields := strings.Fields(alternative)
+		for i := 0; i < len(fields); i++ {
+			term := fields[i]
+			// Allow a space between the operator and the version, e.g. ">= 1.2"
+			if strings.TrimLeft(term, "~^<>=!") == "" && i+1 < len(fields) {
+				i++
+				term += fields[i]
+			}
+			parsed, err := parseTerm(term)
+			if err != nil {
+				return nil, err
+			}
+			comparisons = append(comparisons, parsed...)
+		}
+		if len(comparisons) == 0 {
+			return nil, fmt.Errorf("empty semver constraint %q", s)
+		}
+		constraint = append(constraint, comparisons)
+	}
+	return constraint, nil
+}
+
+// parseTerm expands one term of a constraint into comparisons of full versions.
+func parseTerm(term string) ([]comparison, error) {
+	op := term[:len(term)-len(strings.TrimLeft(term, "~^<>=!"))]
+	v, parts, err := parsePartial(strings.TrimPrefix(term[len(op):], "v"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid semver constraint %q: %w", term, err)
+	}
+	if parts == 0 {
+		// "*" matches every version
+		return []comparison{{op: ">=", version: semver.Version{}}}, nil
+	}
+
+	// next is the first version above the partial version, e.g. 1.5.0 for 1.4
+	next := v
+	next.Pre = nil
+	switch parts {
+	case 1:
+		next = semver.Version{Major: v.Major + 1}
+	case 2:
+		next = semver.Version{Major: v.Major, Minor: v.Minor + 1}
+	default:
+		next.Patch++
+	}
+
+	switch op {
+	case "", "=":
+		if parts == 3 {
+			return []comparison{{op: "=", version: v}}, nil
+		}
+		return []comparison{{op: ">=", version: v}, {op: "<", version: next}}, nil
+	case "!=":
+		return []comparison{{op: "!=", version: v}}, nil
+	case ">=", "<":
+		return []comparison{{op: op, version: v}}, nil
+	case ">":
+		if parts == 3 {
+			return []comparison{{op: ">", version: v}}, nil
+		}
+		return []comparison{{op: ">=", version: next}}, nil
+	case "<=":
+		if parts == 3 {
+			return []comparison{{op: "<=", version: v}}, nil
+		}
+		return []comparison{{op: "<", version: next}}, nil
+	case "~":
+		upper := semver.Version{Major: v.Major, Minor: v.Minor + 1}
+		if parts == 1 {
+			upper = semver.Version{Major: v.Major + 1}
+		}
+		return []comparison{{op: ">=", version: v}, {op: "<", version: upper}}, nil
+	case "^":
+		upper := semver.Version{Major: v.Major + 1}
+		if v.Major == 0 && parts > 1 {
+			upper = semver.Version{Minor: v.Minor + 1}
+			if v.Minor == 0 && parts == 3 {
+				upper = semver.Version{Patch: v.Patch + 1}
+			}
+		}
+		return []comparison{{op: ">=", version: v}, {op: "<", version: upper}}, nil
+	}
+	return nil, fmt.Errorf("invalid semver constraint %q: unknown operator %q", term, op)
+}
+
+// parsePartial parses a possibly partial version, returning it padded with
+// zeros and the number of parts given before any wildcard.
+func parsePartial(s string) (semver.Version, int, error) {
+	if s == "" || s == "*" || s == "x" || s == "X" {
+		return semver.Version{}, 0, nil
+	}
+	core, pre, _ := strings.Cut(s, "-")
+	numbers := strings.Split(core, ".")
+	if len(numbers) > 3 {
+		return semver.Version{}, 0, fmt.Errorf("too many version parts")
+	}
+	var parsed [3]uint64
+	parts := 0
+	for _, n := range numbers {
+		if n == "*" || n == "x" || n == "X" {
+			break
+		}
+		value, err := strconv.ParseUint(n, 10, 64)
+		if err != nil {
+			return semver.Version{}, 0, err
+		}
+		parsed[parts] = value
+		parts++
+	}
+	v := semver.Version{Major: parsed[0], Minor: parsed[1], Patch: parsed[2]}
+	if pre != "" {
+		if parts < 3 {
+			return semver.Version{}, 0, fmt.Errorf("prerelease of a partial version")
+		}
+		full, err := semver.Parse(s)
+		if err != nil {
+			return semver.Version{}, 0, err
+		}
+		v = full
+	}
+	return v, parts, nil
+}
+
+// Check reports whether v satisfies the constraint. Prereleases only match
+// constraints that name a prerelease themselves.
+func (c Constraint) Check(v semver.Version) bool {
+	for _, comparisons := range c {
+		if checkAll(comparisons, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkAll(comparisons []comparison, v semver.Version) bool {
+	allowPre := len(v.Pre) == 0
+	for _, c := range comparisons {
+		cmp := v.Compare(c.version)
+		var ok bool
+		switch c.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		}
+		if !ok {
+			return false
+		}
+		if len(c.version.Pre) > 0 {
+			allowPre = true
+		}
+	}
+	return allowPre
+}
+
+// HighestSemverTag returns the tag with the highest version satisfying the
+// constraint, "" if none does. Tags may carry a "v" prefix; tags that aren't
+// versions are ignored.
+func HighestSemverTag(constraint string, tags []string) (string, error) {
+	c, err := ParseConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+	highest := ""
+	var highestVersion semver.Version
+	for _, tag := range tags {
+		v, err := semver.Parse(strings.TrimPrefix(tag, "v"))
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if highest == "" || v.GT(highestVersion) {
+			highest, highestVersion = tag, v
+		}
+	}
+	return highest, nil
+}
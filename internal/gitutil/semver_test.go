@@ -0,0 +1,45 @@
+package gitutil
+
+import (
+	"github.com/blang/semver/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Semver", func() {
+	DescribeTable("Constraint",
+		func(constraint, version string, expected bool) {
+			c, err := ParseConstraint(constraint)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Check(semver.MustParse(version))).To(Equal(expected))
+		},
+		Entry("range", ">=1.2 <2", "1.9.3", true),
+		Entry("range upper bound", ">=1.2 <2", "2.0.0", false),
+		Entry("space after operator", ">= 1.2 < 2", "1.2.0", true),
+		Entry("tilde", "~1.4", "1.4.7", true),
+		Entry("tilde next minor", "~1.4", "1.5.0", false),
+		Entry("caret", "^1.4", "1.9.0", true),
+		Entry("caret of 0.x", "^0.3", "0.4.0", false),
+		Entry("greater than a partial version", ">1.2", "1.2.9", false),
+		Entry("alternatives", "~1.4 || ^3", "3.1.0", true),
+		Entry("wildcard", "1.x", "1.8.0", true),
+		Entry("prerelease excluded", ">=1.2", "1.3.0-rc.1", false),
+		Entry("prerelease named by the constraint", ">=1.3.0-rc.0", "1.3.0-rc.1", true),
+	)
+
+	It("tells constraints from tag names and patterns", func() {
+		Expect(IsSemverConstraint(">=1.2 <2")).To(BeTrue())
+		Expect(IsSemverConstraint("~1.4")).To(BeTrue())
+		Expect(IsSemverConstraint("v1.4.0")).To(BeFalse())
+		Expect(IsSemverConstraint("v*")).To(BeFalse())
+	})
+
+	It("selects the highest matching tag", func() {
+		tags := []string{"v1.2.0", "v1.10.1", "v2.0.0", "1.11.0-rc.1", "latest"}
+		Expect(HighestSemverTag(">=1.2 <2", tags)).To(Equal("v1.10.1"))
+		Expect(HighestSemverTag("^3", tags)).To(BeEmpty())
+
+		_, err := ParseConstraint(">=one")
+		Expect(err).To(HaveOccurred())
+	})
+})
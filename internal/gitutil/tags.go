@@ -0,0 +1,73 @@
+package gitutil
+
+import (
+	"path"
+	"strings"
+	"unicode"
+)
+
+// IsTagPattern reports whether a tag source is a glob (e.g. "v*") rather than a single tag.
+func IsTagPattern(value string) bool {
+	return strings.ContainsAny(value, "*?[")
+}
+
+// MatchTag reports whether tag is the tag source value, matches its pattern
+// or satisfies its semver constraint.
+func MatchTag(value, tag string) bool {
+	if IsSemverConstraint(value) {
+		highest, err := HighestSemverTag(value, []string{tag})
+		return err == nil && highest == tag
+	}
+	if !IsTagPattern(value) {
+		return value == tag
+	}
+	ok, err := path.Match(value, tag)
+	return err == nil && ok
+}
+
+// LatestTag returns the highest of tags matching pattern, comparing runs of
+// digits numerically so that v1.10 sorts after v1.9. It returns "" if none match.
+func LatestTag(pattern string, tags []string) string {
+	latest := ""
+	for _, tag := range tags {
+		if MatchTag(pattern, tag) && (latest == "" || compareNatural(tag, latest) > 0) {
+			latest = tag
+		}
+	}
+	return latest
+}
+
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			na, restA := digitRun(a)
+			nb, restB := digitRun(b)
+			na, nb = strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if len(na) != len(nb) {
+				return len(na) - len(nb)
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			a, b = restA, restB
+			continue
+		}
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+func digitRun(s string) (string, string) {
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) })
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
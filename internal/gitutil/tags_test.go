@@ -0,0 +1,27 @@
+package gitutil
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tags", func() {
+	DescribeTable("MatchTag",
+		func(value, tag string, expected bool) {
+			Expect(MatchTag(value, tag)).To(Equal(expected))
+		},
+		Entry("exact tag", "v1.2.0", "v1.2.0", true),
+		Entry("other tag", "v1.2.0", "v1.2.1", false),
+		Entry("pattern", "v1.*", "v1.2.1", true),
+		Entry("pattern of another prefix", "v*", "release-1", false),
+		Entry("semver constraint", "~1.4", "v1.4.2", true),
+		Entry("semver constraint not satisfied", "~1.4", "v1.5.0", false),
+	)
+
+	It("picks the highest matching tag", func() {
+		tags := []string{"v1.9.0", "v1.10.0", "v2.0.0-rc1", "release-3"}
+		Expect(LatestTag("v1.*", tags)).To(Equal("v1.10.0"))
+		Expect(LatestTag("v*", tags)).To(Equal("v2.0.0-rc1"))
+		Expect(LatestTag("nightly-*", tags)).To(BeEmpty())
+	})
+})
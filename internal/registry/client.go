@@ -0,0 +1,338 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a manifest or repository doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Credentials for a registry host.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Client is a minimal client for the OCI distribution API.
+type Client struct {
+	HTTP *http.Client
+	// Credentials by registry host
+	Auths map[string]Credentials
+	// Scheme overrides the scheme picked for every registry, used in tests
+	Scheme string
+}
+
+// NewClient returns a Client using the given credentials.
+func NewClient(auths map[string]Credentials) *Client {
+	return &Client{
+		HTTP:  &http.Client{Timeout: 15 * time.Second},
+		Auths: auths,
+	}
+}
+
+// ParseDockerConfig reads the credentials of a kubernetes.io/dockerconfigjson Secret.
+func ParseDockerConfig(data []byte) (map[string]Credentials, error) {
+	var config struct {
+		Auths map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Auth     string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	auths := make(map[string]Credentials, len(config.Auths))
+	for host, entry := range config.Auths {
+		creds := Credentials{Username: entry.Username, Password: entry.Password}
+		if creds.Username == "" && entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err == nil {
+				creds.Username, creds.Password, _ = strings.Cut(string(decoded), ":")
+			}
+		}
+		host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+		host = strings.TrimSuffix(strings.SplitN(host, "/", 2)[0], "/")
+		if host == "index.docker.io" || host == "docker.io" {
+			host = dockerHub
+		}
+		auths[host] = creds
+	}
+	return auths, nil
+}
+
+// ManifestDigest returns the digest of the manifest ref points to, or
+// ErrNotFound if it doesn't exist.
+func (c *Client) ManifestDigest(ctx context.Context, ref Reference) (string, error) {
+	resp, err := c.do(ctx, http.MethodHead, ref, "/manifests/"+ref.Identifier(), "pull")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp, http.StatusOK); err != nil {
+		return "", err
+	}
+	return resp.Header.Get("Docker-Content-Digest"), nil
+}
+
+// Image is a manifest of a repository.
+type Image struct {
+	Digest string
+	// When the image was built, zero for indexes and images without a date
+	Created time.Time
+}
+
+// Image returns the digest and creation time of the manifest ref points to,
+// or ErrNotFound if it doesn't exist.
+func (c *Client) Image(ctx context.Context, ref Reference) (Image, error) {
+	resp, err := c.do(ctx, http.MethodGet, ref, "/manifests/"+ref.Identifier(), "pull")
+	if err != nil {
+		return Image{}, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp, http.StatusOK); err != nil {
+		return Image{}, err
+	}
+	var manifest struct {
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return Image{}, err
+	}
+	image := Image{Digest: resp.Header.Get("Docker-Content-Digest")}
+	if manifest.Config.Digest == "" {
+		return image, nil
+	}
+
+	config, err := c.do(ctx, http.MethodGet, ref, "/blobs/"+manifest.Config.Digest, "pull")
+	if err != nil {
+		return Image{}, err
+	}
+	defer config.Body.Close()
+	if err := checkStatus(config, http.StatusOK); err != nil {
+		return Image{}, err
+	}
+	var body struct {
+		Created time.Time `json:"created"`
+	}
+	if err := json.NewDecoder(config.Body).Decode(&body); err != nil {
+		return Image{}, err
+	}
+	image.Created = body.Created
+	return image, nil
+}
+
+// DeleteManifest deletes the manifest with digest from ref's repository,
+// untagging every tag pointing to it. The registry must allow deletes. Space
+// is only reclaimed once the registry garbage collects the blobs.
+func (c *Client) DeleteManifest(ctx context.Context, ref Reference, digest string) error {
+	resp, err := c.do(ctx, http.MethodDelete, ref, "/manifests/"+digest, "delete")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		return fmt.Errorf("registry %s does not allow deletes", ref.Registry)
+	}
+	return checkStatus(resp, http.StatusAccepted, http.StatusOK)
+}
+
+// maxTagPages bounds how many pages of a tag list are read.
+const maxTagPages = 50
+
+// Tags lists the tags of ref's repository, or returns ErrNotFound if it
+// doesn't exist.
+func (c *Client) Tags(ctx context.Context, ref Reference) ([]string, error) {
+	var tags []string
+	path := "/tags/list"
+	for page := 0; path != "" && page < maxTagPages; page++ {
+		resp, err := c.do(ctx, http.MethodGet, ref, path, "pull")
+		if err != nil {
+			return nil, err
+		}
+		if err := checkStatus(resp, http.StatusOK); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, body.Tags...)
+		path = nextTagsPage(resp.Header.Get("Link"))
+	}
+	return tags, nil
+}
+
+// nextTagsPage returns the path of the next page of a tag list, relative to
+// the repository, from a Link header such as
+// </v2/team/app/tags/list?last=v1&n=100>; rel="next".
+func nextTagsPage(link string) string {
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start == -1 || end < start || !strings.Contains(link[end:], `rel="next"`) {
+		return ""
+	}
+	target := link[start+1 : end]
+	i := strings.Index(target, "/tags/list")
+	if i == -1 {
+		return ""
+	}
+	return target[i:]
+}
+
+// do sends a request to the repository of ref, authenticating with basic or
+// bearer auth when the registry asks for it.
+func (c *Client) do(ctx context.Context, method string, ref Reference, path, scope string) (*http.Response, error) {
+	u := fmt.Sprintf("%s://%s/v2/%s%s", c.scheme(ref.Registry), ref.Registry, ref.Repository, path)
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	req, err = newRequest()
+	if err != nil {
+		return nil, err
+	}
+	creds, hasCreds := c.Auths[ref.Registry]
+	switch {
+	case strings.HasPrefix(strings.ToLower(challenge), "bearer "):
+		token, err := c.token(ctx, challenge, creds, hasCreds, fmt.Sprintf("repository:%s:%s", ref.Repository, scope))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case hasCreds:
+		req.SetBasicAuth(creds.Username, creds.Password)
+	default:
+		return nil, fmt.Errorf("registry %s requires authentication", ref.Registry)
+	}
+	return c.HTTP.Do(req)
+}
+
+// token fetches a bearer token from the realm named in the challenge.
+func (c *Client) token(ctx context.Context, challenge string, creds Credentials, hasCreds bool, scope string) (string, error) {
+	params := parseChallenge(challenge[len("bearer "):])
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid auth challenge %q", challenge)
+	}
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if hasCreds {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp, http.StatusOK); err != nil {
+		return "", fmt.Errorf("token request: %w", err)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// parseChallenge parses the comma separated key="value" pairs of a
+// WWW-Authenticate header.
+func parseChallenge(s string) map[string]string {
+	params := map[string]string{}
+	for s != "" {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end == -1 {
+				value, s = rest[1:], ""
+			} else {
+				value, s = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+		}
+		params[key] = value
+		s = strings.TrimLeft(s, ", ")
+	}
+	return params
+}
+
+func checkStatus(resp *http.Response, expected ...int) error {
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+func (c *Client) scheme(host string) string {
+	if c.Scheme != "" {
+		return c.Scheme
+	}
+	return scheme(host)
+}
@@ -0,0 +1,180 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// newTestRegistry serves a single manifest behind bearer auth.
+func newTestRegistry() *httptest.Server {
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "bot" || pass != "secret" || r.URL.Query().Get("scope") != "repository:team/app:pull" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"token":"t0ken"}`)
+	})
+	mux.HandleFunc("/v2/team/app/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasSuffix(r.URL.Path, "/v1") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", "sha256:abc")
+	})
+	mux.HandleFunc("/v2/team/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Two pages
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/team/app/tags/list?last=v1&n=1>; rel="next"`)
+			_, _ = fmt.Fprint(w, `{"name":"team/app","tags":["v1"]}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"name":"team/app","tags":["v2"]}`)
+	})
+	server = httptest.NewServer(mux)
+	return server
+}
+
+var _ = Describe("Client", func() {
+	var (
+		server *httptest.Server
+		client *Client
+		host   string
+	)
+
+	BeforeEach(func() {
+		server = newTestRegistry()
+		host = strings.TrimPrefix(server.URL, "http://")
+		client = NewClient(map[string]Credentials{host: {Username: "bot", Password: "secret"}})
+		client.Scheme = "http"
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("resolves a manifest digest with bearer auth", func() {
+		digest, err := client.ManifestDigest(context.Background(), Reference{Registry: host, Repository: "team/app", Tag: "v1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(digest).To(Equal("sha256:abc"))
+	})
+
+	It("reports missing manifests", func() {
+		_, err := client.ManifestDigest(context.Background(), Reference{Registry: host, Repository: "team/app", Tag: "v2"})
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+
+	It("lists tags across pages", func() {
+		tags, err := client.Tags(context.Background(), Reference{Registry: host, Repository: "team/app"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tags).To(Equal([]string{"v1", "v2"}))
+	})
+
+	It("reports missing repositories", func() {
+		_, err := client.Tags(context.Background(), Reference{Registry: host, Repository: "team/other"})
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+})
+
+// newImageRegistry serves manifest v1 of team/app, built at created, and
+// accepts deletes of it.
+func newImageRegistry(created string, deleted *[]string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/team/app/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		ident := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		switch {
+		case r.Method == http.MethodDelete && ident == "sha256:abc":
+			*deleted = append(*deleted, ident)
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodGet && ident == "v1":
+			w.Header().Set("Docker-Content-Digest", "sha256:abc")
+			_, _ = fmt.Fprint(w, `{"schemaVersion":2,"config":{"digest":"sha256:cfg"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("/v2/team/app/blobs/sha256:cfg", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"created":%q}`, created)
+	})
+	return httptest.NewServer(mux)
+}
+
+var _ = Describe("Image management", func() {
+	var (
+		server  *httptest.Server
+		client  *Client
+		host    string
+		deleted []string
+	)
+
+	BeforeEach(func() {
+		deleted = nil
+		server = newImageRegistry("2026-01-02T03:04:05Z", &deleted)
+		host = strings.TrimPrefix(server.URL, "http://")
+		client = NewClient(nil)
+		client.Scheme = "http"
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("reads the digest and creation time of an image", func() {
+		image, err := client.Image(context.Background(), Reference{Registry: host, Repository: "team/app", Tag: "v1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(image.Digest).To(Equal("sha256:abc"))
+		Expect(image.Created).To(Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+	})
+
+	It("deletes manifests by digest", func() {
+		ref := Reference{Registry: host, Repository: "team/app"}
+		Expect(client.DeleteManifest(context.Background(), ref, "sha256:abc")).To(Succeed())
+		Expect(deleted).To(Equal([]string{"sha256:abc"}))
+		Expect(client.DeleteManifest(context.Background(), ref, "sha256:def")).To(MatchError(ErrNotFound))
+	})
+})
+
+var _ = Describe("References", func() {
+	It("parses registry, repository, tag and digest", func() {
+		ref, err := ParseReference("gitship-registry.gitship-system.svc.cluster.local:5000/team/app:abc123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref.Registry).To(Equal("gitship-registry.gitship-system.svc.cluster.local:5000"))
+		Expect(ref.Repository).To(Equal("team/app"))
+		Expect(ref.Tag).To(Equal("abc123"))
+		Expect(scheme(ref.Registry)).To(Equal("http"))
+
+		ref, err = ParseReference("nginx@sha256:def")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref.Registry).To(Equal(dockerHub))
+		Expect(ref.Repository).To(Equal("library/nginx"))
+		Expect(ref.Identifier()).To(Equal("sha256:def"))
+	})
+
+	It("reads dockerconfigjson credentials", func() {
+		auth := base64.StdEncoding.EncodeToString([]byte("bot:secret"))
+		auths, err := ParseDockerConfig([]byte(`{"auths":{"https://index.docker.io/v1/":{"auth":"` + auth + `"},"ghcr.io":{"username":"u","password":"p"}}}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(auths[dockerHub]).To(Equal(Credentials{Username: "bot", Password: "secret"}))
+		Expect(auths["ghcr.io"]).To(Equal(Credentials{Username: "u", Password: "p"}))
+	})
+})
@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+const dockerHub = "registry-1.docker.io"
+
+// Reference identifies an image in a registry.
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference splits an image reference such as
+// "registry.example.com:5000/team/app:v1" or "nginx@sha256:...". Images without
+// a registry host refer to Docker Hub.
+func ParseReference(image string) (Reference, error) {
+	var ref Reference
+	name := image
+	if i := strings.Index(name, "@"); i != -1 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i != -1 && !strings.Contains(name[i+1:], "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+	}
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Registry = parts[0]
+		ref.Repository = parts[1]
+	} else {
+		ref.Registry = dockerHub
+		ref.Repository = name
+		if !strings.Contains(name, "/") {
+			ref.Repository = "library/" + name
+		}
+	}
+	if ref.Repository == "" {
+		return ref, fmt.Errorf("invalid image reference %q", image)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// Identifier returns the digest if set, otherwise the tag.
+func (r Reference) Identifier() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// scheme returns "http" for registries that are only reachable inside the
+// cluster or on the local machine, as Kaniko does when pushing.
+func scheme(host string) string {
+	hostname := host
+	if i := strings.LastIndex(hostname, ":"); i != -1 {
+		hostname = hostname[:i]
+	}
+	if hostname == "localhost" || strings.HasPrefix(hostname, "127.") || strings.HasSuffix(hostname, ".local") {
+		return "http"
+	}
+	return "https"
+}
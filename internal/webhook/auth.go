@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+// webhookSecretKey is the key of GitshipApp.Spec.WebhookSecretRef holding the secret.
+const webhookSecretKey = "secret"
+
+// allowUnsignedEnv accepts deliveries for apps without any webhook secret when set to "true".
+const allowUnsignedEnv = "WEBHOOK_ALLOW_UNSIGNED"
+
+var errNoSecret = errors.New("no webhook secret configured")
+
+// delivery is a webhook request being processed.
+type delivery struct {
+	provider Provider
+	req      *http.Request
+	payload  []byte
+
+	// Apps, as namespace/name, the delivery triggered or skipped because of their path filters
+	triggered []string
+	skipped   []string
+	rejected  []RejectedApp
+}
+
+func (d *delivery) reject(app *gitshipiov1alpha1.GitshipApp, err error) {
+	log.Log.Info("Rejected webhook delivery", "Name", app.Name, "reason", err.Error())
+	d.rejected = append(d.rejected, RejectedApp{App: appKey(app), Reason: err.Error()})
+}
+
+// addressedTo reports whether the delivery may trigger the app. Webhooks the
+// dashboard registers name their app in the "app" query parameter and are only
+// authenticated against and delivered to that app. Others deliver to every app
+// of the repository.
+func (d *delivery) addressedTo(app *gitshipiov1alpha1.GitshipApp) bool {
+	target := d.req.URL.Query().Get("app")
+	return target == "" || target == appKey(app)
+}
+
+func appKey(app *gitshipiov1alpha1.GitshipApp) string {
+	return app.Namespace + "/" + app.Name
+}
+
+// authenticate checks that the delivery was sent with the app's webhook
+// secret, or the provider's global secret for apps without one. Deliveries
+// for apps without any secret are rejected unless unsigned deliveries are
+// allowed.
+func (r *Receiver) authenticate(ctx context.Context, d *delivery, app *gitshipiov1alpha1.GitshipApp) error {
+	secret, err := r.webhookSecret(ctx, d.provider, app)
+	if err != nil {
+		return err
+	}
+	if secret == nil {
+		if os.Getenv(allowUnsignedEnv) == "true" {
+			return nil
+		}
+		return errNoSecret
+	}
+	return d.provider.verify(d.req, d.payload, secret)
+}
+
+// webhookSecret returns the secret verifying deliveries for app, nil if there is none.
+func (r *Receiver) webhookSecret(ctx context.Context, provider Provider, app *gitshipiov1alpha1.GitshipApp) ([]byte, error) {
+	if app.Spec.WebhookSecretRef == "" {
+		if secret := os.Getenv(provider.secretEnv()); secret != "" {
+			return []byte(secret), nil
+		}
+		return nil, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: app.Spec.WebhookSecretRef, Namespace: app.Namespace}, secret); err != nil {
+		return nil, fmt.Errorf("reading webhook secret: %w", err)
+	}
+	value := secret.Data[webhookSecretKey]
+	if len(value) == 0 {
+		return nil, fmt.Errorf("secret %s has no %q key", app.Spec.WebhookSecretRef, webhookSecretKey)
+	}
+	return value, nil
+}
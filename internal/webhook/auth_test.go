@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+var _ = Describe("Authentication", func() {
+	payload := []byte(`{"ref":"refs/heads/main"}`)
+	var r *Receiver
+	var app *gitshipiov1alpha1.GitshipApp
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		secret := &corev1.Secret{Data: map[string][]byte{webhookSecretKey: []byte("s3cret")}}
+		secret.Name, secret.Namespace = "web-webhook", "u-1"
+		r = &Receiver{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()}
+
+		app = &gitshipiov1alpha1.GitshipApp{}
+		app.Name, app.Namespace = "web", "u-1"
+	})
+
+	delivery := func(signature string) *delivery {
+		req := httptest.NewRequest("POST", "/api/webhooks/github", nil)
+		if signature != "" {
+			req.Header.Set("X-Hub-Signature-256", signature)
+		}
+		return &delivery{provider: ProviderGitHub, req: req, payload: payload}
+	}
+
+	It("verifies deliveries with the app's secret", func() {
+		app.Spec.WebhookSecretRef = "web-webhook"
+		signature := "sha256=" + hmacHex(payload, []byte("s3cret"))
+		Expect(r.authenticate(context.Background(), delivery(signature), app)).To(Succeed())
+		Expect(r.authenticate(context.Background(), delivery("sha256="+hmacHex(payload, []byte("other"))), app)).
+			To(MatchError(errInvalidSignature))
+		Expect(r.authenticate(context.Background(), delivery(""), app)).To(MatchError(errMissingSignature))
+	})
+
+	It("rejects deliveries for apps without a secret unless unsigned deliveries are allowed", func() {
+		Expect(r.authenticate(context.Background(), delivery(""), app)).To(MatchError(errNoSecret))
+
+		Expect(os.Setenv(allowUnsignedEnv, "true")).To(Succeed())
+		DeferCleanup(os.Unsetenv, allowUnsignedEnv)
+		Expect(r.authenticate(context.Background(), delivery(""), app)).To(Succeed())
+	})
+})
@@ -0,0 +1,191 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeliveryRecord is the outcome of a webhook delivery.
+type DeliveryRecord struct {
+	// ID the provider assigned to the delivery, empty if it doesn't send one
+	ID         string    `json:"id"`
+	Provider   Provider  `json:"provider"`
+	Event      string    `json:"event"`
+	ReceivedAt time.Time `json:"receivedAt"`
+	StatusCode int       `json:"statusCode"`
+	Message    string    `json:"message,omitempty"`
+	// Apps, as namespace/name, the delivery triggered
+	Triggered []string `json:"triggered,omitempty"`
+	// Apps skipped because none of their path filters matched
+	Skipped []string `json:"skipped,omitempty"`
+	// Apps that rejected the delivery's signature
+	Rejected []RejectedApp `json:"rejected,omitempty"`
+	// Whether the delivery was rejected as a redelivery of an already processed one
+	Duplicate bool `json:"duplicate,omitempty"`
+}
+
+// RejectedApp is an app that rejected a delivery.
+type RejectedApp struct {
+	App    string `json:"app"`
+	Reason string `json:"reason"`
+}
+
+// DeliveryLog keeps the most recent webhook deliveries and the IDs of the
+// deliveries processed within the replay window.
+type DeliveryLog struct {
+	mu      sync.Mutex
+	size    int
+	window  time.Duration
+	records []DeliveryRecord
+	// Delivery IDs by the time they were processed
+	seen     map[string]time.Time
+	inFlight map[string]bool
+	now      func() time.Time
+}
+
+// NewDeliveryLog returns a log keeping the last size deliveries that rejects
+// deliveries whose ID was already processed within window.
+func NewDeliveryLog(size int, window time.Duration) *DeliveryLog {
+	return &DeliveryLog{
+		size:     size,
+		window:   window,
+		seen:     map[string]time.Time{},
+		inFlight: map[string]bool{},
+		now:      time.Now,
+	}
+}
+
+// begin reports whether a delivery should be processed, false if its ID is
+// being processed or was processed within the window.
+func (l *DeliveryLog) begin(id string) bool {
+	if id == "" {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for seenID, at := range l.seen {
+		if now.Sub(at) > l.window {
+			delete(l.seen, seenID)
+		}
+	}
+	if _, ok := l.seen[id]; ok || l.inFlight[id] {
+		return false
+	}
+	l.inFlight[id] = true
+	return true
+}
+
+// finish records a delivery. Its ID is only remembered as processed when
+// processed is true, so deliveries that failed can be redelivered.
+func (l *DeliveryLog) finish(record DeliveryRecord, processed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if record.ID != "" && !record.Duplicate {
+		delete(l.inFlight, record.ID)
+		if processed {
+			l.seen[record.ID] = l.now()
+		}
+	}
+	l.records = append(l.records, record)
+	if len(l.records) > l.size {
+		l.records = slices.Delete(l.records, 0, len(l.records)-l.size)
+	}
+}
+
+// Records returns up to limit deliveries, newest first, that concern app
+// (namespace/name), leaving out the other apps they concern. All deliveries
+// are returned if app is empty.
+func (l *DeliveryLog) Records(app string, limit int) []DeliveryRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records := []DeliveryRecord{}
+	for i := len(l.records) - 1; i >= 0 && (limit <= 0 || len(records) < limit); i-- {
+		switch {
+		case app == "":
+			records = append(records, l.records[i])
+		case l.records[i].concerns(app):
+			records = append(records, l.records[i].scopedTo(app))
+		}
+	}
+	return records
+}
+
+func (r *DeliveryRecord) concerns(app string) bool {
+	if slices.Contains(r.Triggered, app) || slices.Contains(r.Skipped, app) {
+		return true
+	}
+	return slices.ContainsFunc(r.Rejected, func(rejected RejectedApp) bool {
+		return rejected.App == app
+	})
+}
+
+// scopedTo returns a copy of the record that only lists app, so that one
+// tenant's deliveries don't reveal the apps of another.
+func (r DeliveryRecord) scopedTo(app string) DeliveryRecord {
+	scoped := r
+	scoped.Triggered, scoped.Skipped, scoped.Rejected = nil, nil, nil
+	if slices.Contains(r.Triggered, app) {
+		scoped.Triggered = []string{app}
+	}
+	if slices.Contains(r.Skipped, app) {
+		scoped.Skipped = []string{app}
+	}
+	for _, rejected := range r.Rejected {
+		if rejected.App == app {
+			scoped.Rejected = append(scoped.Rejected, rejected)
+		}
+	}
+	return scoped
+}
+
+// ServeHTTP lists the recorded deliveries of the app query parameter
+// (namespace/name), up to the limit query parameter.
+func (l *DeliveryLog) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	app := req.URL.Query().Get("app")
+	if namespace, name, ok := strings.Cut(app, "/"); !ok || namespace == "" || name == "" {
+		http.Error(w, "The app query parameter must be <namespace>/<name>", http.StatusBadRequest)
+		return
+	}
+	limit := 0
+	if raw := req.URL.Query().Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(l.Records(app, limit))
+}
+
+// responseRecorder captures the status and message a delivery was answered with.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,120 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+var _ = Describe("Deliveries", func() {
+	var deliveries *DeliveryLog
+	var now time.Time
+
+	BeforeEach(func() {
+		now = time.Now()
+		deliveries = NewDeliveryLog(3, time.Hour)
+		deliveries.now = func() time.Time { return now }
+	})
+
+	It("rejects processed delivery IDs within the window", func() {
+		Expect(deliveries.begin("a")).To(BeTrue())
+		Expect(deliveries.begin("a")).To(BeFalse(), "in flight")
+		deliveries.finish(DeliveryRecord{ID: "a"}, true)
+		Expect(deliveries.begin("a")).To(BeFalse())
+
+		now = now.Add(2 * time.Hour)
+		Expect(deliveries.begin("a")).To(BeTrue())
+	})
+
+	It("accepts redeliveries of deliveries that weren't processed", func() {
+		Expect(deliveries.begin("a")).To(BeTrue())
+		deliveries.finish(DeliveryRecord{ID: "a", StatusCode: http.StatusUnauthorized}, false)
+		Expect(deliveries.begin("a")).To(BeTrue())
+		Expect(deliveries.begin("")).To(BeTrue())
+		Expect(deliveries.begin("")).To(BeTrue())
+	})
+
+	It("keeps the most recent deliveries filtered by app", func() {
+		for _, id := range []string{"1", "2", "3", "4"} {
+			deliveries.finish(DeliveryRecord{ID: id, Triggered: []string{"u-1/web"}}, true)
+		}
+		deliveries.finish(DeliveryRecord{ID: "5", Rejected: []RejectedApp{{App: "u-1/api", Reason: "invalid signature"}}}, false)
+
+		ids := func(records []DeliveryRecord) []string {
+			var ids []string
+			for _, r := range records {
+				ids = append(ids, r.ID)
+			}
+			return ids
+		}
+		Expect(ids(deliveries.Records("", 0))).To(Equal([]string{"5", "4", "3"}))
+		Expect(ids(deliveries.Records("u-1/web", 1))).To(Equal([]string{"4"}))
+		Expect(ids(deliveries.Records("u-1/api", 0))).To(Equal([]string{"5"}))
+	})
+
+	It("only serves the deliveries of the requested app", func() {
+		deliveries.finish(DeliveryRecord{ID: "1", Triggered: []string{"u-1/web", "u-2/site"}}, true)
+		deliveries.finish(DeliveryRecord{ID: "2", Triggered: []string{"u-2/site"}}, true)
+
+		get := func(query string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			deliveries.ServeHTTP(w, httptest.NewRequest("GET", "/api/webhooks/deliveries"+query, nil))
+			return w
+		}
+		Expect(get("").Code).To(Equal(http.StatusBadRequest))
+		Expect(get("?app=u-1").Code).To(Equal(http.StatusBadRequest))
+
+		w := get("?app=u-1/web")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		var records []DeliveryRecord
+		Expect(json.Unmarshal(w.Body.Bytes(), &records)).To(Succeed())
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].ID).To(Equal("1"))
+		Expect(records[0].Triggered).To(Equal([]string{"u-1/web"}))
+	})
+
+	It("records the outcome of deliveries and answers duplicates with a conflict", func() {
+		scheme := runtime.NewScheme()
+		Expect(gitshipiov1alpha1.AddToScheme(scheme)).To(Succeed())
+		app := &gitshipiov1alpha1.GitshipApp{}
+		app.Name, app.Namespace = "web", "u-1"
+		app.Spec.RepoURL = "https://github.com/o/r"
+		app.Spec.Source = gitshipiov1alpha1.SourceConfig{Type: "branch", Value: "main"}
+		r := &Receiver{
+			Client:     fake.NewClientBuilder().WithScheme(scheme).WithObjects(app).Build(),
+			Deliveries: deliveries,
+		}
+
+		deliver := func() int {
+			req := httptest.NewRequest("POST", "/api/webhooks/github",
+				strings.NewReader(`{"ref":"refs/heads/main","repository":{"clone_url":"https://github.com/o/r.git"}}`))
+			req.Header.Set("X-GitHub-Event", "push")
+			req.Header.Set("X-GitHub-Delivery", "d-1")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+		Expect(deliver()).To(Equal(http.StatusUnauthorized))
+		Expect(deliveries.Records("u-1/web", 0)[0].Rejected).To(Equal([]RejectedApp{{App: "u-1/web", Reason: errNoSecret.Error()}}))
+
+		Expect(os.Setenv(allowUnsignedEnv, "true")).To(Succeed())
+		DeferCleanup(os.Unsetenv, allowUnsignedEnv)
+		Expect(deliver()).To(Equal(http.StatusOK))
+		Expect(deliver()).To(Equal(http.StatusConflict))
+
+		records := deliveries.Records("", 0)
+		Expect(records[0].Duplicate).To(BeTrue())
+		Expect(records[1].Triggered).To(Equal([]string{"u-1/web"}))
+		Expect(records[1].Message).To(Equal("Triggered 1 GitshipApps"))
+	})
+})
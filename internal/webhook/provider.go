@@ -0,0 +1,341 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Provider is a git host sending webhooks.
+type Provider string
+
+const (
+	ProviderGitHub    Provider = "github"
+	ProviderGitLab    Provider = "gitlab"
+	ProviderGitea     Provider = "gitea"
+	ProviderBitbucket Provider = "bitbucket"
+)
+
+// Providers lists the providers the receiver accepts webhooks from. Each is
+// served at /api/webhooks/<provider>.
+var Providers = []Provider{ProviderGitHub, ProviderGitLab, ProviderGitea, ProviderBitbucket}
+
+// GitHub and GitLab only include the first 20 commits of a push in the payload.
+const maxPayloadCommits = 20
+
+var (
+	errMissingSignature = errors.New("missing signature")
+	errInvalidSignature = errors.New("invalid signature")
+)
+
+// PushEvent is a push to a branch or tag, normalized across providers.
+type PushEvent struct {
+	// Full ref name, e.g. refs/heads/main
+	Ref    string
+	Before string
+	After  string
+	// URLs the repository is known under
+	RepositoryURLs []string
+	// Files touched by the pushed commits, nil when the payload doesn't list
+	// all of them
+	ChangedFiles []string
+	// Whether the branch or tag was deleted
+	Deleted bool
+}
+
+// zeroCommit is the commit GitLab and Gitea send as the new head of a deleted ref.
+const zeroCommit = "0000000000000000000000000000000000000000"
+
+// payloadCommit lists the files a commit touched, in the format shared by
+// GitHub, GitLab and Gitea.
+type payloadCommit struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// changedFiles returns the files touched by commits, or nil when the payload
+// only lists some of the total pushed commits.
+func changedFiles(commits []payloadCommit, total int) []string {
+	if len(commits) == 0 || len(commits) < total {
+		return nil
+	}
+	var files []string
+	for _, c := range commits {
+		files = append(files, c.Added...)
+		files = append(files, c.Removed...)
+		files = append(files, c.Modified...)
+	}
+	return files
+}
+
+// secretEnv names the environment variable holding the provider's webhook secret.
+func (p Provider) secretEnv() string {
+	return strings.ToUpper(string(p)) + "_WEBHOOK_SECRET"
+}
+
+// eventType returns the kind of event the provider sent.
+func (p Provider) eventType(req *http.Request) string {
+	switch p {
+	case ProviderGitLab:
+		return req.Header.Get("X-Gitlab-Event")
+	case ProviderGitea:
+		return req.Header.Get("X-Gitea-Event")
+	case ProviderBitbucket:
+		return req.Header.Get("X-Event-Key")
+	default:
+		return req.Header.Get("X-GitHub-Event")
+	}
+}
+
+// deliveryID returns the ID the provider assigned to the delivery, empty if it doesn't send one.
+func (p Provider) deliveryID(req *http.Request) string {
+	switch p {
+	case ProviderGitLab:
+		return req.Header.Get("X-Gitlab-Event-UUID")
+	case ProviderGitea:
+		return req.Header.Get("X-Gitea-Delivery")
+	case ProviderBitbucket:
+		return req.Header.Get("X-Request-UUID")
+	default:
+		return req.Header.Get("X-GitHub-Delivery")
+	}
+}
+
+// verify checks that the request was sent with the webhook secret: GitLab
+// sends the secret itself, the others sign the payload with it.
+func (p Provider) verify(req *http.Request, payload []byte, secret []byte) error {
+	switch p {
+	case ProviderGitLab:
+		token := req.Header.Get("X-Gitlab-Token")
+		if token == "" {
+			return errMissingSignature
+		}
+		if subtle.ConstantTimeCompare([]byte(token), secret) != 1 {
+			return errInvalidSignature
+		}
+	case ProviderGitea:
+		signature := req.Header.Get("X-Gitea-Signature")
+		if signature == "" {
+			return errMissingSignature
+		}
+		if !validHMAC(payload, signature, secret) {
+			return errInvalidSignature
+		}
+	default:
+		// GitHub and Bitbucket
+		header := "X-Hub-Signature-256"
+		if p == ProviderBitbucket {
+			header = "X-Hub-Signature"
+		}
+		signature := req.Header.Get(header)
+		if signature == "" {
+			return errMissingSignature
+		}
+		if !verifySignature(payload, signature, secret) {
+			return errInvalidSignature
+		}
+	}
+	return nil
+}
+
+// parsePush parses the pushes, ref creations and deletions in a payload, nil
+// if the event is none of them.
+func (p Provider) parsePush(eventType string, payload []byte) ([]PushEvent, error) {
+	switch p {
+	case ProviderGitLab:
+		if eventType != "Push Hook" && eventType != "Tag Push Hook" {
+			return nil, nil
+		}
+		return parseGitLabPush(payload)
+	case ProviderGitea:
+		if eventType != "push" {
+			return nil, nil
+		}
+		return parseGiteaPush(payload)
+	case ProviderBitbucket:
+		if eventType != "repo:push" {
+			return nil, nil
+		}
+		return parseBitbucketPush(payload)
+	default:
+		switch eventType {
+		case "", "push":
+			return parseGitHubPush(payload)
+		case "create", "delete":
+			return parseGitHubRefEvent(eventType, payload)
+		}
+		return nil, nil
+	}
+}
+
+func parseGitHubPush(payload []byte) ([]PushEvent, error) {
+	var event struct {
+		Ref        string `json:"ref"`
+		Before     string `json:"before"`
+		After      string `json:"after"`
+		Repository struct {
+			CloneURL string `json:"clone_url"`
+			HTMLURL  string `json:"html_url"`
+		} `json:"repository"`
+		Commits []payloadCommit `json:"commits"`
+		Deleted bool            `json:"deleted"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	// GitHub doesn't send the total, a full page may be truncated
+	total := len(event.Commits)
+	if total >= maxPayloadCommits {
+		total++
+	}
+	return []PushEvent{{
+		Ref:            event.Ref,
+		Before:         event.Before,
+		After:          event.After,
+		RepositoryURLs: []string{event.Repository.CloneURL, event.Repository.HTMLURL},
+		ChangedFiles:   changedFiles(event.Commits, total),
+		Deleted:        event.Deleted,
+	}}, nil
+}
+
+// parseGitHubRefEvent parses the create and delete events GitHub sends besides
+// the push of a created or deleted ref. Branch creations are ignored, the push
+// of a new branch already triggers its apps.
+func parseGitHubRefEvent(eventType string, payload []byte) ([]PushEvent, error) {
+	var event struct {
+		Ref        string `json:"ref"`
+		RefType    string `json:"ref_type"`
+		Repository struct {
+			CloneURL string `json:"clone_url"`
+			HTMLURL  string `json:"html_url"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	var ref string
+	switch {
+	case event.RefType == "tag":
+		ref = "refs/tags/" + event.Ref
+	case event.RefType == "branch" && eventType == "delete":
+		ref = "refs/heads/" + event.Ref
+	default:
+		return nil, nil
+	}
+	return []PushEvent{{
+		Ref:            ref,
+		RepositoryURLs: []string{event.Repository.CloneURL, event.Repository.HTMLURL},
+		Deleted:        eventType == "delete",
+	}}, nil
+}
+
+func parseGitLabPush(payload []byte) ([]PushEvent, error) {
+	var event struct {
+		Ref     string `json:"ref"`
+		Before  string `json:"before"`
+		After   string `json:"after"`
+		Project struct {
+			GitHTTPURL string `json:"git_http_url"`
+			GitSSHURL  string `json:"git_ssh_url"`
+			WebURL     string `json:"web_url"`
+		} `json:"project"`
+		Commits           []payloadCommit `json:"commits"`
+		TotalCommitsCount int             `json:"total_commits_count"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return []PushEvent{{
+		Ref:            event.Ref,
+		Before:         event.Before,
+		After:          event.After,
+		RepositoryURLs: []string{event.Project.GitHTTPURL, event.Project.WebURL, event.Project.GitSSHURL},
+		ChangedFiles:   changedFiles(event.Commits, event.TotalCommitsCount),
+		Deleted:        event.After == zeroCommit,
+	}}, nil
+}
+
+func parseGiteaPush(payload []byte) ([]PushEvent, error) {
+	var event struct {
+		Ref        string `json:"ref"`
+		Before     string `json:"before"`
+		After      string `json:"after"`
+		Repository struct {
+			CloneURL string `json:"clone_url"`
+			HTMLURL  string `json:"html_url"`
+			SSHURL   string `json:"ssh_url"`
+		} `json:"repository"`
+		Commits      []payloadCommit `json:"commits"`
+		TotalCommits int             `json:"total_commits"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return []PushEvent{{
+		Ref:            event.Ref,
+		Before:         event.Before,
+		After:          event.After,
+		RepositoryURLs: []string{event.Repository.CloneURL, event.Repository.HTMLURL, event.Repository.SSHURL},
+		ChangedFiles:   changedFiles(event.Commits, event.TotalCommits),
+		Deleted:        event.After == zeroCommit,
+	}}, nil
+}
+
+// parseBitbucketPush returns one push per updated branch or tag. Bitbucket
+// doesn't list changed files, so path filters are applied by the controller.
+func parseBitbucketPush(payload []byte) ([]PushEvent, error) {
+	type bitbucketRef struct {
+		Type   string `json:"type"`
+		Name   string `json:"name"`
+		Target struct {
+			Hash string `json:"hash"`
+		} `json:"target"`
+	}
+	var event struct {
+		Push struct {
+			Changes []struct {
+				Old *bitbucketRef `json:"old"`
+				New *bitbucketRef `json:"new"`
+			} `json:"changes"`
+		} `json:"push"`
+		Repository struct {
+			Links struct {
+				HTML struct {
+					Href string `json:"href"`
+				} `json:"html"`
+			} `json:"links"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	refName := func(ref *bitbucketRef) string {
+		if ref.Type == "tag" {
+			return "refs/tags/" + ref.Name
+		}
+		return "refs/heads/" + ref.Name
+	}
+	events := []PushEvent{}
+	for _, change := range event.Push.Changes {
+		push := PushEvent{RepositoryURLs: []string{event.Repository.Links.HTML.Href}}
+		switch {
+		case change.New != nil:
+			push.Ref = refName(change.New)
+			push.After = change.New.Target.Hash
+		case change.Old != nil:
+			push.Ref = refName(change.Old)
+			push.Deleted = true
+		default:
+			continue
+		}
+		if change.Old != nil {
+			push.Before = change.Old.Target.Hash
+		}
+		events = append(events, push)
+	}
+	return events, nil
+}
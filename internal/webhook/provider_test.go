@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func hmacHex(payload, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var _ = Describe("Providers", func() {
+	secret := []byte("s3cret")
+	sign := func(payload []byte) string {
+		return hmacHex(payload, secret)
+	}
+
+	It("verifies the GitLab token", func() {
+		req := httptest.NewRequest("POST", "/api/webhooks/gitlab", nil)
+		Expect(ProviderGitLab.verify(req, nil, secret)).To(MatchError(errMissingSignature))
+		req.Header.Set("X-Gitlab-Token", "wrong")
+		Expect(ProviderGitLab.verify(req, nil, secret)).To(MatchError(errInvalidSignature))
+		req.Header.Set("X-Gitlab-Token", "s3cret")
+		Expect(ProviderGitLab.verify(req, nil, secret)).To(Succeed())
+	})
+
+	It("verifies Gitea and Bitbucket signatures", func() {
+		payload := []byte(`{"ref":"refs/heads/main"}`)
+		req := httptest.NewRequest("POST", "/api/webhooks/gitea", nil)
+		req.Header.Set("X-Gitea-Signature", sign(payload))
+		Expect(ProviderGitea.verify(req, payload, secret)).To(Succeed())
+		Expect(ProviderGitea.verify(req, []byte("{}"), secret)).To(MatchError(errInvalidSignature))
+
+		req = httptest.NewRequest("POST", "/api/webhooks/bitbucket", nil)
+		req.Header.Set("X-Hub-Signature", "sha256="+sign(payload))
+		Expect(ProviderBitbucket.verify(req, payload, secret)).To(Succeed())
+	})
+
+	It("normalizes a GitLab push", func() {
+		events, err := ProviderGitLab.parsePush("Push Hook", []byte(`{
+			"ref": "refs/heads/main", "before": "aaa", "after": "bbb",
+			"project": {"git_http_url": "https://gitlab.com/team/app.git", "web_url": "https://gitlab.com/team/app"},
+			"commits": [{"added": ["a.go"], "modified": ["b.go"], "removed": []}],
+			"total_commits_count": 1
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Ref).To(Equal("refs/heads/main"))
+		Expect(events[0].After).To(Equal("bbb"))
+		Expect(events[0].RepositoryURLs).To(ContainElement("https://gitlab.com/team/app.git"))
+		Expect(events[0].ChangedFiles).To(ConsistOf("a.go", "b.go"))
+	})
+
+	It("does not list changed files of truncated Gitea pushes", func() {
+		events, err := ProviderGitea.parsePush("push", []byte(`{
+			"ref": "refs/heads/main", "after": "bbb",
+			"commits": [{"added": ["a.go"]}], "total_commits": 12
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events[0].ChangedFiles).To(BeNil())
+	})
+
+	It("splits a Bitbucket push into one event per updated ref", func() {
+		events, err := ProviderBitbucket.parsePush("repo:push", []byte(`{
+			"push": {"changes": [
+				{"old": {"type": "branch", "name": "main", "target": {"hash": "aaa"}},
+				 "new": {"type": "branch", "name": "main", "target": {"hash": "bbb"}}},
+				{"old": null, "new": {"type": "tag", "name": "v1.0.0", "target": {"hash": "ccc"}}},
+				{"old": {"type": "branch", "name": "old", "target": {"hash": "ddd"}}, "new": null}
+			]},
+			"repository": {"links": {"html": {"href": "https://bitbucket.org/team/app"}}}
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(HaveLen(3))
+		Expect(events[0]).To(Equal(PushEvent{
+			Ref: "refs/heads/main", Before: "aaa", After: "bbb",
+			RepositoryURLs: []string{"https://bitbucket.org/team/app"},
+		}))
+		Expect(events[1].Ref).To(Equal("refs/tags/v1.0.0"))
+		Expect(events[2].Ref).To(Equal("refs/heads/old"))
+		Expect(events[2].Deleted).To(BeTrue())
+	})
+
+	It("parses GitHub tag creations and branch deletions", func() {
+		events, err := ProviderGitHub.parsePush("create", []byte(`{"ref": "v1.2.0", "ref_type": "tag"}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(Equal([]PushEvent{{Ref: "refs/tags/v1.2.0", RepositoryURLs: []string{"", ""}}}))
+
+		events, err = ProviderGitHub.parsePush("create", []byte(`{"ref": "feature", "ref_type": "branch"}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(BeNil())
+
+		events, err = ProviderGitHub.parsePush("delete", []byte(`{"ref": "feature", "ref_type": "branch"}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events[0].Ref).To(Equal("refs/heads/feature"))
+		Expect(events[0].Deleted).To(BeTrue())
+	})
+
+	It("reads each provider's secret from its own variable", func() {
+		Expect(ProviderGitea.secretEnv()).To(Equal("GITEA_WEBHOOK_SECRET"))
+	})
+
+	It("ignores events that aren't pushes", func() {
+		events, err := ProviderGitLab.parsePush("Merge Request Hook", []byte(`{}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(BeNil())
+	})
+})
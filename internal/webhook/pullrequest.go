@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type PullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Repository struct {
+		CloneURL string `json:"clone_url"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+}
+
+// handlePullRequest records opened, updated and closed pull requests on the
+// apps with previews enabled that track the pull request's base branch. The
+// controller creates and deletes the preview apps from that record.
+func (r *Receiver) handlePullRequest(ctx context.Context, w http.ResponseWriter, d *delivery) {
+	logger := log.FromContext(ctx)
+
+	var event PullRequestEvent
+	if err := json.Unmarshal(d.payload, &event); err != nil {
+		http.Error(w, "Failed to parse JSON", http.StatusBadRequest)
+		return
+	}
+
+	var open bool
+	switch event.Action {
+	case "opened", "reopened", "synchronize":
+		open = true
+	case "closed":
+		open = false
+	default:
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "Ignored pull request action %q", event.Action)
+		return
+	}
+
+	var apps gitshipiov1alpha1.GitshipAppList
+	if err := r.Client.List(ctx, &apps); err != nil {
+		logger.Error(err, "Failed to list GitshipApps")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	number := strconv.Itoa(event.Number)
+	for _, app := range apps.Items {
+		if !app.Spec.Previews.Enabled || app.Spec.Source.Type != "branch" ||
+			sourceBranch(&app) != event.PullRequest.Base.Ref ||
+			!matchesRepository(&app, event.Repository.CloneURL, event.Repository.HTMLURL) ||
+			!d.addressedTo(&app) {
+			continue
+		}
+		if err := r.authenticate(ctx, d, &app); err != nil {
+			d.reject(&app, err)
+			continue
+		}
+
+		pullRequests := map[string]string{}
+		if raw := app.Annotations[gitshipiov1alpha1.PullRequestsAnnotation]; raw != "" {
+			_ = json.Unmarshal([]byte(raw), &pullRequests)
+		}
+		if open {
+			pullRequests[number] = event.PullRequest.Head.SHA
+		} else if _, ok := pullRequests[number]; ok {
+			delete(pullRequests, number)
+		} else {
+			continue
+		}
+		encoded, err := json.Marshal(pullRequests)
+		if err != nil {
+			continue
+		}
+
+		patch := client.MergeFrom(app.DeepCopy())
+		if app.Annotations == nil {
+			app.Annotations = make(map[string]string)
+		}
+		app.Annotations[gitshipiov1alpha1.PullRequestsAnnotation] = string(encoded)
+		if err := r.Client.Patch(ctx, &app, patch); err != nil {
+			logger.Error(err, "Failed to patch GitshipApp", "Name", app.Name)
+			continue
+		}
+
+		logger.Info("Recorded pull request via Webhook", "Name", app.Name, "pullRequest", event.Number, "action", event.Action)
+		d.triggered = append(d.triggered, appKey(&app))
+	}
+
+	if len(d.triggered) == 0 && len(d.rejected) > 0 {
+		http.Error(w, "Delivery is not signed with the webhook secret of any matching GitshipApp", http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(w, "Updated previews of %d GitshipApps", len(d.triggered))
+}
@@ -5,34 +5,30 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+	"github.com/gitshipio/gitship/internal/gitutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 type Receiver struct {
 	Client client.Client
-}
-
-type PushEvent struct {
-	Ref        string `json:"ref"`
-	Repository struct {
-		CloneURL string `json:"clone_url"`
-		HTMLURL  string `json:"html_url"`
-	} `json:"repository"`
+	// Provider the webhooks are sent by, GitHub if empty
+	Provider Provider
+	// Deliveries records the outcome of each delivery and rejects duplicates, optional
+	Deliveries *DeliveryLog
 }
 
 func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	ctx := context.Background()
-	logger := log.FromContext(ctx)
 
 	// Read Payload
 	payload, err := io.ReadAll(req.Body)
@@ -44,29 +40,72 @@ func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		_ = req.Body.Close()
 	}()
 
-	// Verify Signature (Global Secret from Env)
-	secret := os.Getenv("GITHUB_WEBHOOK_SECRET")
-	if secret != "" {
-		signature := req.Header.Get("X-Hub-Signature-256")
-		if signature == "" {
-			http.Error(w, "Missing signature", http.StatusUnauthorized)
+	provider := r.Provider
+	if provider == "" {
+		provider = ProviderGitHub
+	}
+
+	// Deliveries are verified against the secret of each matching app
+	d := &delivery{provider: provider, req: req, payload: payload}
+	if r.Deliveries == nil {
+		r.serve(ctx, w, d)
+		return
+	}
+
+	record := DeliveryRecord{
+		ID:         provider.deliveryID(req),
+		Provider:   provider,
+		Event:      provider.eventType(req),
+		ReceivedAt: time.Now(),
+	}
+	if !r.Deliveries.begin(record.ID) {
+		record.StatusCode = http.StatusConflict
+		record.Message = "Duplicate delivery"
+		record.Duplicate = true
+		r.Deliveries.finish(record, false)
+		http.Error(w, record.Message, record.StatusCode)
+		return
+	}
+	rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	r.serve(ctx, rec, d)
+
+	record.StatusCode = rec.status
+	record.Message = strings.TrimSpace(rec.body.String())
+	record.Triggered, record.Skipped, record.Rejected = d.triggered, d.skipped, d.rejected
+	// Only deliveries that changed something are remembered, a failed delivery can be redelivered
+	r.Deliveries.finish(record, len(d.triggered) > 0)
+}
+
+// serve processes a delivery and records its outcome on d.
+func (r *Receiver) serve(ctx context.Context, w http.ResponseWriter, d *delivery) {
+	logger := log.FromContext(ctx)
+	provider := d.provider
+
+	eventType := provider.eventType(d.req)
+	if provider == ProviderGitHub {
+		switch eventType {
+		case "ping":
+			// Sent when the webhook is created
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, "pong")
 			return
-		}
-		if !verifySignature(payload, signature, []byte(secret)) {
-			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		case "pull_request":
+			r.handlePullRequest(ctx, w, d)
 			return
 		}
 	}
 
 	// Parse Event
-	var event PushEvent
-	if err := json.Unmarshal(payload, &event); err != nil {
+	events, err := provider.parsePush(eventType, d.payload)
+	if err != nil {
 		http.Error(w, "Failed to parse JSON", http.StatusBadRequest)
 		return
 	}
-
-	rawRef := event.Ref // e.g., refs/heads/main
-	branch := strings.TrimPrefix(rawRef, "refs/heads/")
+	if events == nil {
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "Ignored %s event %q", provider, eventType)
+		return
+	}
 
 	// Find matching GitshipApps
 	var apps gitshipiov1alpha1.GitshipAppList
@@ -76,26 +115,44 @@ func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	triggeredCount := 0
+	for i := range events {
+		r.triggerApps(ctx, d, apps.Items, &events[i])
+	}
+
+	if len(d.triggered) == 0 && len(d.rejected) > 0 {
+		http.Error(w, "Delivery is not signed with the webhook secret of any matching GitshipApp", http.StatusUnauthorized)
+	} else if len(d.triggered) > 0 {
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "Triggered %d GitshipApps", len(d.triggered))
+	} else {
+		w.WriteHeader(http.StatusOK) // 200 OK even if no match, to satisfy the provider
+		_, _ = fmt.Fprint(w, "No matching GitshipApps found")
+	}
+}
+
+// triggerApps triggers the apps tracking the pushed branch or tag and records
+// which apps were triggered, skipped or rejected the delivery on d. Apps whose
+// branch or tag was deleted are marked with the SourceMissing condition instead.
+func (r *Receiver) triggerApps(ctx context.Context, d *delivery, apps []gitshipiov1alpha1.GitshipApp, event *PushEvent) {
+	logger := log.FromContext(ctx)
+
+	rawRef := event.Ref // e.g., refs/heads/main
+	branch := strings.TrimPrefix(rawRef, "refs/heads/")
 
-	for _, app := range apps.Items {
+	for _, app := range apps {
 		// Match Source
 		source := app.Spec.Source
 		isMatch := false
 
 		switch source.Type {
 		case "branch":
-			targetBranch := source.Value
-			if targetBranch == "" || targetBranch == "HEAD" {
-				targetBranch = "main" // Simplified fallback
-			}
-			if targetBranch == branch {
+			if sourceBranch(&app) == branch {
 				isMatch = true
 			}
 		case "tag":
 			if strings.HasPrefix(rawRef, "refs/tags/") {
 				tag := strings.TrimPrefix(rawRef, "refs/tags/")
-				if tag == source.Value {
+				if gitutil.MatchTag(source.Value, tag) {
 					isMatch = true
 				}
 			}
@@ -105,12 +162,28 @@ func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			continue
 		}
 
-		// Match URL (normalize)
-		targetURL := normalizeURL(app.Spec.RepoURL)
-		eventURL := normalizeURL(event.Repository.CloneURL)
-		eventURL2 := normalizeURL(event.Repository.HTMLURL)
+		if !matchesRepository(&app, event.RepositoryURLs...) || !d.addressedTo(&app) {
+			continue
+		}
+
+		if err := r.authenticate(ctx, d, &app); err != nil {
+			d.reject(&app, err)
+			continue
+		}
+
+		// A tag pattern still matches other tags, re-resolve it instead
+		if event.Deleted && !(source.Type == "tag" && gitutil.IsTagPattern(source.Value)) {
+			if err := r.markSourceMissing(ctx, &app, event.Ref); err != nil {
+				logger.Error(err, "Failed to mark source missing", "Name", app.Name)
+				continue
+			}
+			logger.Info("Tracked ref of GitshipApp deleted", "Name", app.Name, "ref", event.Ref)
+			d.triggered = append(d.triggered, appKey(&app))
+			continue
+		}
 
-		if targetURL != eventURL && targetURL != eventURL2 {
+		if r.skipByPathFilter(ctx, &app, event) {
+			d.skipped = append(d.skipped, appKey(&app))
 			continue
 		}
 
@@ -128,16 +201,85 @@ func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 
 		logger.Info("Triggered GitshipApp via Webhook", "Name", app.Name)
-		triggeredCount++
+		d.triggered = append(d.triggered, appKey(&app))
 	}
+}
 
-	if triggeredCount > 0 {
-		w.WriteHeader(http.StatusOK)
-		_, _ = fmt.Fprintf(w, "Triggered %d GitshipApps", triggeredCount)
-	} else {
-		w.WriteHeader(http.StatusOK) // 200 OK even if no match, to satisfy GitHub
-		_, _ = fmt.Fprint(w, "No matching GitshipApps found")
+// skipByPathFilter records a skipped build instead of triggering the app when
+// none of the pushed files match its path filters. It only applies when the
+// push directly follows the last built commit; otherwise the controller diffs
+// against that build itself.
+func (r *Receiver) skipByPathFilter(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, event *PushEvent) bool {
+	changedFiles := event.ChangedFiles
+	filter := app.Spec.Paths
+	if len(filter.Include) == 0 && len(filter.Exclude) == 0 {
+		return false
+	}
+	if changedFiles == nil || event.Before == "" || event.Before != app.Status.LatestBuildID {
+		return false
 	}
+	if gitutil.MatchesPathFilter(filter.Include, filter.Exclude, changedFiles) {
+		return false
+	}
+
+	patch := client.MergeFrom(app.DeepCopy())
+	record := gitshipiov1alpha1.BuildRecord{
+		CommitID:       event.After,
+		Status:         "Skipped",
+		CompletionTime: time.Now().Format(time.RFC3339),
+		Message:        fmt.Sprintf("No matching paths changed (%d files changed)", len(changedFiles)),
+	}
+	history := append([]gitshipiov1alpha1.BuildRecord{record}, app.Status.BuildHistory...)
+	if len(history) > 10 {
+		history = history[:10]
+	}
+	app.Status.BuildHistory = history
+	app.Status.SkippedCommit = event.After
+	if err := r.Client.Status().Patch(ctx, app, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to record skipped build", "Name", app.Name)
+		return false
+	}
+	log.FromContext(ctx).Info("Skipped GitshipApp, no matching paths changed", "Name", app.Name, "commit", event.After)
+	return true
+}
+
+// markSourceMissing sets the SourceMissing condition of an app whose branch or
+// tag was deleted. The controller clears it once the ref exists again.
+func (r *Receiver) markSourceMissing(ctx context.Context, app *gitshipiov1alpha1.GitshipApp, ref string) error {
+	patch := client.MergeFrom(app.DeepCopy())
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               gitshipiov1alpha1.ConditionSourceMissing,
+		Status:             metav1.ConditionTrue,
+		Reason:             "RefDeleted",
+		Message:            fmt.Sprintf("%s was deleted", ref),
+		ObservedGeneration: app.Generation,
+	})
+	return r.Client.Status().Patch(ctx, app, patch)
+}
+
+// sourceBranch returns the branch a branch source tracks: its value, or the
+// repository's default branch resolved by the controller.
+func sourceBranch(app *gitshipiov1alpha1.GitshipApp) string {
+	source := app.Spec.Source
+	if source.Value != "" && source.Value != "HEAD" {
+		return source.Value
+	}
+	if app.Status.DefaultBranch != "" {
+		return app.Status.DefaultBranch
+	}
+	return "main" // Not resolved yet
+}
+
+// matchesRepository reports whether the app builds the repository an event
+// was sent for, known under any of urls.
+func matchesRepository(app *gitshipiov1alpha1.GitshipApp, urls ...string) bool {
+	targetURL := normalizeURL(app.Spec.RepoURL)
+	for _, u := range urls {
+		if u != "" && normalizeURL(u) == targetURL {
+			return true
+		}
+	}
+	return false
 }
 
 func normalizeURL(u string) string {
@@ -148,16 +290,21 @@ func normalizeURL(u string) string {
 	return strings.ToLower(u)
 }
 
+// verifySignature checks a "sha256=<hex>" HMAC signature of the payload.
 func verifySignature(payload []byte, signature string, secret []byte) bool {
 	parts := strings.SplitN(signature, "=", 2)
 	if len(parts) != 2 || parts[0] != "sha256" {
 		return false
 	}
+	return validHMAC(payload, parts[1], secret)
+}
 
+// validHMAC checks a hex encoded HMAC-SHA256 of the payload.
+func validHMAC(payload []byte, signature string, secret []byte) bool {
 	mac := hmac.New(sha256.New, secret)
 	mac.Write(payload)
 	expectedMAC := mac.Sum(nil)
 	expectedSig := hex.EncodeToString(expectedMAC)
 
-	return hmac.Equal([]byte(parts[1]), []byte(expectedSig))
+	return hmac.Equal([]byte(signature), []byte(expectedSig))
 }
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gitshipiov1alpha1 "github.com/gitshipio/gitship/api/gitship.io/v1alpha1"
+)
+
+var _ = Describe("Receiver", func() {
+	var r *Receiver
+
+	newApp := func(name, sourceType, value string) *gitshipiov1alpha1.GitshipApp {
+		app := &gitshipiov1alpha1.GitshipApp{}
+		app.Name, app.Namespace = name, "u-1"
+		app.Spec.RepoURL = "https://github.com/o/r"
+		app.Spec.Source = gitshipiov1alpha1.SourceConfig{Type: sourceType, Value: value}
+		return app
+	}
+
+	BeforeEach(func() {
+		Expect(os.Setenv(allowUnsignedEnv, "true")).To(Succeed())
+		DeferCleanup(os.Unsetenv, allowUnsignedEnv)
+
+		scheme := runtime.NewScheme()
+		Expect(gitshipiov1alpha1.AddToScheme(scheme)).To(Succeed())
+		trunk := newApp("trunk", "branch", "")
+		trunk.Status.DefaultBranch = "trunk"
+		apps := []client.Object{newApp("web", "branch", "feature"), newApp("api", "tag", "v1.*"), newApp("docs", "tag", "v2.0.0"), trunk}
+		r = &Receiver{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(apps...).
+			WithStatusSubresource(&gitshipiov1alpha1.GitshipApp{}).Build()}
+	})
+
+	deliver := func(event, payload string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/webhooks/github", strings.NewReader(payload))
+		req.Header.Set("X-GitHub-Event", event)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(name string) *gitshipiov1alpha1.GitshipApp {
+		app := &gitshipiov1alpha1.GitshipApp{}
+		Expect(r.Client.Get(context.Background(), client.ObjectKey{Namespace: "u-1", Name: name}, app)).To(Succeed())
+		return app
+	}
+
+	It("acknowledges pings", func() {
+		w := deliver("ping", `{"zen":"Keep it logically awesome."}`)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(Equal("pong"))
+	})
+
+	It("marks apps whose branch was deleted", func() {
+		w := deliver("delete", `{"ref":"feature","ref_type":"branch","repository":{"html_url":"https://github.com/o/r"}}`)
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		app := get("web")
+		Expect(meta.IsStatusConditionTrue(app.Status.Conditions, gitshipiov1alpha1.ConditionSourceMissing)).To(BeTrue())
+		Expect(app.Annotations).NotTo(HaveKey("gitship.io/last-webhook-trigger"))
+	})
+
+	It("triggers apps tracking a pattern matching a created tag", func() {
+		w := deliver("create", `{"ref":"v1.3.0","ref_type":"tag","repository":{"html_url":"https://github.com/o/r"}}`)
+		Expect(w.Body.String()).To(Equal("Triggered 1 GitshipApps"))
+		Expect(get("api").Annotations).To(HaveKey("gitship.io/last-webhook-trigger"))
+		Expect(get("docs").Annotations).NotTo(HaveKey("gitship.io/last-webhook-trigger"))
+	})
+
+	It("matches pushes to the default branch of apps tracking HEAD", func() {
+		w := deliver("push", `{"ref":"refs/heads/trunk","repository":{"html_url":"https://github.com/o/r"}}`)
+		Expect(w.Body.String()).To(Equal("Triggered 1 GitshipApps"))
+		Expect(get("trunk").Annotations).To(HaveKey("gitship.io/last-webhook-trigger"))
+	})
+
+	It("only delivers to the app a webhook is registered for", func() {
+		payload := `{"ref":"v1.3.0","ref_type":"tag","repository":{"html_url":"https://github.com/o/r"}}`
+		req := httptest.NewRequest("POST", "/api/webhooks/github?app=u-1%2Fdocs", strings.NewReader(payload))
+		req.Header.Set("X-GitHub-Event", "create")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		Expect(w.Body.String()).To(Equal("No matching GitshipApps found"))
+		Expect(get("api").Annotations).NotTo(HaveKey("gitship.io/last-webhook-trigger"))
+
+		req = httptest.NewRequest("POST", "/api/webhooks/github?app=u-1%2Fapi", strings.NewReader(payload))
+		req.Header.Set("X-GitHub-Event", "create")
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		Expect(w.Body.String()).To(Equal("Triggered 1 GitshipApps"))
+	})
+})
@@ -1,6 +1,7 @@
 import { NextRequest, NextResponse } from "next/server"
 import { auth } from "@/auth"
-import { k8sCoreApi, k8sBatchApi } from "@/lib/k8s"
+import { k8sCoreApi, k8sBatchApi, k8sCustomApi } from "@/lib/k8s"
+import { GitshipBuild } from "@/lib/types"
 import { hasNamespaceAccess } from "@/lib/auth-utils"
 
 export async function GET(
@@ -23,7 +24,7 @@ export async function GET(
     })
     
     const jobs = jobsRes.items || []
-    if (jobs.length === 0) return NextResponse.json({ logs: "No build jobs found." })
+    if (jobs.length === 0) return NextResponse.json(await capturedBuildLogs(namespace, name))
 
     // Sort by creation timestamp descending
     const latestJob = jobs.sort((a, b) => 
@@ -65,3 +66,31 @@ export async function GET(
     return NextResponse.json({ error: e.message }, { status: 500 })
   }
 }
+
+// Build Jobs expire an hour after finishing, the controller keeps the tail of
+// their logs in the GitshipBuild record.
+async function capturedBuildLogs(namespace: string, name: string) {
+  const res = await k8sCustomApi.listNamespacedCustomObject({
+    group: "gitship.io",
+    version: "v1alpha1",
+    namespace,
+    plural: "gitshipbuilds",
+    labelSelector: `gitship.io/app=${name}`
+  })
+  const builds: GitshipBuild[] = res.items || []
+  if (builds.length === 0) return { logs: "No build jobs found." }
+
+  const latest = builds.sort((a, b) =>
+    new Date(b.metadata.creationTimestamp || 0).getTime() - new Date(a.metadata.creationTimestamp || 0).getTime()
+  )[0]
+  const logs = (latest.status?.logs || [])
+    .map((l) => `==> ${l.container} <==\n${l.log || ""}`)
+    .join("\n")
+  const failed = latest.status?.phase === "Failed"
+
+  return {
+    logs: logs || latest.status?.message || "No logs were captured for this build.",
+    jobName: latest.spec.jobName,
+    status: { succeeded: latest.status?.phase === "Succeeded" ? 1 : 0, failed: failed ? 1 : 0 }
+  }
+}
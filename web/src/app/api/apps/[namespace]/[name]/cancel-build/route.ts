@@ -0,0 +1,37 @@
+import { auth } from "@/auth"
+import { k8sMergePatch } from "@/lib/k8s"
+import { NextResponse } from "next/server"
+import { hasNamespaceAccess } from "@/lib/auth-utils"
+
+export async function POST(
+  req: Request,
+  { params }: { params: Promise<{ namespace: string, name: string }> }
+) {
+  const session = await auth()
+  const { namespace, name } = await params
+
+  if (!(await hasNamespaceAccess(namespace, session))) {
+    return new NextResponse("Unauthorized", { status: 401 })
+  }
+
+  try {
+    // The controller cancels the running build when the cancel token changes
+    await k8sMergePatch({
+      group: "gitship.io",
+      version: "v1alpha1",
+      namespace,
+      plural: "gitshipapps",
+      name,
+      body: {
+        spec: {
+          cancelToken: Date.now().toString()
+        }
+      }
+    })
+
+    return NextResponse.json({ ok: true })
+  } catch (e: any) {
+    console.error("[API] Failed to cancel build:", e.body?.message || e.message)
+    return NextResponse.json({ error: e.body?.message || e.message }, { status: 500 })
+  }
+}
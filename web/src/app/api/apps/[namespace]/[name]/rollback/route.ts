@@ -15,9 +15,10 @@ export async function POST(
     return NextResponse.json({ error: "Access Denied" }, { status: 403 })
   }
 
+  // A null commitId removes the pin and resumes tracking the source
   const { commitId } = await request.json()
 
-  if (!commitId) return NextResponse.json({ error: "commitId is required" }, { status: 400 })
+  if (commitId === undefined) return NextResponse.json({ error: "commitId is required" }, { status: 400 })
 
   try {
     await k8sMergePatch({
@@ -28,10 +29,8 @@ export async function POST(
       name,
       body: {
         spec: {
-          source: {
-            type: "commit",
-            value: commitId
-          }
+          // Runs the already built image, no rebuild
+          pinnedCommit: commitId
         }
       }
     })
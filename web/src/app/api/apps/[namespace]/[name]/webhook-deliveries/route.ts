@@ -0,0 +1,35 @@
+import { auth } from "@/auth"
+import { NextResponse } from "next/server"
+import { hasNamespaceAccess } from "@/lib/auth-utils"
+
+// Internal URL of the controller manager webhook server
+const CONTROLLER_WEBHOOK_BASE_URL =
+  process.env.CONTROLLER_WEBHOOK_URL?.replace(/\/github$/, "") ||
+  `http://controller-manager-webhook.${process.env.SYSTEM_NAMESPACE || "gitship-system"}.svc.cluster.local:3001/api/webhooks`
+
+export async function GET(
+  req: Request,
+  { params }: { params: Promise<{ namespace: string, name: string }> }
+) {
+  const session = await auth()
+  const { namespace, name } = await params
+
+  if (!(await hasNamespaceAccess(namespace, session))) {
+    return new NextResponse("Unauthorized", { status: 401 })
+  }
+
+  try {
+    const query = new URLSearchParams({ app: `${namespace}/${name}` })
+    const limit = new URL(req.url).searchParams.get("limit")
+    if (limit) query.set("limit", limit)
+
+    const resp = await fetch(`${CONTROLLER_WEBHOOK_BASE_URL}/deliveries?${query}`)
+    if (!resp.ok) {
+      return NextResponse.json({ error: await resp.text() }, { status: resp.status })
+    }
+    return NextResponse.json(await resp.json())
+  } catch (e: any) {
+    console.error("[API] Failed to fetch webhook deliveries:", e.message)
+    return NextResponse.json({ error: e.message }, { status: 500 })
+  }
+}
@@ -0,0 +1,55 @@
+import { NextRequest, NextResponse } from "next/server"
+
+const PROVIDERS = ["github", "gitlab", "gitea", "bitbucket"]
+
+// Headers carrying the event type, delivery ID and signature of each provider
+const FORWARDED_HEADERS = [
+    "x-hub-signature-256",
+    "x-github-event",
+    "x-github-delivery",
+    "x-gitlab-token",
+    "x-gitlab-event",
+    "x-gitlab-event-uuid",
+    "x-gitea-signature",
+    "x-gitea-event",
+    "x-gitea-delivery",
+    "x-hub-signature",
+    "x-event-key",
+    "x-request-uuid",
+]
+
+// Internal URL of the controller manager webhook server
+const CONTROLLER_WEBHOOK_BASE_URL =
+    process.env.CONTROLLER_WEBHOOK_URL?.replace(/\/github$/, "") ||
+    `http://controller-manager-webhook.${process.env.SYSTEM_NAMESPACE || "gitship-system"}.svc.cluster.local:3001/api/webhooks`
+
+export async function POST(req: NextRequest, { params }: { params: Promise<{ provider: string }> }) {
+    const { provider } = await params
+    if (!PROVIDERS.includes(provider)) {
+        return NextResponse.json({ error: `Unsupported provider ${provider}` }, { status: 404 })
+    }
+
+    try {
+        const body = await req.text()
+
+        // Forward to the controller manager's webhook receiver, which verifies
+        // the delivery against the webhook secret of each matching app
+        const headers: Record<string, string> = { "Content-Type": "application/json" }
+        for (const name of FORWARDED_HEADERS) {
+            const value = req.headers.get(name)
+            if (value) headers[name] = value
+        }
+        // The query names the app a hook registered by the dashboard delivers to
+        const resp = await fetch(`${CONTROLLER_WEBHOOK_BASE_URL}/${provider}${req.nextUrl.search}`, {
+            method: "POST",
+            headers,
+            body,
+        })
+
+        const text = await resp.text()
+        return new NextResponse(text, { status: resp.status })
+    } catch (error) {
+        console.error("[Webhook] Failed to process webhook:", error)
+        return NextResponse.json({ error: "Internal server error" }, { status: 500 })
+    }
+}
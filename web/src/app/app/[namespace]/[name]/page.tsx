@@ -35,7 +35,8 @@ export default async function AppDetailsPage({ params }: { params: Promise<{ nam
         switch (phase) {
             case "Running": return "bg-green-500"
             case "Building":
-            case "Deploying": return "bg-yellow-500"
+            case "Deploying":
+            case "Degraded": return "bg-yellow-500"
             case "Failed": return "bg-red-500"
             default: return "bg-gray-500"
         }
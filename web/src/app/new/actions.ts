@@ -10,6 +10,7 @@ import { createSecret } from "@/lib/secrets"
 import { redirect } from "next/navigation"
 import { z } from "zod"
 import { headers } from "next/headers"
+import crypto from "crypto"
 import { resolveUserSession } from "@/lib/auth-utils"
 
 const createAppSchema = z.object({
@@ -93,7 +94,7 @@ export async function createApp(formData: FormData) {
   const namespace = await ensureUserNamespace(internalId)
 
   // 1. Setup SSH Authentication
-  let authMethod = "token"
+  let authMethod = "auto"
   // Try to use token from session
   // @ts-expect-error dynamic property
   const token = session.accessToken
@@ -126,6 +127,19 @@ export async function createApp(formData: FormData) {
       }
   }
 
+  // 2. Webhook deliveries for this app are verified with its own secret
+  let webhookSecret = ""
+  let webhookSecretRef = ""
+  if (data.updateStrategy === "webhook") {
+      try {
+          webhookSecret = crypto.randomBytes(32).toString("hex")
+          webhookSecretRef = await createSecret(namespace, data.name, "webhook", { secret: webhookSecret })
+      } catch (e) {
+          console.error("[createApp] Failed to create webhook secret:", e)
+          webhookSecret = ""
+      }
+  }
+
   const gitshipApp = {
     apiVersion: "gitship.io/v1alpha1",
     kind: "GitshipApp",
@@ -167,6 +181,7 @@ export async function createApp(formData: FormData) {
         type: data.updateStrategy,
         interval: data.pollInterval,
       },
+      ...(webhookSecretRef ? { webhookSecretRef } : {}),
     },
   }
 
@@ -191,7 +206,7 @@ export async function createApp(formData: FormData) {
 
   // Attempt to create Webhook if strategy is webhook
   // @ts-expect-error dynamic property
-  if (data.updateStrategy === "webhook" && session.accessToken) {
+  if (data.updateStrategy === "webhook" && webhookSecret && session.accessToken) {
     const headerList = await headers()
     const host = headerList.get("host")
     const proto = headerList.get("x-forwarded-proto") || "http"
@@ -204,11 +219,13 @@ export async function createApp(formData: FormData) {
             if (urlParts.length >= 2) {
                 const owner = urlParts[0]
                 const repo = urlParts[1].replace(".git", "")
-                const webhookUrl = `${publicUrl}/api/webhooks/github`
+                // One hook per app, each signed with the app's secret. The receiver
+                // only delivers to the app named in the URL, and teardown finds it by it.
+                const webhookUrl = `${publicUrl}/api/webhooks/github?app=${encodeURIComponent(`${namespace}/${data.name}`)}`
                 
                 console.log(`[createApp] Attempting to create webhook for ${owner}/${repo} -> ${webhookUrl}`)
                 // @ts-expect-error dynamic property
-                const success = await createRepositoryWebhook(owner, repo, webhookUrl, session.accessToken)
+                const success = await createRepositoryWebhook(owner, repo, webhookUrl, session.accessToken, webhookSecret)
                 if (success) {
                     console.log(`[createApp] Successfully created webhook for ${owner}/${repo}`)
                 }
@@ -2,7 +2,7 @@
 
 import { useCallback, useState, useEffect, useRef } from "react"
 import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card"
-import { Terminal, Loader2, RefreshCcw, CheckCircle2, XCircle, Clock, RotateCcw } from "lucide-react"
+import { Terminal, Loader2, RefreshCcw, CheckCircle2, XCircle, Clock, RotateCcw, Square } from "lucide-react"
 import { Button } from "@/components/ui/button"
 import { Badge } from "@/components/ui/badge"
 import { cn } from "@/lib/utils"
@@ -67,7 +67,7 @@ export function AppBuildLogs({ appName, namespace, buildHistory }: AppBuildLogsP
     const isActive = status && !isSuccess && !isFailed
 
     const handleRollback = async (commitId: string) => {
-        if (!confirm(`Are you sure you want to rollback to commit ${commitId.substring(0, 7)}?`)) return
+        if (!confirm(`Are you sure you want to pin the app to commit ${commitId.substring(0, 7)}? New commits won't be deployed until it is unpinned.`)) return
         try {
             const res = await fetch(`/api/apps/${namespace}/${appName}/rollback`, {
                 method: "POST",
@@ -85,6 +85,19 @@ export function AppBuildLogs({ appName, namespace, buildHistory }: AppBuildLogsP
         }
     }
 
+    const handleCancel = async () => {
+        if (!confirm("Are you sure you want to cancel the running build?")) return
+        try {
+            const res = await fetch(`/api/apps/${namespace}/${appName}/cancel-build`, { method: "POST" })
+            if (!res.ok) {
+                alert("Failed to cancel build.")
+            }
+        } catch (e: unknown) {
+            // @ts-expect-error dynamic access
+            alert(`Error: ${e.message}`)
+        }
+    }
+
     return (
         <Card className="border-border/60 shadow-lg bg-card/50 backdrop-blur-sm">
             <CardHeader className="flex flex-row items-center justify-between border-b bg-muted/20 py-4">
@@ -112,9 +125,16 @@ export function AppBuildLogs({ appName, namespace, buildHistory }: AppBuildLogsP
                         )}
                     </div>
                 </div>
-                <Button size="icon" variant="ghost" className="h-8 w-8" onClick={() => { setLoading(true); fetchLogs(); }}>
-                    <RefreshCcw className={`w-4 h-4 ${loading ? 'animate-spin' : ''}`} />
-                </Button>
+                <div className="flex items-center gap-1">
+                    {isActive && (
+                        <Button size="icon" variant="ghost" className="h-8 w-8 text-destructive" title="Cancel build" onClick={handleCancel}>
+                            <Square className="w-4 h-4" />
+                        </Button>
+                    )}
+                    <Button size="icon" variant="ghost" className="h-8 w-8" onClick={() => { setLoading(true); fetchLogs(); }}>
+                        <RefreshCcw className={`w-4 h-4 ${loading ? 'animate-spin' : ''}`} />
+                    </Button>
+                </div>
             </CardHeader>
             <CardContent className="p-0">
                 <div 
@@ -153,14 +173,16 @@ export function AppBuildLogs({ appName, namespace, buildHistory }: AppBuildLogsP
                                     <Badge variant="outline" className="text-[9px] h-4 font-mono opacity-50">
                                         {record.commitId.substring(0, 7)}
                                     </Badge>
-                                    <Button 
-                                        size="sm" 
-                                        variant="outline" 
-                                        className="h-7 text-[10px] gap-1 font-bold hover:bg-amber-500 hover:text-white transition-all"
-                                        onClick={() => handleRollback(record.commitId)}
-                                    >
-                                        <RotateCcw className="w-3 h-3" /> Rollback
-                                    </Button>
+                                    {record.status === "Succeeded" && (
+                                        <Button 
+                                            size="sm" 
+                                            variant="outline" 
+                                            className="h-7 text-[10px] gap-1 font-bold hover:bg-amber-500 hover:text-white transition-all"
+                                            onClick={() => handleRollback(record.commitId)}
+                                        >
+                                            <RotateCcw className="w-3 h-3" /> Rollback
+                                        </Button>
+                                    )}
                                 </div>
                             </div>
                         ))}
@@ -29,7 +29,7 @@ interface AppConfigurationProps {
         domain?: string
         replicas?: number
         env?: Record<string, string>
-        volumes?: { name: string; mountPath: string; size: string; storageClass?: string }[]
+        volumes?: { name: string; mountPath: string; size: string; storageClass?: string; deletionPolicy?: "Retain" | "Delete" }[]
         updateStrategy?: { type: "polling" | "webhook"; interval?: string }
         tls?: { enabled: boolean; issuer?: string }
         secretRefs?: string[]
@@ -59,7 +59,7 @@ export function AppConfiguration({ appName, namespace, spec }: AppConfigurationP
     const [isDropdownOpen, setDropdownOpen] = useState(false)
     const dropdownRef = useRef<HTMLDivElement>(null)
 
-    const [authMethod, setAuthMethod] = useState(spec?.authMethod || "token")
+    const [authMethod, setAuthMethod] = useState(spec?.authMethod || "auto")
     const [setupLoading, setSetupLoading] = useState(false)
     const [sshResult, setSshResult] = useState<{ success: boolean, autoAdded: boolean, publicKey?: string } | null>(null)
 
@@ -35,6 +35,18 @@ function StatusIndicator({ app }: { app: GitshipApp }) {
     )
   }
 
+  if (phase === "RolledBack") {
+    return (
+      <div
+        className="flex items-center gap-1.5 text-amber-500 bg-amber-500/10 px-2 py-0.5 rounded-full text-xs font-medium border border-amber-500/20"
+        title={app.status?.rollbackReason}
+      >
+        <AlertCircle className="w-3 h-3" />
+        Rolled Back
+      </div>
+    )
+  }
+
   if (phase === "Failed" || (desired > 0 && ready === 0)) {
     return (
         <div className="flex items-center gap-1.5 text-red-500 bg-red-500/10 px-2 py-0.5 rounded-full text-xs font-medium border border-red-500/20">
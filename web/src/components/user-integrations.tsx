@@ -18,6 +18,7 @@ export function UserIntegrations() {
     const [installing, setInstalling] = useState(false)
     const [deleting, setDeleting] = useState<string | null>(null)
     const [cfToken, setCfToken] = useState("")
+    const [cfApiToken, setCfApiToken] = useState("")
     const [cmEmail, setCmEmail] = useState("")
     const [cmDnsToken, setCmDnsToken] = useState("")
     const [dialogOpen, setDialogOpen] = useState(false)
@@ -54,12 +55,13 @@ export function UserIntegrations() {
                 body: JSON.stringify({
                     type: "cloudflare-tunnel",
                     name: "cloudflare-tunnel",
-                    config: { token: cfToken }
+                    config: cfApiToken ? { token: cfToken, apiToken: cfApiToken } : { token: cfToken }
                 })
             })
             if (res.ok) {
                 await fetchIntegrations()
                 setCfToken("")
+                setCfApiToken("")
                 setDialogOpen(false)
             } else {
                 const data = await res.json()
@@ -192,6 +194,18 @@ export function UserIntegrations() {
                                     You can find this in your Cloudflare Zero Trust dashboard under Networks &rarr; Tunnels.
                                 </p>
                             </div>
+                            <div className="space-y-2">
+                                <Label htmlFor="apiToken">Cloudflare API Token (Optional)</Label>
+                                <Input 
+                                    id="apiToken" 
+                                    placeholder="API Token with Cloudflare Tunnel: Edit" 
+                                    value={cfApiToken}
+                                    onChange={(e) => setCfApiToken(e.target.value)}
+                                />
+                                <p className="text-[10px] text-muted-foreground">
+                                    Lets Gitship remove the tunnel&apos;s routes to apps you delete.
+                                </p>
+                            </div>
                         </div>
                         <DialogFooter>
                             <Button onClick={installCloudflare} disabled={!cfToken || installing}>
@@ -342,6 +356,14 @@ export function UserIntegrations() {
                                                             onChange={(e) => setEditConfig({ ...editConfig, token: e.target.value })} 
                                                             className="font-mono text-sm" 
                                                         />
+                                                        <Label htmlFor="edit-api-token">Cloudflare API Token (Optional)</Label>
+                                                        <Input 
+                                                            id="edit-api-token" 
+                                                            placeholder="API Token with Cloudflare Tunnel: Edit"
+                                                            value={editConfig.apiToken || ""} 
+                                                            onChange={(e) => setEditConfig({ ...editConfig, apiToken: e.target.value })} 
+                                                            className="font-mono text-sm" 
+                                                        />
                                                     </div>
                                                 )}
                                                 {int.spec.type === "cert-manager" && (
@@ -101,7 +101,7 @@ export async function getUserRepositories(): Promise<GitHubRepo[]> {
   }
 }
 
-export async function createRepositoryWebhook(owner: string, repo: string, webhookUrl: string, token: string): Promise<boolean> {
+export async function createRepositoryWebhook(owner: string, repo: string, webhookUrl: string, token: string, secret: string): Promise<boolean> {
   try {
     // 1. Check existing hooks to avoid duplicates
     const checkRes = await fetch(`https://api.github.com/repos/${owner}/${repo}/hooks`, {
@@ -131,12 +131,12 @@ export async function createRepositoryWebhook(owner: string, repo: string, webho
         body: JSON.stringify({
             name: "web",
             active: true,
-            events: ["push"],
+            events: ["push", "create", "delete"],
             config: {
                 url: webhookUrl,
                 content_type: "json",
                 insecure_ssl: "0",
-                secret: process.env.GITHUB_WEBHOOK_SECRET || "",
+                secret,
             }
         })
     })
@@ -37,11 +37,19 @@ export interface GitshipUser {
 
 export interface GitshipAppSpec {
   repoUrl: string;
+  sshUrl?: string;
   source: {
-    type: "branch" | "tag" | "commit";
+    type: "branch" | "tag" | "commit" | "pullRequest";
     value: string;
   };
-  authMethod?: string;
+  authMethod?: "auto" | "ssh" | "token" | "none";
+  credentialsSecretRef?: string;
+  image?: {
+    repository: string;
+    policy?: "exact" | "semver" | "digest";
+    tag?: string;
+    digest?: string;
+  };
   registrySecretRef: string;
   imageName: string;
   ports: PortConfig[];
@@ -77,6 +85,23 @@ export interface GitshipAppSpec {
   };
   secretRefs?: string[];
   rebuildToken?: string;
+  cancelToken?: string;
+  buildPolicy?: "queue" | "cancel-in-progress" | "skip-intermediate";
+  pinnedCommit?: string;
+  webhookSecretRef?: string;
+  knownHosts?: string;
+  previews?: {
+    enabled?: boolean;
+    maxPreviews?: number;
+    includeSecrets?: boolean;
+  };
+  rollout?: {
+    strategy?: "rolling" | "blueGreen" | "canary";
+    autoRollback?: boolean;
+    progressDeadlineSeconds?: number;
+    healthWindowSeconds?: number;
+    canaryWeight?: number;
+  };
 }
 
 export interface PortConfig {
@@ -109,6 +134,7 @@ export interface VolumeConfig {
   mountPath: string;
   size: string;
   storageClass?: string;
+  deletionPolicy?: "Retain" | "Delete";
 }
 
 export interface BuildRecord {
@@ -117,6 +143,37 @@ export interface BuildRecord {
   startTime?: string;
   completionTime?: string;
   message?: string;
+  buildName?: string;
+  tag?: string;
+  digest?: string;
+}
+
+export interface GitshipBuild {
+  apiVersion: "gitship.io/v1alpha1";
+  kind: "GitshipBuild";
+  metadata: {
+    name: string;
+    namespace: string;
+    creationTimestamp?: string;
+  };
+  spec: {
+    appName: string;
+    commitId: string;
+    image?: string;
+    jobName: string;
+    tag?: string;
+  };
+  status?: {
+    phase?: string;
+    digest?: string;
+    startTime?: string;
+    completionTime?: string;
+    failedContainer?: string;
+    exitCode?: number;
+    message?: string;
+    detectedStack?: string;
+    logs?: { container: string; log?: string }[];
+  };
 }
 
 export interface GitshipAppStatus {
@@ -131,6 +188,40 @@ export interface GitshipAppStatus {
   serviceType?: string;
   ingressHost?: string;
   latestRebuildToken?: string;
+  latestCancelToken?: string;
+  lastHealthyCommit?: string;
+  rolledBackCommit?: string;
+  rollbackReason?: string;
+  activeSlot?: "blue" | "green";
+  candidateCommit?: string;
+  candidateHealthySince?: string;
+  pinnedCommit?: string;
+  pinError?: string;
+  trackedCommit?: string;
+  commitsBehind?: number;
+  resolvedTag?: string;
+  defaultBranch?: string;
+  authMethod?: "ssh" | "token" | "anonymous";
+  previews?: PreviewStatus[];
+  conditions?: Condition[];
+  observedGeneration?: number;
+}
+
+export interface Condition {
+  type: string;
+  status: "True" | "False" | "Unknown";
+  reason: string;
+  message?: string;
+  lastTransitionTime: string;
+  observedGeneration?: number;
+}
+
+export interface PreviewStatus {
+  pullRequest: number;
+  headCommit?: string;
+  appName?: string;
+  url?: string;
+  message?: string;
 }
 
 export interface GitshipApp {
@@ -148,6 +239,19 @@ export interface GitshipApp {
   status?: GitshipAppStatus;
 }
 
+export interface WebhookDelivery {
+  id: string;
+  provider: string;
+  event: string;
+  receivedAt: string;
+  statusCode: number;
+  message?: string;
+  triggered?: string[];
+  skipped?: string[];
+  rejected?: { app: string; reason: string }[];
+  duplicate?: boolean;
+}
+
 export interface GitshipAppList {
   apiVersion: "gitship.io/v1alpha1";
   kind: "GitshipAppList";